@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A3MChunk describes one piece of a split alignment in the index file.
+type A3MChunk struct {
+	File string `json:"file"`
+	// Offset is the byte position of the chunk's first record in the
+	// original a3m, so tools can also seek into the unsplit file.
+	Offset    int64 `json:"offset"`
+	Bytes     int64 `json:"bytes"`
+	Sequences int   `json:"sequences"`
+}
+
+// A3MIndex is written next to the chunks as <name>.index so downstream
+// tools can stream or sample a very deep MSA without loading a
+// multi-GB a3m into memory.
+type A3MIndex struct {
+	Source    string     `json:"source"`
+	Sequences int        `json:"sequences"`
+	Chunks    []A3MChunk `json:"chunks"`
+}
+
+// ChunkA3MFile splits an a3m into numbered chunk files of roughly
+// maxChunkBytes each, breaking only at record boundaries, and writes
+// the index. The first record (the query) is repeated at the start of
+// every chunk so each chunk is a valid stand-alone alignment. Files
+// smaller than the limit are left untouched and no index is written.
+func ChunkA3MFile(path string, maxChunkBytes int64) (*A3MIndex, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if maxChunkBytes <= 0 || info.Size() <= maxChunkBytes {
+		return nil, nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	base := strings.TrimSuffix(path, ".a3m")
+	index := &A3MIndex{Source: filepath.Base(path)}
+
+	var chunk *os.File
+	var chunkBytes int64
+	var query []byte
+	var record []byte
+	var recordOffset int64
+	var offset int64
+
+	openChunk := func(firstOffset int64) error {
+		name := fmt.Sprintf("%s.%d.a3m", base, len(index.Chunks))
+		var err error
+		chunk, err = os.Create(name)
+		if err != nil {
+			return err
+		}
+		chunkBytes = 0
+		index.Chunks = append(index.Chunks, A3MChunk{File: filepath.Base(name), Offset: firstOffset})
+		if len(index.Chunks) > 1 && len(query) > 0 {
+			// repeat the query so the chunk stands alone
+			n, err := chunk.Write(query)
+			if err != nil {
+				return err
+			}
+			chunkBytes += int64(n)
+			index.Chunks[len(index.Chunks)-1].Sequences++
+		}
+		return nil
+	}
+	closeChunk := func() error {
+		if chunk == nil {
+			return nil
+		}
+		index.Chunks[len(index.Chunks)-1].Bytes = chunkBytes
+		err := chunk.Close()
+		chunk = nil
+		return err
+	}
+	flushRecord := func() error {
+		if len(record) == 0 {
+			return nil
+		}
+		if query == nil {
+			query = append([]byte(nil), record...)
+		}
+		if chunk == nil || chunkBytes+int64(len(record)) > maxChunkBytes {
+			if err := closeChunk(); err != nil {
+				return err
+			}
+			if err := openChunk(recordOffset); err != nil {
+				return err
+			}
+		}
+		n, err := chunk.Write(record)
+		if err != nil {
+			return err
+		}
+		chunkBytes += int64(n)
+		index.Chunks[len(index.Chunks)-1].Sequences++
+		index.Sequences++
+		record = record[:0]
+		return nil
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		lineLength := int64(len(line)) + 1
+		if len(line) > 0 && line[0] == '>' {
+			if err := flushRecord(); err != nil {
+				return nil, err
+			}
+			recordOffset = offset
+		}
+		record = append(record, line...)
+		record = append(record, '\n')
+		offset += lineLength
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flushRecord(); err != nil {
+		return nil, err
+	}
+	if err := closeChunk(); err != nil {
+		return nil, err
+	}
+
+	payload, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path+".index", payload, 0644); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// ChunkLargeAlignments splits every a3m in the result directory that
+// exceeds the limit; workers call it before archiving.
+func ChunkLargeAlignments(resultBase string, maxChunkBytes int64) error {
+	matches, err := filepath.Glob(filepath.Join(resultBase, "*.a3m"))
+	if err != nil {
+		return err
+	}
+	for _, path := range matches {
+		if _, err := ChunkA3MFile(path, maxChunkBytes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+type ConfigAdmin struct {
+	// Token protects the admin endpoints; with an empty token the
+	// endpoints are not registered at all.
+	Token string `json:"token"`
+}
+
+func adminAuth(token string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		provided := req.Header.Get("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, req)
+	}
+}
+
+func adminError(w http.ResponseWriter, err error) {
+	if err == ErrInvalidTicket {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// RegisterAdminRoutes exposes the operator API: inspect the queue, change
+// priorities, cancel or requeue tickets, and manage the dead-letter
+// queue. Everything is guarded by the configured admin token.
+func RegisterAdminRoutes(r *mux.Router, jobsystem JobSystem, config ConfigAdmin) {
+	if config.Token == "" {
+		return
+	}
+	store := UnwrapJobSystem(jobsystem)
+	if admin, ok := store.(AdminSystem); ok {
+		r.HandleFunc("/admin/tickets", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+			var statuses []Status
+			if filter := req.URL.Query().Get("status"); filter != "" {
+				for _, value := range strings.Split(filter, ",") {
+					statuses = append(statuses, Status(strings.ToUpper(value)))
+				}
+			}
+			tickets, err := admin.ListTickets(statuses)
+			if err != nil {
+				adminError(w, err)
+				return
+			}
+			if tickets == nil {
+				tickets = []AdminTicket{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(tickets)
+		})).Methods("GET")
+		r.HandleFunc("/admin/tickets/{ticket}/priority", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+			id := Id(mux.Vars(req)["ticket"])
+			priority := ParsePriority(req.FormValue("priority"))
+			if err := admin.Reprioritize(id, priority); err != nil {
+				adminError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(Ticket{Id: id, Status: StatusPending})
+		})).Methods("POST")
+		r.HandleFunc("/admin/tickets/{ticket}/cancel", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+			id := Id(mux.Vars(req)["ticket"])
+			if err := admin.Cancel(id); err != nil {
+				adminError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(Ticket{Id: id, Status: StatusCancelled})
+		})).Methods("POST")
+		r.HandleFunc("/admin/tickets/{ticket}/requeue", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+			id := Id(mux.Vars(req)["ticket"])
+			if err := admin.Requeue(id); err != nil {
+				adminError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(Ticket{Id: id, Status: StatusPending})
+		})).Methods("POST")
+	}
+	if pausable, ok := jobsystem.(*PausableJobSystem); ok {
+		writeState := func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]bool{"paused": pausable.Paused()})
+		}
+		r.HandleFunc("/admin/queue", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+			writeState(w)
+		})).Methods("GET")
+		r.HandleFunc("/admin/queue/pause", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+			pausable.Pause()
+			writeState(w)
+		})).Methods("POST")
+		r.HandleFunc("/admin/queue/resume", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+			pausable.Resume()
+			writeState(w)
+		})).Methods("POST")
+	}
+	if deadletters, ok := store.(DeadLetterSystem); ok {
+		r.HandleFunc("/admin/deadletters", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+			letters, err := deadletters.DeadLetters()
+			if err != nil {
+				adminError(w, err)
+				return
+			}
+			if letters == nil {
+				letters = []DeadLetter{}
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(letters)
+		})).Methods("GET")
+		r.HandleFunc("/admin/deadletters/{ticket}/requeue", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+			id := Id(mux.Vars(req)["ticket"])
+			if err := deadletters.RequeueDeadLetter(id); err != nil {
+				adminError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(Ticket{Id: id, Status: StatusPending})
+		})).Methods("POST")
+	}
+}
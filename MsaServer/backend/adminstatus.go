@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// WorkerInfo is one connected worker as seen through its advertisements.
+type WorkerInfo struct {
+	Name      string    `json:"name"`
+	Databases []string  `json:"databases,omitempty"`
+	Seen      time.Time `json:"seen"`
+}
+
+// WorkerRegistry is implemented by job systems that track worker
+// advertisements and can list them for operators.
+type WorkerRegistry interface {
+	Workers() ([]WorkerInfo, error)
+}
+
+// runningTicket is one in-flight ticket with the worker that claimed it.
+type runningTicket struct {
+	Id     Id      `json:"id"`
+	Type   JobType `json:"type"`
+	Worker string  `json:"worker,omitempty"`
+}
+
+// adminStatus answers "what is the server doing right now?" in one
+// response instead of a log grep.
+type adminStatus struct {
+	Paused      bool            `json:"paused"`
+	QueueLength int             `json:"queue_length"`
+	QueueDepths map[JobType]int `json:"queue_depths"`
+	Running     []runningTicket `json:"running"`
+	Workers     []WorkerInfo    `json:"workers"`
+}
+
+// RegisterAdminStatusRoute adds GET /admin/status with the connected
+// workers, the tickets they are running, and per-type queue depths.
+func RegisterAdminStatusRoute(r *mux.Router, jobsystem JobSystem, config ConfigAdmin) {
+	if config.Token == "" {
+		return
+	}
+	r.HandleFunc("/admin/status", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		status := adminStatus{QueueDepths: make(map[JobType]int)}
+		if pausable, ok := jobsystem.(*PausableJobSystem); ok {
+			status.Paused = pausable.Paused()
+		}
+		length, err := jobsystem.QueueLength()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		status.QueueLength = length
+		store := UnwrapJobSystem(jobsystem)
+		if admin, ok := store.(AdminSystem); ok {
+			pending, err := admin.ListTickets([]Status{StatusPending})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, ticket := range pending {
+				status.QueueDepths[ticket.Type]++
+			}
+			running, err := admin.ListTickets([]Status{StatusRunning})
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			for _, ticket := range running {
+				entry := runningTicket{Id: ticket.Id, Type: ticket.Type}
+				// the worker is stamped on the RUNNING transition
+				if full, err := jobsystem.GetTicket(ticket.Id); err == nil {
+					for _, transition := range full.History {
+						if transition.Status == StatusRunning {
+							entry.Worker = transition.Worker
+						}
+					}
+				}
+				status.Running = append(status.Running, entry)
+			}
+		}
+		if registry, ok := store.(WorkerRegistry); ok {
+			if workers, err := registry.Workers(); err == nil {
+				status.Workers = workers
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
+	})).Methods("GET")
+}
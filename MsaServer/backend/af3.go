@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// AF3Template is one template entry of the AlphaFold3 input schema.
+// The server currently only carries the alignment; predictors resolve
+// the mmCIF from the template name themselves.
+type AF3Template struct {
+	MmcifPath       string `json:"mmcifPath,omitempty"`
+	QueryIndices    []int  `json:"queryIndices,omitempty"`
+	TemplateIndices []int  `json:"templateIndices,omitempty"`
+}
+
+// AF3Protein is one protein chain of the AlphaFold3 input schema, with
+// the MSAs inlined as a3m text the way AF3 expects.
+type AF3Protein struct {
+	Id          string        `json:"id"`
+	Sequence    string        `json:"sequence"`
+	UnpairedMsa string        `json:"unpairedMsa"`
+	PairedMsa   string        `json:"pairedMsa"`
+	Templates   []AF3Template `json:"templates"`
+}
+
+// AF3Sequence wraps a chain in the schema's tagged-union form.
+type AF3Sequence struct {
+	Protein AF3Protein `json:"protein"`
+}
+
+// AF3Input is the AlphaFold3 input JSON document; the server fills the
+// MSA fields so the output can be fed directly into AF3-compatible
+// predictors.
+type AF3Input struct {
+	Name       string        `json:"name"`
+	ModelSeeds []int         `json:"modelSeeds"`
+	Sequences  []AF3Sequence `json:"sequences"`
+	Dialect    string        `json:"dialect"`
+	Version    int           `json:"version"`
+}
+
+// af3ChainIds assigns chain identifiers A, B, ... the way AF3 examples
+// do.
+func af3ChainId(index int) string {
+	letters := "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	if index < len(letters) {
+		return string(letters[index])
+	}
+	return string(letters[index/len(letters)-1]) + string(letters[index%len(letters)])
+}
+
+// BuildAF3Input assembles the AlphaFold3 input document from a result
+// directory: every chain a3m becomes an unpairedMsa, pair.a3m (if
+// present) supplies the pairedMsa rows.
+func BuildAF3Input(resultBase string, name string) (*AF3Input, error) {
+	matches, err := filepath.Glob(filepath.Join(resultBase, "*.a3m"))
+	if err != nil {
+		return nil, err
+	}
+	var chains []string
+	pairPath := ""
+	for _, path := range matches {
+		if filepath.Base(path) == "pair.a3m" {
+			pairPath = path
+			continue
+		}
+		chains = append(chains, path)
+	}
+	sort.Strings(chains)
+	pairedMsa := ""
+	if pairPath != "" {
+		raw, err := os.ReadFile(pairPath)
+		if err != nil {
+			return nil, err
+		}
+		pairedMsa = string(raw)
+	}
+	input := &AF3Input{
+		Name:       name,
+		ModelSeeds: []int{1},
+		Dialect:    "alphafold3",
+		Version:    1,
+	}
+	for index, path := range chains {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := readA3MEntries(file)
+		file.Close()
+		if err != nil {
+			return nil, err
+		}
+		sequence := ""
+		if len(entries) > 0 {
+			sequence = strings.ReplaceAll(entries[0].Sequence, "-", "")
+		}
+		input.Sequences = append(input.Sequences, AF3Sequence{Protein: AF3Protein{
+			Id:          af3ChainId(index),
+			Sequence:    sequence,
+			UnpairedMsa: string(raw),
+			PairedMsa:   pairedMsa,
+			Templates:   []AF3Template{},
+		}})
+	}
+	return input, nil
+}
+
+// RegisterAF3Route adds GET /ticket/{ticket}/af3, which packages the
+// result MSAs into the AlphaFold3 input JSON schema.
+func RegisterAF3Route(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/af3", func(w http.ResponseWriter, req *http.Request) {
+		id := Id(mux.Vars(req)["ticket"])
+		status, err := jobsystem.Status(id)
+		if err != nil || status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusNotFound)
+			return
+		}
+		input, err := BuildAF3Input(filepath.Join(jobsbase, string(id)), string(id))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+string(id)+".af3.json\"")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(input)
+	}).Methods("GET")
+}
@@ -0,0 +1,40 @@
+package main
+
+import "sync"
+
+// databaseAffinity remembers which database the last dequeued search
+// targeted. Serving queued searches against the same database
+// back-to-back keeps its index hot in the page cache, which dominates
+// throughput on memory-constrained servers.
+type databaseAffinity struct {
+	mu   sync.Mutex
+	last string
+}
+
+// Choose prefers a candidate whose head ticket targets the database
+// served last; ok is false when no candidate matches and the caller
+// should fall back to its usual (fair-share) choice.
+func (a *databaseAffinity) Choose(candidates map[string][]string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.last == "" {
+		return "", false
+	}
+	for key, databases := range candidates {
+		for _, database := range databases {
+			if database == a.last {
+				return key, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Served records the database of the ticket that was just dequeued.
+func (a *databaseAffinity) Served(databases []string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(databases) > 0 {
+		a.last = databases[0]
+	}
+}
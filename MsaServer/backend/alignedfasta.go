@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// fastaLineWidth wraps aligned FASTA sequences at the conventional
+// 60 columns.
+const fastaLineWidth = 60
+
+// WriteAlignedFasta converts an a3m alignment to aligned FASTA: the
+// lowercase insertion columns are dropped so every row spans exactly the
+// query columns. Design pipelines that cannot parse the a3m lowercase
+// convention consume this instead.
+func WriteAlignedFasta(w io.Writer, a3m io.Reader) error {
+	entries, err := readA3MEntries(a3m)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(w)
+	for _, entry := range entries {
+		fmt.Fprintf(buffered, ">%s\n", entry.Name)
+		sequence := entry.Sequence
+		for len(sequence) > fastaLineWidth {
+			buffered.WriteString(sequence[:fastaLineWidth])
+			buffered.WriteByte('\n')
+			sequence = sequence[fastaLineWidth:]
+		}
+		buffered.WriteString(sequence)
+		buffered.WriteByte('\n')
+	}
+	return buffered.Flush()
+}
+
+// ConvertAlignmentsToFasta writes a <name>.afa next to every a3m in the
+// result directory, so the archive carries both representations.
+func ConvertAlignmentsToFasta(resultBase string) error {
+	matches, err := filepath.Glob(filepath.Join(resultBase, "*.a3m"))
+	if err != nil {
+		return err
+	}
+	for _, path := range matches {
+		source, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		target, err := os.Create(strings.TrimSuffix(path, ".a3m") + ".afa")
+		if err != nil {
+			source.Close()
+			return err
+		}
+		err = WriteAlignedFasta(target, source)
+		source.Close()
+		if closeErr := target.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterAlignedFastaRoute adds GET /ticket/{ticket}/fasta/{name},
+// which converts one a3m file from the result directory to aligned
+// FASTA on the fly.
+func RegisterAlignedFastaRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/fasta/{name}", func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		id := Id(vars["ticket"])
+		name := vars["name"]
+		if name != filepath.Base(name) || !strings.HasSuffix(name, ".a3m") {
+			http.Error(w, "invalid file name", http.StatusBadRequest)
+			return
+		}
+		status, err := jobsystem.Status(id)
+		if err != nil || status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusNotFound)
+			return
+		}
+		file, err := os.Open(filepath.Join(jobsbase, string(id), name))
+		if err != nil {
+			http.Error(w, "no such result file", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+strings.TrimSuffix(name, ".a3m")+".afa\"")
+		if err := WriteAlignedFasta(w, file); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}).Methods("GET")
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Scopes an API key can carry; middleware checks one scope per route
+// group.
+const (
+	ScopeSubmit   = "submit"
+	ScopeDownload = "download"
+	ScopeAdmin    = "admin"
+)
+
+type ConfigAuth struct {
+	Enabled bool `json:"enabled"`
+	// KeyFile is the JSON file persisting issued keys.
+	KeyFile string `json:"keyfile"`
+}
+
+// ApiKey is one issued credential. Revoked keys are kept in the file so
+// the audit trail survives.
+type ApiKey struct {
+	Key     string    `json:"key"`
+	Name    string    `json:"name"`
+	Scopes  []string  `json:"scopes"`
+	Created time.Time `json:"created"`
+	Revoked bool      `json:"revoked"`
+}
+
+var ErrUnknownApiKey = errors.New("unknown API key")
+
+// ApiKeyStore manages issued keys, persisted as a JSON file so a
+// restart does not lose credentials. Deployments beyond localhost
+// enable it to close the otherwise open server.
+type ApiKeyStore struct {
+	mutex sync.RWMutex
+	path  string
+	keys  map[string]*ApiKey
+}
+
+func NewApiKeyStore(path string) (*ApiKeyStore, error) {
+	store := &ApiKeyStore{path: path, keys: make(map[string]*ApiKey)}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	var keys []*ApiKey
+	if err := json.NewDecoder(file).Decode(&keys); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		store.keys[key.Key] = key
+	}
+	return store, nil
+}
+
+func (s *ApiKeyStore) save() error {
+	keys := make([]*ApiKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	payload, err := json.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := s.path + ".tmp"
+	if err := os.WriteFile(temp, payload, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, s.path)
+}
+
+// Create issues a new key with the given scopes.
+func (s *ApiKeyStore) Create(name string, scopes []string) (*ApiKey, error) {
+	buffer := make([]byte, 24)
+	if _, err := rand.Read(buffer); err != nil {
+		return nil, err
+	}
+	key := &ApiKey{
+		Key:     hex.EncodeToString(buffer),
+		Name:    name,
+		Scopes:  scopes,
+		Created: time.Now(),
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[key.Key] = key
+	if err := s.save(); err != nil {
+		delete(s.keys, key.Key)
+		return nil, err
+	}
+	return key, nil
+}
+
+// Revoke disables a key; requests carrying it fail from then on.
+func (s *ApiKeyStore) Revoke(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	entry, found := s.keys[key]
+	if !found {
+		return ErrUnknownApiKey
+	}
+	entry.Revoked = true
+	return s.save()
+}
+
+// Check reports whether the key exists, is not revoked and carries the
+// scope.
+func (s *ApiKeyStore) Check(key string, scope string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	entry, found := s.keys[key]
+	if !found || entry.Revoked {
+		return false
+	}
+	for _, granted := range entry.Scopes {
+		if granted == scope || granted == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// NameOf resolves an active key to its registered name; unknown or
+// revoked keys yield the empty string.
+func (s *ApiKeyStore) NameOf(key string) string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	entry, found := s.keys[key]
+	if !found || entry.Revoked {
+		return ""
+	}
+	return entry.Name
+}
+
+// List returns all keys with the secret redacted except for a prefix.
+func (s *ApiKeyStore) List() []ApiKey {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]ApiKey, 0, len(s.keys))
+	for _, entry := range s.keys {
+		redacted := *entry
+		if len(redacted.Key) > 8 {
+			redacted.Key = redacted.Key[:8] + "…"
+		}
+		keys = append(keys, redacted)
+	}
+	return keys
+}
+
+// ScopeMiddleware rejects requests whose X-API-Key header does not
+// carry the required scope. Identities BearerMiddleware minted from a
+// verified OIDC token carry the submit and download scopes implicitly;
+// they are never in the issued-key store. With auth disabled it is a
+// no-op so local single-user deployments keep working unchanged.
+func ScopeMiddleware(store *ApiKeyStore, scope string, enabled bool) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if enabled && !checkScope(store, req.Header.Get("X-API-Key"), scope) {
+				http.Error(w, "missing or insufficient API key", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func checkScope(store *ApiKeyStore, key string, scope string) bool {
+	if strings.HasPrefix(key, OidcKeyPrefix) {
+		return scope == ScopeSubmit || scope == ScopeDownload
+	}
+	return store.Check(key, scope)
+}
+
+// RegisterApiKeyAdminRoutes adds key management under the admin API:
+// GET /admin/keys, POST /admin/keys {name, scopes}, POST
+// /admin/keys/{key}/revoke.
+func RegisterApiKeyAdminRoutes(r *mux.Router, store *ApiKeyStore, config ConfigAdmin) {
+	if config.Token == "" {
+		return
+	}
+	r.HandleFunc("/admin/keys", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(store.List())
+	})).Methods("GET")
+	r.HandleFunc("/admin/keys", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Name   string   `json:"name"`
+			Scopes []string `json:"scopes"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body.Scopes) == 0 {
+			body.Scopes = []string{ScopeSubmit, ScopeDownload}
+		}
+		key, err := store.Create(body.Name, body.Scopes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(key)
+	})).Methods("POST")
+	r.HandleFunc("/admin/keys/{key}/revoke", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		if err := store.Revoke(mux.Vars(req)["key"]); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})).Methods("POST")
+}
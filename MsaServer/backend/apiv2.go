@@ -0,0 +1,268 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// V2Submission is the structured submission body of /api/v2. The legacy
+// /api/v1 endpoints keep encoding everything in a single mode string for
+// the existing ColabFold client; v2 clients use explicit fields that the
+// server validates before accepting the ticket.
+type V2Submission struct {
+	Type      string   `json:"type"`
+	Query     string   `json:"query"`
+	Databases []string `json:"databases,omitempty"`
+	Mode      struct {
+		// UseEnv includes the environmental database in MSA jobs.
+		UseEnv bool `json:"use_env"`
+		// UseTemplates includes the pdb70 template search.
+		UseTemplates bool `json:"use_templates"`
+		// UseFilter enables the diversity filter.
+		UseFilter bool `json:"use_filter"`
+		// Pairing is empty, "greedy" or "complete" for pair jobs.
+		Pairing string `json:"pairing,omitempty"`
+	} `json:"mode"`
+	// Taxonomy adds taxid annotations to the output.
+	Taxonomy bool `json:"taxonomy,omitempty"`
+	// Output selects the hit table format, currently "" or "m8".
+	Output string `json:"output,omitempty"`
+	// OutputColumns picks the mmseqs --format-output columns for search
+	// jobs; validated against the server allowlist.
+	OutputColumns []string `json:"output_columns,omitempty"`
+	// Filter post-filters hits server-side before archiving.
+	Filter *HitFilter `json:"filter,omitempty"`
+	// ArchiveZip additionally persists the result set as a .zip for
+	// platforms where tar.gz handling is awkward; downloads negotiate a
+	// re-encoded zip on the fly either way.
+	ArchiveZip bool `json:"archive_zip,omitempty"`
+	// Delivery pushes the finished archive into the submitter's own
+	// bucket; the target is probed with a marker write before the job
+	// is accepted.
+	Delivery       *DeliveryTarget `json:"delivery,omitempty"`
+	Email          string          `json:"email,omitempty"`
+	Priority       string          `json:"priority,omitempty"`
+	NotBefore      time.Time       `json:"not_before,omitempty"`
+	DependsOn      []string        `json:"depends_on,omitempty"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty"`
+}
+
+type V2ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e *V2ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Message)
+}
+
+var v2JobTypes = map[string]JobType{
+	"msa":             JobTypeMsa,
+	"search":          JobTypeSearch,
+	"structuresearch": JobTypeStructureSearch,
+	"pair":            JobTypePair,
+}
+
+// Validate checks the structured submission; it returns the first
+// problem found so the client gets an actionable message.
+func (s *V2Submission) Validate() error {
+	if _, found := v2JobTypes[s.Type]; !found {
+		return &V2ValidationError{Field: "type", Message: "must be one of msa, search, structuresearch, pair"}
+	}
+	if strings.TrimSpace(s.Query) == "" {
+		return &V2ValidationError{Field: "query", Message: "must not be empty"}
+	}
+	switch s.Mode.Pairing {
+	case "", "greedy", "complete":
+	default:
+		return &V2ValidationError{Field: "mode.pairing", Message: "must be empty, greedy or complete"}
+	}
+	if s.Mode.Pairing != "" && s.Type != "pair" {
+		return &V2ValidationError{Field: "mode.pairing", Message: "only valid for pair jobs"}
+	}
+	switch s.Output {
+	case "", "m8":
+	default:
+		return &V2ValidationError{Field: "output", Message: "must be empty or m8"}
+	}
+	switch s.Priority {
+	case "", "interactive", "batch":
+	default:
+		return &V2ValidationError{Field: "priority", Message: "must be interactive or batch"}
+	}
+	if len(s.OutputColumns) > 0 {
+		switch s.Type {
+		case "search":
+			if err := ValidateOutputColumns(s.OutputColumns); err != nil {
+				return &V2ValidationError{Field: "output_columns", Message: err.Error()}
+			}
+		case "structuresearch":
+			if err := ValidateStructureOutputColumns(s.OutputColumns); err != nil {
+				return &V2ValidationError{Field: "output_columns", Message: err.Error()}
+			}
+		default:
+			return &V2ValidationError{Field: "output_columns", Message: "only valid for search jobs"}
+		}
+	}
+	if err := s.Filter.Validate(); err != nil {
+		return &V2ValidationError{Field: "filter", Message: err.Error()}
+	}
+	if s.Filter != nil && s.Filter.MinCoverage > 0 && s.Type != "msa" {
+		return &V2ValidationError{Field: "filter.min_coverage", Message: "only valid for msa jobs, which produce alignments"}
+	}
+	if s.Delivery != nil {
+		if err := s.Delivery.Validate(); err != nil {
+			return &V2ValidationError{Field: "delivery", Message: err.Error()}
+		}
+	}
+	return nil
+}
+
+// legacyMode folds the structured fields back into the v1 mode string
+// the worker understands.
+func (s *V2Submission) legacyMode() string {
+	var parts []string
+	if s.Mode.UseEnv {
+		parts = append(parts, "env")
+	}
+	if !s.Mode.UseFilter {
+		parts = append(parts, "nofilter")
+	}
+	if s.Mode.UseTemplates {
+		parts = append(parts, "templates")
+	}
+	if s.Taxonomy {
+		parts = append(parts, "taxonomy")
+	}
+	if s.Output == "m8" {
+		parts = append(parts, "m8output")
+	}
+	if s.ArchiveZip {
+		parts = append(parts, "zipoutput")
+	}
+	if s.Mode.Pairing != "" {
+		parts = append(parts, "pair:"+s.Mode.Pairing)
+	}
+	return strings.Join(parts, "-")
+}
+
+// ToJobRequest converts a validated submission into the internal job
+// request.
+func (s *V2Submission) ToJobRequest() JobRequest {
+	jobType := v2JobTypes[s.Type]
+	mode := s.legacyMode()
+	var job interface{}
+	switch jobType {
+	case JobTypeMsa:
+		job = MsaJob{Mode: mode, Query: s.Query, DBs: s.Databases, Filter: s.Filter}
+	case JobTypeSearch:
+		job = SearchJob{Mode: mode, Query: s.Query, Database: s.Databases, OutputColumns: s.OutputColumns, Filter: s.Filter}
+	case JobTypeStructureSearch:
+		job = StructureSearchJob{Mode: mode, Query: s.Query, Database: s.Databases, OutputColumns: s.OutputColumns, Filter: s.Filter}
+	case JobTypePair:
+		job = PairJob{Mode: mode, Query: s.Query}
+	}
+	payload, _ := json.Marshal(job)
+	hash := sha256.Sum256(append([]byte(jobType), payload...))
+	request := JobRequest{
+		Id:        Id(hex.EncodeToString(hash[:])[:32]),
+		Type:      jobType,
+		Job:       json.RawMessage(payload),
+		Email:     s.Email,
+		Priority:  ParsePriority(s.Priority),
+		NotBefore: s.NotBefore,
+	}
+	for _, dependency := range s.DependsOn {
+		request.DependsOn = append(request.DependsOn, Id(dependency))
+	}
+	return request
+}
+
+// RegisterApiV2 adds the structured, versioned API under /api/v2. The
+// legacy routes stay untouched for the existing ColabFold client. With
+// a non-nil alias resolver, submitted database aliases are pinned to
+// concrete versions before the job is stored; a non-nil acl restricts
+// access-controlled databases to their cleared API keys; a non-nil
+// retirement rejects databases being decommissioned.
+func RegisterApiV2(r *mux.Router, jobsystem JobSystem, jobsbase string, aliases *DbAliases, acl *DbAcl, retirement *DbRetirement) {
+	v2 := r.PathPrefix("/api/v2").Subrouter()
+	v2.HandleFunc("/tickets", func(w http.ResponseWriter, req *http.Request) {
+		var submission V2Submission
+		if err := json.NewDecoder(req.Body).Decode(&submission); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := submission.Validate(); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(err)
+			return
+		}
+		if aliases != nil && len(submission.Databases) > 0 {
+			resolved, err := aliases.ResolveAll(submission.Databases)
+			if err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&V2ValidationError{Field: "databases", Message: err.Error()})
+				return
+			}
+			submission.Databases = resolved
+		}
+		if err := acl.Authorize(req.Header.Get("X-API-Key"), submission.Databases); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := AuthorizeUserDatabases(req.Header.Get("X-API-Key"), submission.Databases); err != nil {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err := retirement.CheckNotRetired(submission.Databases); err != nil {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		if submission.Delivery != nil {
+			if err := submission.Delivery.Probe(); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(&V2ValidationError{Field: "delivery", Message: err.Error()})
+				return
+			}
+		}
+		request := submission.ToJobRequest()
+		request.ApiKey = req.Header.Get("X-API-Key")
+		idempotencyKey := submission.IdempotencyKey
+		if idempotencyKey == "" {
+			idempotencyKey = req.Header.Get("Idempotency-Key")
+		}
+		ticket, err := NewJobIdempotent(jobsystem, request, jobsbase, false, idempotencyKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if submission.Delivery != nil {
+			if err := SaveDeliveryTarget(jobsbase, ticket.Id, *submission.Delivery); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ticket)
+	}).Methods("POST")
+	v2.HandleFunc("/tickets/{ticket}", func(w http.ResponseWriter, req *http.Request) {
+		ticket, err := jobsystem.GetTicket(Id(mux.Vars(req)["ticket"]))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		AttachResultStats(jobsbase, &ticket)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ticket)
+	}).Methods("GET")
+}
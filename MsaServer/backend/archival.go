@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type ConfigArchival struct {
+	Enabled bool `json:"enabled"`
+	// After is the age in seconds at which finished tickets are
+	// archived and purged from the live job system.
+	After int64 `json:"after"`
+	// Interval is the sweep interval in seconds.
+	Interval int64 `json:"interval"`
+	// Directory receives one JSONL file per day with the archived
+	// ticket metadata; it can be synced to object storage.
+	Directory string `json:"directory"`
+}
+
+// ArchivedTicket is the long-term record of a finished ticket: enough
+// for post-hoc analytics without keeping the live store large.
+type ArchivedTicket struct {
+	Id       Id                 `json:"id"`
+	Status   Status             `json:"status"`
+	Type     JobType            `json:"type"`
+	ApiKey   string             `json:"api_key,omitempty"`
+	Job      json.RawMessage    `json:"job,omitempty"`
+	Created  time.Time          `json:"created"`
+	History  []TicketTransition `json:"history,omitempty"`
+	Failures []FailureRecord    `json:"failures,omitempty"`
+}
+
+// ArchivalSystem is implemented by job systems that can hand out and
+// purge finished tickets.
+type ArchivalSystem interface {
+	// FinishedBefore returns tickets in a terminal status whose last
+	// update is older than the cutoff.
+	FinishedBefore(cutoff time.Time) ([]ArchivedTicket, error)
+	// PurgeTicket removes a ticket and all its associated records.
+	PurgeTicket(id Id) error
+}
+
+func isTerminal(status Status) bool {
+	switch status {
+	case StatusComplete, StatusError, StatusExpired, StatusCancelled, StatusDeadLetter:
+		return true
+	}
+	return false
+}
+
+// ArchiveFinishedTickets runs one archival sweep: finished tickets older
+// than the configured age are appended to the day's JSONL file and then
+// purged from the live store. It returns the number of archived tickets.
+func ArchiveFinishedTickets(jobsystem JobSystem, config ConfigArchival) (int, error) {
+	store, ok := UnwrapJobSystem(jobsystem).(ArchivalSystem)
+	if !ok || !config.Enabled {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-time.Duration(config.After) * time.Second)
+	tickets, err := store.FinishedBefore(cutoff)
+	if err != nil {
+		return 0, err
+	}
+	if len(tickets) == 0 {
+		return 0, nil
+	}
+	if err := os.MkdirAll(config.Directory, 0755); err != nil {
+		return 0, err
+	}
+	name := filepath.Join(config.Directory, "tickets-"+time.Now().UTC().Format("20060102")+".jsonl")
+	file, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	encoder := json.NewEncoder(file)
+	archived := 0
+	for _, ticket := range tickets {
+		if err := encoder.Encode(ticket); err != nil {
+			return archived, err
+		}
+		// only purge after the record is safely written out
+		if err := file.Sync(); err != nil {
+			return archived, err
+		}
+		if err := store.PurgeTicket(ticket.Id); err != nil {
+			return archived, err
+		}
+		archived++
+	}
+	return archived, nil
+}
+
+// ArchivalLoop runs archival sweeps until the stop channel is closed.
+func ArchivalLoop(jobsystem JobSystem, config ConfigArchival, stop <-chan struct{}) {
+	if !config.Enabled {
+		return
+	}
+	interval := time.Duration(config.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ArchiveFinishedTickets(jobsystem, config)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+type ConfigBackpressure struct {
+	Enabled bool `json:"enabled"`
+	// MaxQueueDepth rejects submissions once this many tickets are
+	// pending; zero disables the depth check.
+	MaxQueueDepth int `json:"maxqueuedepth"`
+	// EstimatedJobSeconds is the average job duration used to estimate
+	// the current wait.
+	EstimatedJobSeconds float64 `json:"estimatedjobseconds"`
+	// MaxEstimatedWait rejects submissions once the estimated wait
+	// exceeds this many seconds; zero disables the wait check.
+	MaxEstimatedWait float64 `json:"maxestimatedwait"`
+	// Workers is the number of workers draining the queue, used for
+	// the wait estimate.
+	Workers int `json:"workers"`
+}
+
+// BackpressureError tells a client the queue is over capacity and how
+// long to back off.
+type BackpressureError struct {
+	QueueDepth    int           `json:"queue_depth"`
+	EstimatedWait time.Duration `json:"-"`
+}
+
+func (e *BackpressureError) Error() string {
+	return fmt.Sprintf("queue over capacity: %d tickets pending, estimated wait %s",
+		e.QueueDepth, e.EstimatedWait.Round(time.Second))
+}
+
+// EstimateQueueWait derives the expected queue wait from the current
+// depth, the average job duration and the worker count.
+func EstimateQueueWait(depth int, config ConfigBackpressure) time.Duration {
+	workers := config.Workers
+	if workers < 1 {
+		workers = 1
+	}
+	seconds := float64(depth) * config.EstimatedJobSeconds / float64(workers)
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// CheckBackpressure returns a BackpressureError when the queue is too
+// deep or the estimated wait too long for new submissions.
+func CheckBackpressure(jobsystem JobSystem, config ConfigBackpressure) error {
+	if !config.Enabled {
+		return nil
+	}
+	depth, err := jobsystem.QueueLength()
+	if err != nil {
+		return err
+	}
+	wait := EstimateQueueWait(depth, config)
+	if config.MaxQueueDepth > 0 && depth >= config.MaxQueueDepth {
+		return &BackpressureError{QueueDepth: depth, EstimatedWait: wait}
+	}
+	if config.MaxEstimatedWait > 0 && wait.Seconds() >= config.MaxEstimatedWait {
+		return &BackpressureError{QueueDepth: depth, EstimatedWait: wait}
+	}
+	return nil
+}
+
+// WriteBackpressure renders a backpressure rejection as a 429 response
+// with a Retry-After header, so clients like colabfold_batch can back
+// off intelligently.
+func WriteBackpressure(w http.ResponseWriter, err *BackpressureError) {
+	seconds := int(err.EstimatedWait.Seconds()) + 1
+	w.Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":          err.Error(),
+		"queue_depth":    err.QueueDepth,
+		"estimated_wait": seconds,
+	})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+// maxBulkStatusTickets bounds one bulk request; batch clients split
+// larger sets.
+const maxBulkStatusTickets = 1000
+
+// RegisterBulkStatusRoute adds POST /tickets/status, which resolves up
+// to maxBulkStatusTickets ticket IDs in one response. Batch clients
+// polling hundreds of tickets use it instead of one request per ticket.
+// The body is either a JSON array of IDs or a form field "tickets[]".
+func RegisterBulkStatusRoute(r *mux.Router, jobsystem JobSystem) {
+	r.HandleFunc("/tickets/status", func(w http.ResponseWriter, req *http.Request) {
+		var ids []string
+		if req.Header.Get("Content-Type") == "application/json" {
+			if err := json.NewDecoder(req.Body).Decode(&ids); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		} else {
+			if err := req.ParseForm(); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			ids = req.Form["tickets[]"]
+		}
+		if len(ids) == 0 {
+			http.Error(w, "no ticket ids given", http.StatusBadRequest)
+			return
+		}
+		if len(ids) > maxBulkStatusTickets {
+			http.Error(w, fmt.Sprintf("at most %d tickets per request", maxBulkStatusTickets), http.StatusRequestEntityTooLarge)
+			return
+		}
+		tickets, err := jobsystem.MultiStatus(ids)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tickets)
+	}).Methods("POST")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ConfigCallback struct {
+	Enabled bool `json:"enabled"`
+	// Secret signs the payload so receivers can verify it came from
+	// this server.
+	Secret string `json:"secret"`
+	// BaseUrl is the public address of this server, used to build the
+	// result URL in the payload.
+	BaseUrl string `json:"baseurl"`
+	// Retries is how often a failed delivery is reattempted.
+	Retries int `json:"retries"`
+}
+
+const callbackFileName = "callback.url"
+
+// CallbackPayload is what gets POSTed to the submitter's callback_url
+// once the job reaches a terminal status, so pipelines don't have to
+// poll.
+type CallbackPayload struct {
+	Id        Id                  `json:"id"`
+	Status    Status              `json:"status"`
+	ResultUrl string              `json:"result_url,omitempty"`
+	Stats     map[string]MSAStats `json:"stats,omitempty"`
+}
+
+// SaveCallbackUrl persists the callback target next to the job inputs
+// at submission time; like the input files it survives server restarts.
+func SaveCallbackUrl(jobsbase string, id Id, callback string) error {
+	parsed, err := url.Parse(callback)
+	if err != nil {
+		return err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return ErrInvalidTicket
+	}
+	return os.WriteFile(filepath.Join(jobsbase, string(id), callbackFileName), []byte(callback), 0644)
+}
+
+// signCallback computes the hex HMAC-SHA256 of the payload; it is sent
+// in X-MsaServer-Signature so receivers can authenticate the delivery.
+func signCallback(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NotifyCallback posts the signed completion payload to the callback
+// URL stored for the job, if any. Deliveries are retried with a short
+// backoff; a persistently unreachable receiver is logged and dropped
+// rather than blocking the worker.
+func NotifyCallback(config ConfigCallback, jobsbase string, ticket Ticket) error {
+	if !config.Enabled {
+		return nil
+	}
+	raw, err := os.ReadFile(filepath.Join(jobsbase, string(ticket.Id), callbackFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	callback := strings.TrimSpace(string(raw))
+	payload := CallbackPayload{
+		Id:     ticket.Id,
+		Status: ticket.Status,
+		Stats:  ticket.Stats,
+	}
+	if ticket.Status == StatusComplete && config.BaseUrl != "" {
+		payload.ResultUrl = strings.TrimSuffix(config.BaseUrl, "/") + "/result/download/" + string(ticket.Id)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	retries := config.Retries
+	if retries <= 0 {
+		retries = 3
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	lastErr := errors.New("callback delivery failed")
+	for attempt := 0; attempt < retries; attempt++ {
+		request, err := http.NewRequest("POST", callback, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		request.Header.Set("Content-Type", "application/json")
+		if config.Secret != "" {
+			request.Header.Set("X-MsaServer-Signature", signCallback(config.Secret, body))
+		}
+		response, err := client.Do(request)
+		if err != nil {
+			lastErr = err
+		} else {
+			response.Body.Close()
+			if response.StatusCode < 300 {
+				return nil
+			}
+			lastErr = errors.New("callback receiver answered " + response.Status)
+		}
+		time.Sleep(time.Duration(attempt+1) * 5 * time.Second)
+	}
+	return lastErr
+}
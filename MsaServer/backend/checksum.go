@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterChecksumRoute adds GET /ticket/{ticket}/result/sha256, which
+// returns the archive's SHA256 so pipelines can verify a large download
+// before unpacking it. The hash is the same one used as the download
+// ETag, so it comes from the sidecar cache when available.
+func RegisterChecksumRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/result/sha256", func(w http.ResponseWriter, req *http.Request) {
+		id := Id(mux.Vars(req)["ticket"])
+		status, err := jobsystem.Status(id)
+		if err != nil || status == StatusUnknown {
+			http.Error(w, "unknown ticket", http.StatusNotFound)
+			return
+		}
+		if status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusConflict)
+			return
+		}
+		path := resultArchivePath(jobsbase, id)
+		if _, err := os.Stat(path); err != nil {
+			http.Error(w, "no result for ticket", http.StatusNotFound)
+			return
+		}
+		checksum, err := resultEtag(path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"id":     string(id),
+			"sha256": checksum,
+		})
+	}).Methods("GET")
+}
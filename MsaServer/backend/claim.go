@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+var ErrAlreadyClaimed = errors.New("job already claimed by another worker")
+
+// Claim is the content of a job's claim file. Workers sharing
+// Paths.Results over NFS use it for mutual exclusion: the O_EXCL create
+// of the claim file is the atomic claim, and the fencing token lets
+// stale workers detect that their claim was taken over.
+type Claim struct {
+	Worker string    `json:"worker"`
+	Token  uint64    `json:"token"`
+	At     time.Time `json:"at"`
+}
+
+const claimFileName = "job.claim"
+
+// claimStaleAfter is how long a claim may go without a heartbeat before
+// another worker may take it over, e.g. after a worker host died.
+const claimStaleAfter = 10 * time.Minute
+
+func claimPath(jobsbase string, id Id) string {
+	return filepath.Join(jobsbase, string(id), claimFileName)
+}
+
+func writeClaim(path string, claim Claim, flags int) error {
+	file, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return err
+	}
+	err = json.NewEncoder(file).Encode(claim)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ClaimJob atomically claims the job directory for the given worker and
+// returns a fencing token that is strictly larger than any token of a
+// previous claim on this job. It returns ErrAlreadyClaimed if another
+// worker holds a live claim.
+func ClaimJob(jobsbase string, id Id, worker string) (uint64, error) {
+	path := claimPath(jobsbase, id)
+	token := uint64(time.Now().UnixNano())
+	claim := Claim{Worker: worker, Token: token, At: time.Now()}
+	err := writeClaim(path, claim, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err == nil {
+		return token, nil
+	}
+	if !os.IsExist(err) {
+		return 0, err
+	}
+	existing, readErr := ReadClaim(jobsbase, id)
+	if readErr != nil {
+		return 0, readErr
+	}
+	if time.Since(existing.At) < claimStaleAfter {
+		return 0, ErrAlreadyClaimed
+	}
+	// take over a stale claim with a strictly larger fencing token; the
+	// rename makes the takeover atomic for concurrent claimants
+	if token <= existing.Token {
+		token = existing.Token + 1
+	}
+	claim.Token = token
+	temporary := path + "." + worker + "." + strconv.FormatUint(token, 10)
+	if err := writeClaim(temporary, claim, os.O_WRONLY|os.O_CREATE|os.O_EXCL); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(temporary, path); err != nil {
+		os.Remove(temporary)
+		return 0, err
+	}
+	return token, nil
+}
+
+// RenewClaim refreshes the heartbeat of a held claim. It fails if the
+// claim was taken over by another worker, in which case the caller must
+// stop writing to the job directory.
+func RenewClaim(jobsbase string, id Id, worker string, token uint64) error {
+	existing, err := ReadClaim(jobsbase, id)
+	if err != nil {
+		return err
+	}
+	if existing.Worker != worker || existing.Token != token {
+		return ErrAlreadyClaimed
+	}
+	return writeClaim(claimPath(jobsbase, id), Claim{
+		Worker: worker,
+		Token:  token,
+		At:     time.Now(),
+	}, os.O_WRONLY|os.O_TRUNC)
+}
+
+// ReleaseClaim removes the claim file if it is still held with the given
+// token; a foreign claim is left untouched.
+func ReleaseClaim(jobsbase string, id Id, worker string, token uint64) error {
+	existing, err := ReadClaim(jobsbase, id)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if existing.Worker != worker || existing.Token != token {
+		return nil
+	}
+	return os.Remove(claimPath(jobsbase, id))
+}
+
+func ReadClaim(jobsbase string, id Id) (Claim, error) {
+	var claim Claim
+	file, err := os.Open(claimPath(jobsbase, id))
+	if err != nil {
+		return claim, err
+	}
+	defer file.Close()
+	err = json.NewDecoder(file).Decode(&claim)
+	return claim, err
+}
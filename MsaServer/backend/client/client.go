@@ -0,0 +1,273 @@
+// Package client is the official Go SDK for the ColabFold MSA server.
+// It wraps submission, polling, server-sent-event streaming and result
+// download, including the fork-specific taxonomy and pair endpoints, so
+// other Go services can talk to the server without hand-rolled HTTP.
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Status mirrors the server-side ticket status values.
+type Status string
+
+const (
+	StatusPending    Status = "PENDING"
+	StatusRunning    Status = "RUNNING"
+	StatusComplete   Status = "COMPLETE"
+	StatusError      Status = "ERROR"
+	StatusUnknown    Status = "UNKNOWN"
+	StatusDeadLetter Status = "DEADLETTER"
+	StatusExpired    Status = "EXPIRED"
+	StatusCancelled  Status = "CANCELLED"
+)
+
+// IsTerminal reports whether the status will never change again.
+func (s Status) IsTerminal() bool {
+	switch s {
+	case StatusComplete, StatusError, StatusExpired, StatusCancelled, StatusDeadLetter:
+		return true
+	}
+	return false
+}
+
+// Ticket is the server's ticket representation.
+type Ticket struct {
+	Id     string `json:"id"`
+	Status Status `json:"status"`
+}
+
+// ErrJobFailed is returned by Wait when the job reaches a terminal
+// status other than COMPLETE.
+type ErrJobFailed struct {
+	Ticket Ticket
+}
+
+func (e *ErrJobFailed) Error() string {
+	return fmt.Sprintf("job %s finished with status %s", e.Ticket.Id, e.Ticket.Status)
+}
+
+// Client talks to one MSA server. The zero value is not usable; create
+// clients with New.
+type Client struct {
+	baseUrl string
+	apiKey  string
+	http    *http.Client
+	// PollInterval is used by Wait when the event stream is not
+	// available.
+	PollInterval time.Duration
+}
+
+// Option customizes a Client.
+type Option func(*Client)
+
+// WithApiKey sends the key in X-API-Key on every request.
+func WithApiKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithHTTPClient replaces the default http.Client.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.http = httpClient }
+}
+
+func New(baseUrl string, options ...Option) *Client {
+	client := &Client{
+		baseUrl:      strings.TrimSuffix(baseUrl, "/"),
+		http:         &http.Client{Timeout: 30 * time.Second},
+		PollInterval: 3 * time.Second,
+	}
+	for _, option := range options {
+		option(client)
+	}
+	return client
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	response, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode >= 400 {
+		defer response.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 4096))
+		return nil, fmt.Errorf("server answered %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return response, nil
+}
+
+func (c *Client) postForm(ctx context.Context, path string, values url.Values) (Ticket, error) {
+	var ticket Ticket
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseUrl+path, strings.NewReader(values.Encode()))
+	if err != nil {
+		return ticket, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := c.do(req)
+	if err != nil {
+		return ticket, err
+	}
+	defer response.Body.Close()
+	err = json.NewDecoder(response.Body).Decode(&ticket)
+	return ticket, err
+}
+
+// SubmitMSA submits a FASTA query for MSA generation. mode is the
+// server mode string (e.g. "env-nofilter"); taxonomy adds taxid
+// annotations to the output.
+func (c *Client) SubmitMSA(ctx context.Context, fasta string, mode string, taxonomy bool) (Ticket, error) {
+	if taxonomy && !strings.Contains(mode, "taxonomy") {
+		if mode != "" {
+			mode += "-"
+		}
+		mode += "taxonomy"
+	}
+	return c.postForm(ctx, "/ticket/msa", url.Values{"q": {fasta}, "mode": {mode}})
+}
+
+// SubmitPair submits a paired-MSA job for a protein complex.
+func (c *Client) SubmitPair(ctx context.Context, fasta string, mode string) (Ticket, error) {
+	return c.postForm(ctx, "/ticket/pair", url.Values{"q": {fasta}, "mode": {mode}})
+}
+
+// SubmitStructureSearch submits a PDB structure for a foldseek search.
+func (c *Client) SubmitStructureSearch(ctx context.Context, pdb string, mode string) (Ticket, error) {
+	return c.postForm(ctx, "/ticket/structuresearch", url.Values{"q": {pdb}, "mode": {mode}})
+}
+
+// TicketStatus fetches the current status of a ticket.
+func (c *Client) TicketStatus(ctx context.Context, id string) (Ticket, error) {
+	var ticket Ticket
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseUrl+"/ticket/"+id, nil)
+	if err != nil {
+		return ticket, err
+	}
+	response, err := c.do(req)
+	if err != nil {
+		return ticket, err
+	}
+	defer response.Body.Close()
+	err = json.NewDecoder(response.Body).Decode(&ticket)
+	return ticket, err
+}
+
+// Watch streams status changes over the server-sent-events endpoint,
+// invoking handle for each update until the ticket is terminal or the
+// context is cancelled.
+func (c *Client) Watch(ctx context.Context, id string, handle func(Ticket)) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseUrl+"/ticket/"+id+"/events", nil)
+	if err != nil {
+		return err
+	}
+	streaming := *c.http
+	streaming.Timeout = 0
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	response, err := streaming.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		return fmt.Errorf("server answered %s", response.Status)
+	}
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var ticket Ticket
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &ticket); err != nil {
+			continue
+		}
+		handle(ticket)
+		if ticket.Status.IsTerminal() || ticket.Status == StatusUnknown {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Wait polls until the ticket is terminal and returns the final ticket;
+// a non-COMPLETE outcome is reported as *ErrJobFailed.
+func (c *Client) Wait(ctx context.Context, id string) (Ticket, error) {
+	for {
+		ticket, err := c.TicketStatus(ctx, id)
+		if err != nil {
+			return ticket, err
+		}
+		if ticket.Status == StatusComplete {
+			return ticket, nil
+		}
+		if ticket.Status.IsTerminal() || ticket.Status == StatusUnknown {
+			return ticket, &ErrJobFailed{Ticket: ticket}
+		}
+		select {
+		case <-ctx.Done():
+			return ticket, ctx.Err()
+		case <-time.After(c.PollInterval):
+		}
+	}
+}
+
+// Download writes the result archive of a completed ticket to path.
+func (c *Client) Download(ctx context.Context, id string, path string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseUrl+"/result/download/"+id, nil)
+	if err != nil {
+		return err
+	}
+	streaming := *c.http
+	streaming.Timeout = 0
+	if c.apiKey != "" {
+		req.Header.Set("X-API-Key", c.apiKey)
+	}
+	response, err := streaming.Do(req)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= 400 {
+		return errors.New("server answered " + response.Status)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, response.Body); err != nil {
+		file.Close()
+		os.Remove(path)
+		return err
+	}
+	return file.Close()
+}
+
+// ResultFile fetches a single file from the result directory via the
+// per-file endpoint.
+func (c *Client) ResultFile(ctx context.Context, id string, name string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseUrl+"/ticket/"+id+"/file/"+url.PathEscape(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	return io.ReadAll(response.Body)
+}
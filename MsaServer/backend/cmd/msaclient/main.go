@@ -0,0 +1,151 @@
+// msaclient submits FASTA or PDB files to a ColabFold MSA server,
+// waits for completion with a progress display and unpacks the result
+// locally. It covers the common scripting case without HTTP glue:
+//
+//	msaclient -server http://localhost:8000 query.fasta
+//	msaclient -type structuresearch -out results/ structure.pdb
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"msaserver/backend/client"
+)
+
+func fatal(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, "msaclient: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+func main() {
+	server := flag.String("server", "http://localhost:8000", "MSA server base URL")
+	apiKey := flag.String("api-key", os.Getenv("MSA_API_KEY"), "API key (defaults to $MSA_API_KEY)")
+	jobType := flag.String("type", "msa", "job type: msa, pair or structuresearch")
+	mode := flag.String("mode", "env-nofilter", "server mode string")
+	taxonomy := flag.Bool("taxonomy", false, "request taxid annotations (msa jobs)")
+	out := flag.String("out", ".", "directory to unpack results into")
+	keepArchive := flag.Bool("keep-archive", false, "keep the downloaded tar.gz next to the unpacked files")
+	timeout := flag.Duration("timeout", 2*time.Hour, "overall deadline for the job")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "usage: msaclient [flags] <query file>")
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	query, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fatal("cannot read query: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	c := client.New(*server, client.WithApiKey(*apiKey))
+
+	var ticket client.Ticket
+	switch *jobType {
+	case "msa":
+		ticket, err = c.SubmitMSA(ctx, string(query), *mode, *taxonomy)
+	case "pair":
+		ticket, err = c.SubmitPair(ctx, string(query), *mode)
+	case "structuresearch":
+		ticket, err = c.SubmitStructureSearch(ctx, string(query), *mode)
+	default:
+		fatal("unknown job type %q", *jobType)
+	}
+	if err != nil {
+		fatal("submission failed: %v", err)
+	}
+	fmt.Printf("ticket %s\n", ticket.Id)
+
+	started := time.Now()
+	err = c.Watch(ctx, ticket.Id, func(update client.Ticket) {
+		fmt.Printf("[%6.0fs] %s\n", time.Since(started).Seconds(), update.Status)
+		ticket = update
+	})
+	if err != nil {
+		// the event stream is optional on older servers; fall back to
+		// plain polling
+		ticket, err = c.Wait(ctx, ticket.Id)
+	}
+	if err != nil {
+		fatal("%v", err)
+	}
+	if ticket.Status != client.StatusComplete {
+		fatal("job finished with status %s", ticket.Status)
+	}
+
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		fatal("%v", err)
+	}
+	archive := filepath.Join(*out, "mmseqs_results_"+ticket.Id+".tar.gz")
+	if err := c.Download(ctx, ticket.Id, archive); err != nil {
+		fatal("download failed: %v", err)
+	}
+	if err := unpack(archive, *out); err != nil {
+		fatal("unpack failed: %v", err)
+	}
+	if !*keepArchive {
+		os.Remove(archive)
+	}
+	fmt.Printf("results in %s\n", *out)
+}
+
+// unpack extracts a tar.gz into directory, refusing entries that would
+// escape it.
+func unpack(archive string, directory string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer decompressed.Close()
+	reader := tar.NewReader(decompressed)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name := filepath.Clean(header.Name)
+		if strings.HasPrefix(name, "..") || filepath.IsAbs(name) {
+			return fmt.Errorf("archive entry %q escapes the output directory", header.Name)
+		}
+		target := filepath.Join(directory, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.FileMode(header.Mode)&0777)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, reader); err != nil {
+				out.Close()
+				return err
+			}
+			out.Close()
+		}
+	}
+}
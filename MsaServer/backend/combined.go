@@ -0,0 +1,200 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const parentFileName = "parent.json"
+
+// ParentTicket groups the child tickets of a combined submission; it is
+// persisted in the parent's job directory so aggregation survives
+// restarts.
+type ParentTicket struct {
+	Id       Id        `json:"id"`
+	Children []Id      `json:"children"`
+	Created  time.Time `json:"created"`
+}
+
+// CombinedStatus is the aggregated view over all children.
+type CombinedStatus struct {
+	Id       Id       `json:"id"`
+	Status   Status   `json:"status"`
+	Children []Ticket `json:"children"`
+}
+
+// aggregateStatus folds child statuses into one: any ERROR-like child
+// makes the parent ERROR, any unfinished child keeps it RUNNING or
+// PENDING, and only all-COMPLETE children yield COMPLETE.
+func aggregateStatus(children []Ticket) Status {
+	status := StatusComplete
+	for _, child := range children {
+		switch child.Status {
+		case StatusError, StatusDeadLetter, StatusExpired, StatusCancelled:
+			return StatusError
+		case StatusRunning:
+			status = StatusRunning
+		case StatusPending:
+			if status != StatusRunning {
+				status = StatusPending
+			}
+		case StatusUnknown:
+			return StatusUnknown
+		}
+	}
+	return status
+}
+
+func readParentTicket(jobsbase string, id Id) (ParentTicket, error) {
+	var parent ParentTicket
+	raw, err := os.ReadFile(filepath.Join(jobsbase, string(id), parentFileName))
+	if err != nil {
+		return parent, err
+	}
+	err = json.Unmarshal(raw, &parent)
+	return parent, err
+}
+
+// RegisterCombinedRoutes adds the combined sequence+structure flow:
+//
+//	POST /ticket/combined          multipart with q (FASTA) and structure (PDB)
+//	GET  /ticket/combined/{ticket} aggregated status with per-child tickets
+//	GET  /result/combined/{ticket} one tar.gz bundling both child archives
+//
+// One upload fans out into an MSA job and a structure search job under
+// a parent ticket, so complex-prediction clients make a single request.
+func RegisterCombinedRoutes(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/combined", func(w http.ResponseWriter, req *http.Request) {
+		query := req.FormValue("q")
+		structure := req.FormValue("structure")
+		if query == "" || structure == "" {
+			http.Error(w, "both q and structure are required", http.StatusBadRequest)
+			return
+		}
+		mode := req.FormValue("mode")
+		email := req.FormValue("email")
+		priority := ParsePriority(req.FormValue("priority"))
+		apiKey := req.Header.Get("X-API-Key")
+
+		hash := sha256.Sum256([]byte(query + "\x00" + structure + "\x00" + mode))
+		parentId := Id("c" + hex.EncodeToString(hash[:])[:31])
+
+		children := []JobRequest{
+			{
+				Type:     JobTypeMsa,
+				Job:      MsaJob{Mode: mode, Query: query},
+				Email:    email,
+				Priority: priority,
+				ApiKey:   apiKey,
+			},
+			{
+				Type:     JobTypeStructureSearch,
+				Job:      StructureSearchJob{Mode: mode, Query: structure},
+				Email:    email,
+				Priority: priority,
+				ApiKey:   apiKey,
+			},
+		}
+		parent := ParentTicket{Id: parentId, Created: time.Now()}
+		for index, child := range children {
+			payload, err := json.Marshal(child.Job)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			childHash := sha256.Sum256(append([]byte(child.Type), payload...))
+			child.Id = Id(hex.EncodeToString(childHash[:])[:32])
+			child.Origin = parentId
+			if _, err := jobsystem.NewJob(child, jobsbase, false); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			parent.Children = append(parent.Children, child.Id)
+			children[index] = child
+		}
+		parentBase := filepath.Join(jobsbase, string(parentId))
+		if err := os.MkdirAll(parentBase, 0755); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		record, err := json.Marshal(parent)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err := os.WriteFile(filepath.Join(parentBase, parentFileName), record, 0644); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(parent)
+	}).Methods("POST")
+	r.HandleFunc("/ticket/combined/{ticket}", func(w http.ResponseWriter, req *http.Request) {
+		parent, err := readParentTicket(jobsbase, Id(mux.Vars(req)["ticket"]))
+		if err != nil {
+			http.Error(w, "unknown combined ticket", http.StatusNotFound)
+			return
+		}
+		combined := CombinedStatus{Id: parent.Id}
+		for _, childId := range parent.Children {
+			child, err := jobsystem.GetTicket(childId)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			combined.Children = append(combined.Children, child)
+		}
+		combined.Status = aggregateStatus(combined.Children)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(combined)
+	}).Methods("GET")
+	r.HandleFunc("/result/combined/{ticket}", func(w http.ResponseWriter, req *http.Request) {
+		parent, err := readParentTicket(jobsbase, Id(mux.Vars(req)["ticket"]))
+		if err != nil {
+			http.Error(w, "unknown combined ticket", http.StatusNotFound)
+			return
+		}
+		for _, childId := range parent.Children {
+			status, err := jobsystem.Status(childId)
+			if err != nil || status != StatusComplete {
+				http.Error(w, "combined job is not complete", http.StatusConflict)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"mmseqs_results_"+string(parent.Id)+".tar.gz\"")
+		compressed := gzip.NewWriter(w)
+		defer compressed.Close()
+		archive := tar.NewWriter(compressed)
+		defer archive.Close()
+		for _, childId := range parent.Children {
+			path := filepath.Join(jobsbase, string(childId), "mmseqs_results_"+string(childId)+".tar.gz")
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			file, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			archive.WriteHeader(&tar.Header{
+				Name:    filepath.Base(path),
+				Mode:    0644,
+				Size:    info.Size(),
+				ModTime: info.ModTime(),
+			})
+			io.Copy(archive, file)
+			file.Close()
+		}
+	}).Methods("GET")
+}
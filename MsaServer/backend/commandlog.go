@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// commandLogName is included in the result archive so every result
+// carries the exact commands that produced it.
+const commandLogName = "commands.log"
+
+// CommandLog appends an audit trail of executed commands to the job's
+// result directory: command line, exit code, wall time and tool
+// versions — enough to reproduce the result exactly later.
+type CommandLog struct {
+	path string
+}
+
+func OpenCommandLog(jobsbase string, id Id) *CommandLog {
+	return &CommandLog{path: filepath.Join(jobsbase, string(id), commandLogName)}
+}
+
+func (l *CommandLog) append(line string) error {
+	file, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = fmt.Fprintf(file, "[%s] %s\n", time.Now().UTC().Format(time.RFC3339), line)
+	return err
+}
+
+// RecordVersion runs `<tool> version` and logs its output, so the
+// binary versions used for the job are part of the record.
+func (l *CommandLog) RecordVersion(tool string) error {
+	output, err := exec.Command(tool, "version").Output()
+	version := strings.TrimSpace(string(output))
+	if err != nil {
+		version = "unknown (" + err.Error() + ")"
+	}
+	return l.append(fmt.Sprintf("version %s: %s", tool, version))
+}
+
+// Run executes the command with output streams attached by the caller,
+// logging the command line, exit code and wall time. The command's
+// error is returned unchanged so worker error handling stays the same.
+func (l *CommandLog) Run(cmd *exec.Cmd) error {
+	line := strings.Join(cmd.Args, " ")
+	started := time.Now()
+	runErr := cmd.Run()
+	elapsed := time.Since(started)
+	exitCode := 0
+	if cmd.ProcessState != nil {
+		exitCode = cmd.ProcessState.ExitCode()
+	}
+	if err := l.append(fmt.Sprintf("run (exit %d, %.1fs): %s", exitCode, elapsed.Seconds(), line)); err != nil {
+		return err
+	}
+	return runErr
+}
+
+// Note records a free-form line, e.g. which database version a search
+// resolved to.
+func (l *CommandLog) Note(format string, args ...interface{}) error {
+	return l.append(fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigPaths groups the filesystem layout: where databases live, where
+// job directories are created and which search binaries to use.
+type ConfigPaths struct {
+	// Databases accepts a single directory or a list of roots; the
+	// first root is where new databases are installed.
+	Databases DbRoots `json:"databases"`
+	// Results is the job directory base (jobsbase); one subdirectory
+	// per ticket.
+	Results string `json:"results"`
+	// Temporary holds scratch space for running jobs.
+	Temporary string `json:"temporary"`
+	Mmseqs    string `json:"mmseqs"`
+	Foldseek  string `json:"foldseek,omitempty"`
+	// Search names the default databases of the ColabFold MSA
+	// pipeline, used when a submission does not pin databases itself.
+	Search ConfigSearchDatabases `json:"search"`
+}
+
+// ConfigSearchDatabases are the default pipeline targets, matching the
+// roles in setup_databases.sh.
+type ConfigSearchDatabases struct {
+	Uniref        string `json:"uniref"`
+	Environmental string `json:"environmental"`
+	Pdb           string `json:"pdb"`
+	// Structure is the default foldseek target of v1 structure
+	// searches that do not pin a database.
+	Structure string `json:"structure,omitempty"`
+}
+
+type ConfigServer struct {
+	// Address is the listen address of the REST API, e.g. ":8000".
+	Address string `json:"address"`
+}
+
+// ConfigWorker controls the in-process job execution loop. Deployments
+// that split API and compute nodes disable the worker on the API side.
+type ConfigWorker struct {
+	Enabled bool `json:"enabled"`
+	// Name identifies this worker in claims, heartbeats and the ticket
+	// history; it defaults to the hostname.
+	Name string `json:"name,omitempty"`
+	// Workers is how many jobs run concurrently.
+	Workers int `json:"workers,omitempty"`
+	// Threads is passed to mmseqs --threads; zero lets mmseqs decide.
+	Threads int `json:"threads,omitempty"`
+	// PollSeconds is the dequeue poll interval when the queue is empty.
+	PollSeconds int64 `json:"pollseconds,omitempty"`
+	// VisibilitySeconds is how long a RUNNING ticket may go without a
+	// heartbeat before the dead-worker sweep requeues it.
+	VisibilitySeconds int64 `json:"visibilityseconds,omitempty"`
+}
+
+// ConfigBackend selects the job system implementation and an optional
+// external queue in front of it.
+type ConfigBackend struct {
+	// Type is "bolt" (default) or "postgres".
+	Type string `json:"type,omitempty"`
+	// Path is the bolt database file; it defaults to jobsystem.db in
+	// the temporary directory.
+	Path     string         `json:"path,omitempty"`
+	Postgres ConfigPostgres `json:"postgres"`
+	// Queue is empty for the built-in queue, "sqs" or "pubsub".
+	Queue  string       `json:"queue,omitempty"`
+	Sqs    ConfigSqs    `json:"sqs"`
+	PubSub ConfigPubSub `json:"pubsub"`
+	// StartPaused brings the server up without dequeuing, so operators
+	// can verify a new deployment before it takes work.
+	StartPaused bool `json:"startpaused,omitempty"`
+}
+
+// ConfigRoot is the whole server configuration, read from one JSON file
+// with one section per feature; absent sections leave the feature
+// disabled or on its defaults.
+type ConfigRoot struct {
+	Verbose       bool                `json:"verbose"`
+	Server        ConfigServer        `json:"server"`
+	Paths         ConfigPaths         `json:"paths"`
+	Backend       ConfigBackend       `json:"backend"`
+	Worker        ConfigWorker        `json:"worker"`
+	Admin         ConfigAdmin         `json:"admin"`
+	Auth          ConfigAuth          `json:"auth"`
+	Oidc          ConfigOidc          `json:"oidc"`
+	Cors          ConfigCors          `json:"cors"`
+	RateLimit     ConfigRateLimit     `json:"ratelimit"`
+	Quotas        ConfigQuotas        `json:"quotas"`
+	Backpressure  ConfigBackpressure  `json:"backpressure"`
+	DeadLetter    ConfigDeadLetter    `json:"deadletter"`
+	Archival      ConfigArchival      `json:"archival"`
+	Retention     ConfigRetention     `json:"retention"`
+	Callback      ConfigCallback      `json:"callback"`
+	ObjectStore   ConfigObjectStore   `json:"objectstore"`
+	ResultArchive ConfigResultArchive `json:"resultarchive"`
+	Upload        ConfigUpload        `json:"upload"`
+	Grpc          ConfigGrpc          `json:"grpc"`
+	DbSetup       ConfigDbSetup       `json:"dbsetup"`
+	DbVersions    ConfigDbVersions    `json:"dbversions"`
+	DbAcl         ConfigDbAcl         `json:"dbacl"`
+	UserDb        ConfigUserDb        `json:"userdb"`
+	Reindex       ConfigReindex       `json:"reindex"`
+	Warmup        ConfigWarmup        `json:"warmup"`
+	Taxdump       ConfigTaxdump       `json:"taxdump"`
+	Staging       ConfigStaging       `json:"staging"`
+	Shards        ConfigShards        `json:"shards"`
+	Templates     ConfigTemplates     `json:"templates"`
+	IndexSplit    ConfigIndexSplit    `json:"indexsplit"`
+	MirrorSync    ConfigMirrorSync    `json:"mirrorsync"`
+}
+
+// stripJsonComments removes // line comments outside of strings, so the
+// config file can be annotated like MsaServer/config.json.
+func stripJsonComments(raw []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	escaped := false
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if inString {
+			out.WriteByte(c)
+			if escaped {
+				escaped = false
+			} else if c == '\\' {
+				escaped = true
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		if c == '"' {
+			inString = true
+			out.WriteByte(c)
+			continue
+		}
+		if c == '/' && i+1 < len(raw) && raw[i+1] == '/' {
+			for i < len(raw) && raw[i] != '\n' {
+				i++
+			}
+			if i < len(raw) {
+				out.WriteByte('\n')
+			}
+			continue
+		}
+		out.WriteByte(c)
+	}
+	return out.Bytes()
+}
+
+// expandPath resolves a leading ~ against the home directory, so the
+// config can use the same shorthand as setup-and-start-local.sh.
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return path
+		}
+		return filepath.Join(home, strings.TrimPrefix(path, "~"))
+	}
+	return path
+}
+
+func (c *ConfigRoot) expandPaths() {
+	for i := range c.Paths.Databases {
+		c.Paths.Databases[i] = expandPath(c.Paths.Databases[i])
+	}
+	c.Paths.Results = expandPath(c.Paths.Results)
+	c.Paths.Temporary = expandPath(c.Paths.Temporary)
+	c.Paths.Mmseqs = expandPath(c.Paths.Mmseqs)
+	c.Paths.Foldseek = expandPath(c.Paths.Foldseek)
+	c.Backend.Path = expandPath(c.Backend.Path)
+	c.Upload.Directory = expandPath(c.Upload.Directory)
+	c.Archival.Directory = expandPath(c.Archival.Directory)
+	c.DbSetup.Path = expandPath(c.DbSetup.Path)
+	c.Taxdump.Path = expandPath(c.Taxdump.Path)
+	c.Staging.Path = expandPath(c.Staging.Path)
+	c.Templates.MirrorPath = expandPath(c.Templates.MirrorPath)
+	c.Templates.CachePath = expandPath(c.Templates.CachePath)
+	c.Auth.KeyFile = expandPath(c.Auth.KeyFile)
+}
+
+// applyDefaults fills the values most deployments never need to set.
+func (c *ConfigRoot) applyDefaults() {
+	if c.Server.Address == "" {
+		c.Server.Address = "127.0.0.1:8000"
+	}
+	if c.Paths.Mmseqs == "" {
+		c.Paths.Mmseqs = "mmseqs"
+	}
+	if c.Paths.Foldseek == "" {
+		c.Paths.Foldseek = "foldseek"
+	}
+	if c.Backend.Type == "" {
+		c.Backend.Type = "bolt"
+	}
+	if c.Backend.Path == "" {
+		c.Backend.Path = filepath.Join(c.Paths.Temporary, "jobsystem.db")
+	}
+	if c.Worker.Name == "" {
+		if hostname, err := os.Hostname(); err == nil {
+			c.Worker.Name = hostname
+		} else {
+			c.Worker.Name = "worker"
+		}
+	}
+	if c.Worker.Workers <= 0 {
+		c.Worker.Workers = 1
+	}
+	if c.Worker.PollSeconds <= 0 {
+		c.Worker.PollSeconds = 1
+	}
+	if c.Worker.VisibilitySeconds <= 0 {
+		c.Worker.VisibilitySeconds = 600
+	}
+	if c.DbSetup.Path == "" {
+		c.DbSetup.Path = c.Paths.Databases.Primary()
+	}
+	if c.DbSetup.Mmseqs == "" {
+		c.DbSetup.Mmseqs = c.Paths.Mmseqs
+	}
+	if c.UserDb.Mmseqs == "" {
+		c.UserDb.Mmseqs = c.Paths.Mmseqs
+	}
+	if c.Warmup.Mmseqs == "" {
+		c.Warmup.Mmseqs = c.Paths.Mmseqs
+	}
+	if c.Upload.Directory == "" {
+		c.Upload.Directory = filepath.Join(c.Paths.Temporary, "uploads")
+	}
+	if c.Auth.KeyFile == "" {
+		c.Auth.KeyFile = filepath.Join(c.Paths.Temporary, "apikeys.json")
+	}
+}
+
+// ReadConfig loads and prepares the server configuration.
+func ReadConfig(path string) (ConfigRoot, error) {
+	var config ConfigRoot
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(stripJsonComments(raw), &config); err != nil {
+		return config, err
+	}
+	config.expandPaths()
+	config.applyDefaults()
+	return config, nil
+}
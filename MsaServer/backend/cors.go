@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+type ConfigCors struct {
+	Enabled bool `json:"enabled"`
+	// AllowedOrigins lists exact origins; "*" allows everything (not
+	// combinable with credentials).
+	AllowedOrigins []string `json:"allowedorigins"`
+	// AllowedMethods and AllowedHeaders have sensible defaults for
+	// this API when left empty.
+	AllowedMethods   []string `json:"allowedmethods"`
+	AllowedHeaders   []string `json:"allowedheaders"`
+	AllowCredentials bool     `json:"allowcredentials"`
+	// MaxAgeSeconds caches the preflight response in the browser.
+	MaxAgeSeconds int `json:"maxageseconds"`
+}
+
+func (c *ConfigCors) originAllowed(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// CorsMiddleware applies the configured CORS policy so web frontends on
+// other domains can talk to the backend without a reverse-proxy
+// workaround. With CORS disabled requests pass through untouched.
+func CorsMiddleware(config ConfigCors) func(http.Handler) http.Handler {
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD"}
+	}
+	headers := config.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", "X-API-Key", "Authorization", "Idempotency-Key", "Upload-Length", "Upload-Offset", "Upload-Filename"}
+	}
+	methodList := strings.Join(methods, ", ")
+	headerList := strings.Join(headers, ", ")
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			origin := req.Header.Get("Origin")
+			if !config.Enabled || origin == "" || !config.originAllowed(origin) {
+				next.ServeHTTP(w, req)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Add("Vary", "Origin")
+			if config.AllowCredentials {
+				w.Header().Set("Access-Control-Allow-Credentials", "true")
+			}
+			if req.Method == http.MethodOptions && req.Header.Get("Access-Control-Request-Method") != "" {
+				w.Header().Set("Access-Control-Allow-Methods", methodList)
+				w.Header().Set("Access-Control-Allow-Headers", headerList)
+				if config.MaxAgeSeconds > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(config.MaxAgeSeconds))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, req)
+		})
+	}
+}
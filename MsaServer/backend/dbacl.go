@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+)
+
+// ConfigDbAcl restricts specific databases to particular API keys, for
+// proprietary internal collections served next to the public ones.
+// Databases not listed stay open to everyone.
+type ConfigDbAcl struct {
+	Enabled bool `json:"enabled"`
+	// Restricted maps a database name to the API key names (as
+	// registered in the key store) allowed to search it.
+	Restricted map[string][]string `json:"restricted,omitempty"`
+}
+
+// DbAcl enforces the per-database restrictions at submission time.
+type DbAcl struct {
+	config ConfigDbAcl
+	store  *ApiKeyStore
+}
+
+func NewDbAcl(config ConfigDbAcl, store *ApiKeyStore) *DbAcl {
+	if !config.Enabled {
+		return nil
+	}
+	return &DbAcl{config: config, store: store}
+}
+
+// keyName resolves an API key to its registered name; unregistered
+// keys resolve to the empty string and can only use open databases.
+func (a *DbAcl) keyName(apiKey string) string {
+	if a.store == nil || apiKey == "" {
+		return ""
+	}
+	return a.store.NameOf(apiKey)
+}
+
+// Authorize rejects submissions targeting a restricted database the
+// key is not cleared for; the error names the database so the client
+// knows which part of the request to fix.
+func (a *DbAcl) Authorize(apiKey string, databases []string) error {
+	if a == nil {
+		return nil
+	}
+	name := a.keyName(apiKey)
+	for _, database := range databases {
+		allowed, restricted := a.config.Restricted[database]
+		if !restricted {
+			continue
+		}
+		cleared := false
+		for _, entry := range allowed {
+			if entry == name && name != "" {
+				cleared = true
+				break
+			}
+		}
+		if !cleared {
+			return fmt.Errorf("database %s requires authorization your API key does not have", database)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DatabaseInfo is the public metadata of one installed database, so
+// clients can introspect what the server offers before submitting.
+type DatabaseInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	Kind    string `json:"kind"`
+	// SizeBytes is the on-disk footprint of all database files.
+	SizeBytes int64 `json:"size"`
+	// Indexed reports whether a precomputed index exists.
+	Indexed bool `json:"indexed"`
+	// Taxonomy reports whether taxid mapping files are present.
+	Taxonomy bool `json:"taxonomy"`
+	// EstimatedMemoryBytes is what a search is expected to need
+	// resident, derived from the index size.
+	EstimatedMemoryBytes int64 `json:"estimated_memory"`
+	// LastIndexStatus and LastIndexed describe the most recent
+	// IndexJob that targeted this database, if any.
+	LastIndexStatus Status    `json:"last_index_status,omitempty"`
+	LastIndexed     time.Time `json:"last_indexed,omitempty"`
+	// Display is the registry metadata for frontend pickers.
+	Display *DbDisplay `json:"display,omitempty"`
+	// DefaultParams are the structured pipeline defaults, so clients
+	// can show what a search against this database will use.
+	DefaultParams *DbPipelineParams `json:"default_params,omitempty"`
+}
+
+// databaseVersionPattern extracts the version suffix of the standard
+// naming scheme, e.g. uniref30_2302.
+var databaseVersionPattern = regexp.MustCompile(`_([0-9]{4,8})$`)
+
+// discoverDatabases finds the database base names under all roots,
+// keyed to the root that holds them.
+func discoverDatabases(roots DbRoots) map[string]string {
+	found := make(map[string]string)
+	for _, root := range roots {
+		matches, err := filepath.Glob(filepath.Join(root, "*.dbtype"))
+		if err != nil {
+			continue
+		}
+		for _, match := range matches {
+			name := strings.TrimSuffix(filepath.Base(match), ".dbtype")
+			// index and header companion databases are not targets
+			if strings.HasSuffix(name, ".idx") || strings.HasSuffix(name, "_h") || strings.HasSuffix(name, "_seq") || strings.HasSuffix(name, "_aln") {
+				continue
+			}
+			if _, seen := found[name]; !seen {
+				found[name] = root
+			}
+		}
+	}
+	return found
+}
+
+// databaseInfo assembles the metadata of one database.
+func databaseInfo(root string, name string, lastIndex map[string]AdminTicket) DatabaseInfo {
+	info := DatabaseInfo{Name: name, Kind: "sequence"}
+	if params, err := ReadDbParams(root, name); err == nil {
+		if params.Kind != "" {
+			info.Kind = params.Kind
+		}
+		info.Taxonomy = params.Taxonomy
+		info.Version = params.Version
+		info.Display = params.Display
+		info.DefaultParams = params.Pipeline
+	}
+	if info.Version == "" {
+		if match := databaseVersionPattern.FindStringSubmatch(strings.TrimSuffix(name, "_db")); match != nil {
+			info.Version = match[1]
+		}
+	}
+	matches, _ := filepath.Glob(filepath.Join(root, name+"*"))
+	for _, match := range matches {
+		stat, err := os.Stat(match)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+		info.SizeBytes += stat.Size()
+		base := filepath.Base(match)
+		if strings.Contains(base, ".idx") {
+			info.Indexed = true
+		}
+		if strings.HasSuffix(base, "_mapping") || strings.HasSuffix(base, "_taxonomy") {
+			info.Taxonomy = true
+		}
+	}
+	info.EstimatedMemoryBytes = databaseIndexSize(root, name)
+	if ticket, found := lastIndex[name]; found {
+		info.LastIndexStatus = ticket.Status
+		info.LastIndexed = ticket.Created
+	}
+	return info
+}
+
+// lastIndexJobs maps database names to the most recent IndexJob ticket
+// that targeted them.
+func lastIndexJobs(jobsystem JobSystem) map[string]AdminTicket {
+	admin, ok := UnwrapJobSystem(jobsystem).(AdminSystem)
+	if !ok {
+		return nil
+	}
+	tickets, err := admin.ListTickets(nil)
+	if err != nil {
+		return nil
+	}
+	last := make(map[string]AdminTicket)
+	for _, ticket := range tickets {
+		if ticket.Type != JobTypeIndex {
+			continue
+		}
+		for _, database := range ticket.Databases {
+			if previous, found := last[database]; !found || ticket.Created.After(previous.Created) {
+				last[database] = ticket
+			}
+		}
+	}
+	return last
+}
+
+// ListDatabases collects the metadata of every installed database.
+func ListDatabases(roots DbRoots, jobsystem JobSystem) []DatabaseInfo {
+	lastIndex := lastIndexJobs(jobsystem)
+	discovered := discoverDatabases(roots)
+	infos := make([]DatabaseInfo, 0, len(discovered))
+	for name, root := range discovered {
+		infos = append(infos, databaseInfo(root, name, lastIndex))
+	}
+	order := func(info DatabaseInfo) int {
+		if info.Display == nil || info.Display.Order == 0 {
+			return int(^uint(0) >> 1)
+		}
+		return info.Display.Order
+	}
+	sort.Slice(infos, func(a, b int) bool {
+		if order(infos[a]) != order(infos[b]) {
+			return order(infos[a]) < order(infos[b])
+		}
+		return infos[a].Name < infos[b].Name
+	})
+	return infos
+}
+
+// RegisterDatabasesRoute adds GET /databases, the public database
+// health and metadata listing. User databases stay hidden: they only
+// concern their owner.
+func RegisterDatabasesRoute(r *mux.Router, roots DbRoots, jobsystem JobSystem) {
+	r.HandleFunc("/databases", func(w http.ResponseWriter, req *http.Request) {
+		infos := ListDatabases(roots, jobsystem)
+		filtered := infos[:0]
+		for _, info := range infos {
+			if userDbNamespacePattern.MatchString(info.Name) {
+				continue
+			}
+			filtered = append(filtered, info)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(filtered)
+	}).Methods("GET")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// DbRoots is the set of database root directories. Large installations
+// spread databases across devices (envdb on one NVMe, uniref on
+// another); lookups search every root in order, while new installs go
+// to the primary (first) root.
+type DbRoots []string
+
+// UnmarshalJSON accepts both the historic single path string and a
+// list of paths, so existing config files keep working.
+func (r *DbRoots) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*r = DbRoots{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*r = DbRoots(multiple)
+	return nil
+}
+
+// Primary is the root new databases are installed into.
+func (r DbRoots) Primary() string {
+	if len(r) == 0 {
+		return ""
+	}
+	return r[0]
+}
+
+// Locate returns the root containing the named database; ok is false
+// when no root has it.
+func (r DbRoots) Locate(name string) (string, bool) {
+	for _, root := range r {
+		if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+			return root, true
+		}
+		matches, err := filepath.Glob(filepath.Join(root, name+"*"))
+		if err == nil && len(matches) > 0 {
+			return root, true
+		}
+	}
+	return r.Primary(), false
+}
+
+// Path resolves a database name to its full path, defaulting to the
+// primary root for databases that do not exist yet.
+func (r DbRoots) Path(name string) string {
+	root, _ := r.Locate(name)
+	return filepath.Join(root, name)
+}
+
+// Glob collects matches for a database file pattern across all roots.
+func (r DbRoots) Glob(pattern string) []string {
+	var all []string
+	for _, root := range r {
+		matches, err := filepath.Glob(filepath.Join(root, pattern))
+		if err != nil {
+			continue
+		}
+		all = append(all, matches...)
+	}
+	return all
+}
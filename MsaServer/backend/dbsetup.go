@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+// ConfigDbSetup drives the in-server port of setup_databases.sh: the
+// server downloads, converts and indexes the standard ColabFold
+// databases itself instead of requiring an operator to run the shell
+// script by hand.
+type ConfigDbSetup struct {
+	Enabled bool `json:"enabled"`
+	// Path is the database directory the downloads are installed into.
+	Path string `json:"path"`
+	// MirrorUrl overrides the default download mirror.
+	MirrorUrl string `json:"mirrorurl,omitempty"`
+	// Mmseqs is the mmseqs binary used for conversion and indexing.
+	Mmseqs string `json:"mmseqs"`
+	// SkipIndex skips createindex, mirroring MMSEQS_NO_INDEX.
+	SkipIndex bool `json:"skipindex,omitempty"`
+}
+
+const defaultDbMirror = "https://wwwuser.gwdg.de/~compbiol/colabfold"
+
+// databaseDownload describes one managed database: where to fetch it,
+// how to verify it and how to turn the download into a searchable
+// database. ReadyFile follows the marker convention of the shell
+// script, so a directory prepared by either route is recognized by
+// both.
+type databaseDownload struct {
+	Name      string
+	Archive   string
+	Sha256    string
+	ReadyFile string
+	// Profile databases go through tsv2exprofiledb after extraction;
+	// plain FASTA downloads use createdb instead.
+	Profile bool
+}
+
+// managedDatabases lists the standard set from setup_databases.sh.
+// Checksums are filled from the mirror's .sha256 sidecar files at
+// download time when not pinned here.
+var managedDatabases = []databaseDownload{
+	{Name: "uniref30_2302", Archive: "uniref30_2302.tar.gz", ReadyFile: "UNIREF30_READY", Profile: true},
+	{Name: "colabfold_envdb_202108", Archive: "colabfold_envdb_202108.tar.gz", ReadyFile: "COLABDB_READY", Profile: true},
+	{Name: "pdb100_230517", Archive: "pdb100_230517.fasta.gz", ReadyFile: "PDB_READY", Profile: false},
+}
+
+var ErrUnknownDatabase = errors.New("unknown database")
+
+// DbSetup runs managed database installations one at a time and tracks
+// their progress for the admin endpoint.
+type DbSetup struct {
+	config ConfigDbSetup
+
+	mutex  sync.Mutex
+	states map[string]string
+	busy   bool
+}
+
+func NewDbSetup(config ConfigDbSetup) *DbSetup {
+	if config.MirrorUrl == "" {
+		config.MirrorUrl = defaultDbMirror
+	}
+	if config.Mmseqs == "" {
+		config.Mmseqs = "mmseqs"
+	}
+	return &DbSetup{config: config, states: make(map[string]string)}
+}
+
+func (d *DbSetup) setState(name string, state string) {
+	d.mutex.Lock()
+	d.states[name] = state
+	d.mutex.Unlock()
+}
+
+// States returns a copy of the per-database progress map.
+func (d *DbSetup) States() map[string]string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	states := make(map[string]string, len(d.states))
+	for name, state := range d.states {
+		states[name] = state
+	}
+	for _, download := range managedDatabases {
+		if _, tracked := states[download.Name]; tracked {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(d.config.Path, download.ReadyFile)); err == nil {
+			states[download.Name] = "ready"
+		} else {
+			states[download.Name] = "absent"
+		}
+	}
+	return states
+}
+
+// downloadResumable fetches url into path, resuming a partial download
+// via an HTTP Range request when a .part file is left over from an
+// earlier attempt.
+func downloadResumable(url string, path string) error {
+	partial := path + ".part"
+	offset := int64(0)
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		request.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	flags := os.O_CREATE | os.O_WRONLY
+	switch response.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// server ignored the range; start over
+		flags |= os.O_TRUNC
+	default:
+		return fmt.Errorf("download of %s failed: %s", url, response.Status)
+	}
+	file, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, response.Body); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partial, path)
+}
+
+// verifyChecksum compares the file against the expected sha256; when no
+// checksum is pinned it tries the mirror's .sha256 sidecar and accepts
+// the download if the mirror publishes none.
+func (d *DbSetup) verifyChecksum(download databaseDownload, path string) error {
+	expected := download.Sha256
+	if expected == "" {
+		response, err := http.Get(d.config.MirrorUrl + "/" + download.Archive + ".sha256")
+		if err != nil || response.StatusCode != http.StatusOK {
+			if response != nil {
+				response.Body.Close()
+			}
+			return nil
+		}
+		sidecar, err := io.ReadAll(io.LimitReader(response.Body, 256))
+		response.Body.Close()
+		if err != nil {
+			return err
+		}
+		fields := string(sidecar)
+		if len(fields) >= 64 {
+			expected = fields[:64]
+		}
+	}
+	if expected == "" {
+		return nil
+	}
+	actual, err := fileSha256(path)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", download.Archive, actual, expected)
+	}
+	return nil
+}
+
+func (d *DbSetup) run(name string, arguments ...string) error {
+	cmd := exec.Command(d.config.Mmseqs, arguments...)
+	cmd.Dir = d.config.Path
+	cmd.Env = append(os.Environ(), "MMSEQS_FORCE_MERGE=1")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s failed: %w: %s", d.config.Mmseqs, arguments[0], err, string(output))
+	}
+	d.setState(name, "finished "+arguments[0])
+	return nil
+}
+
+// Setup installs one managed database end to end: resumable download,
+// checksum verification, extraction, profile conversion and index
+// creation, finishing with the READY marker.
+func (d *DbSetup) Setup(name string) error {
+	var download databaseDownload
+	found := false
+	for _, candidate := range managedDatabases {
+		if candidate.Name == name {
+			download = candidate
+			found = true
+			break
+		}
+	}
+	if !found {
+		return ErrUnknownDatabase
+	}
+	ready := filepath.Join(d.config.Path, download.ReadyFile)
+	if _, err := os.Stat(ready); err == nil {
+		d.setState(name, "ready")
+		return nil
+	}
+	archive := filepath.Join(d.config.Path, download.Archive)
+	d.setState(name, "downloading")
+	if err := downloadResumable(d.config.MirrorUrl+"/"+download.Archive, archive); err != nil {
+		d.setState(name, "error: "+err.Error())
+		return err
+	}
+	d.setState(name, "verifying")
+	if err := d.verifyChecksum(download, archive); err != nil {
+		d.setState(name, "error: "+err.Error())
+		return err
+	}
+	if err := d.install(download, archive); err != nil {
+		d.setState(name, "error: "+err.Error())
+		return err
+	}
+	if err := os.WriteFile(ready, []byte{}, 0644); err != nil {
+		d.setState(name, "error: "+err.Error())
+		return err
+	}
+	os.Remove(archive)
+	d.setState(name, "ready")
+	return nil
+}
+
+func (d *DbSetup) install(download databaseDownload, archive string) error {
+	name := download.Name
+	if download.Profile {
+		d.setState(name, "extracting")
+		cmd := exec.Command("tar", "xzf", archive)
+		cmd.Dir = d.config.Path
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("extracting %s failed: %w: %s", archive, err, string(output))
+		}
+		d.setState(name, "converting")
+		if err := d.run(name, "tsv2exprofiledb", name, name+"_db"); err != nil {
+			return err
+		}
+		if !d.config.SkipIndex {
+			d.setState(name, "indexing")
+			if err := d.run(name, "createindex", name+"_db", "tmp_"+name, "--remove-tmp-files", "1"); err != nil {
+				return err
+			}
+		}
+		// expose taxonomy files under the index name, as the shell
+		// script does
+		for _, suffix := range []string{"_mapping", "_taxonomy"} {
+			source := filepath.Join(d.config.Path, name+"_db"+suffix)
+			if _, err := os.Stat(source); err == nil {
+				os.Symlink(name+"_db"+suffix, filepath.Join(d.config.Path, name+"_db.idx"+suffix))
+			}
+		}
+		return nil
+	}
+	d.setState(name, "converting")
+	if err := d.run(name, "createdb", download.Archive, name); err != nil {
+		return err
+	}
+	if !d.config.SkipIndex {
+		d.setState(name, "indexing")
+		return d.run(name, "createindex", name, "tmp_"+name, "--remove-tmp-files", "1")
+	}
+	return nil
+}
+
+// SetupAsync starts one installation in the background; only one runs
+// at a time.
+func (d *DbSetup) SetupAsync(name string) error {
+	d.mutex.Lock()
+	if d.busy {
+		d.mutex.Unlock()
+		return errors.New("a database setup is already running")
+	}
+	d.busy = true
+	d.mutex.Unlock()
+	go func() {
+		defer func() {
+			d.mutex.Lock()
+			d.busy = false
+			d.mutex.Unlock()
+		}()
+		d.Setup(name)
+	}()
+	return nil
+}
+
+// RegisterDbSetupRoutes adds the managed database installer to the
+// admin API: POST /admin/databases/setup starts an installation,
+// GET /admin/databases/setup reports progress.
+func RegisterDbSetupRoutes(r *mux.Router, setup *DbSetup, config ConfigAdmin) {
+	if config.Token == "" {
+		return
+	}
+	r.HandleFunc("/admin/databases/setup", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		name := req.FormValue("database")
+		if name == "" {
+			http.Error(w, "missing database parameter", http.StatusBadRequest)
+			return
+		}
+		known := false
+		for _, download := range managedDatabases {
+			if download.Name == name {
+				known = true
+				break
+			}
+		}
+		if !known {
+			http.Error(w, ErrUnknownDatabase.Error(), http.StatusNotFound)
+			return
+		}
+		if err := setup.SetupAsync(name); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})).Methods("POST")
+	r.HandleFunc("/admin/databases/setup", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(setup.States())
+	})).Methods("GET")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// DbUsage accumulates the lifetime statistics of one database.
+type DbUsage struct {
+	Searches       int64   `json:"searches"`
+	Hits           int64   `json:"hits"`
+	RuntimeSeconds float64 `json:"runtime_seconds"`
+	LastUsed       string  `json:"last_used,omitempty"`
+}
+
+// DbStats tracks searches, hit counts and cumulative runtime per
+// database, persisted as one JSON file so the numbers survive
+// restarts. The admin endpoint exposes them to inform which databases
+// are worth their storage and RAM.
+type DbStats struct {
+	path string
+
+	mutex sync.Mutex
+	usage map[string]*DbUsage
+}
+
+// NewDbStats loads existing statistics from path, starting empty when
+// the file does not exist yet.
+func NewDbStats(path string) (*DbStats, error) {
+	stats := &DbStats{path: path, usage: make(map[string]*DbUsage)}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return stats, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &stats.usage); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// save writes the statistics atomically; the caller holds the lock.
+func (s *DbStats) save() error {
+	payload, err := json.MarshalIndent(s.usage, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := s.path + ".tmp"
+	if err := os.WriteFile(temp, payload, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, s.path)
+}
+
+// RecordSearch adds one finished search to every database it targeted.
+func (s *DbStats) RecordSearch(databases []string, hits int64, runtime time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, database := range databases {
+		usage, found := s.usage[database]
+		if !found {
+			usage = &DbUsage{}
+			s.usage[database] = usage
+		}
+		usage.Searches++
+		usage.Hits += hits
+		usage.RuntimeSeconds += runtime.Seconds()
+		usage.LastUsed = now
+	}
+	return s.save()
+}
+
+// Snapshot returns a copy of the per-database usage map.
+func (s *DbStats) Snapshot() map[string]DbUsage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	snapshot := make(map[string]DbUsage, len(s.usage))
+	for database, usage := range s.usage {
+		snapshot[database] = *usage
+	}
+	return snapshot
+}
+
+// RegisterDbStatsRoute adds GET /admin/databases/stats, the per-database
+// usage report.
+func RegisterDbStatsRoute(r *mux.Router, stats *DbStats, config ConfigAdmin) {
+	if config.Token == "" || stats == nil {
+		return
+	}
+	r.HandleFunc("/admin/databases/stats", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(stats.Snapshot())
+	})).Methods("GET")
+}
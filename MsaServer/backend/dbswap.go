@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// swapDrainInterval is how often a pending swap re-checks for running
+// jobs on the old version.
+const swapDrainInterval = 15 * time.Second
+
+// swapDrainTimeout bounds how long a swap waits for the old version to
+// drain before giving up; the alias switch itself has already happened
+// by then, only the removal is abandoned.
+const swapDrainTimeout = 24 * time.Hour
+
+// SwapRequest is the admin body for a hot database swap.
+type SwapRequest struct {
+	Alias   string `json:"alias"`
+	Version string `json:"version"`
+	// RemoveOld deletes the previous version's files once no running
+	// or queued job references it anymore.
+	RemoveOld bool `json:"remove_old,omitempty"`
+}
+
+// versionReferenced reports whether any non-terminal ticket still
+// targets the given database version.
+func versionReferenced(jobsystem JobSystem, version string) (bool, error) {
+	admin, ok := UnwrapJobSystem(jobsystem).(AdminSystem)
+	if !ok {
+		return false, errors.New("job system does not support ticket listing")
+	}
+	tickets, err := admin.ListTickets([]Status{StatusPending, StatusRunning})
+	if err != nil {
+		return false, err
+	}
+	for _, ticket := range tickets {
+		for _, database := range ticket.Databases {
+			if database == version {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// removeDatabaseVersion deletes all on-disk artifacts of one concrete
+// database version (the data files, _db conversion and index).
+func removeDatabaseVersion(databasesPath string, version string) error {
+	if version == "" || strings.ContainsAny(version, "/\\") {
+		return fmt.Errorf("refusing to remove database %q", version)
+	}
+	matches, err := filepath.Glob(filepath.Join(databasesPath, version+"*"))
+	if err != nil {
+		return err
+	}
+	for _, match := range matches {
+		if err := os.RemoveAll(match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HotSwapDatabase switches an alias to a staged new version without
+// downtime: new submissions resolve to the new version immediately,
+// running jobs finish on the version recorded in their payload, and the
+// old files are removed in the background once drained.
+func HotSwapDatabase(jobsystem JobSystem, aliases *DbAliases, databasesPath string, request SwapRequest) error {
+	oldVersion := aliases.Alias(request.Alias)
+	if oldVersion == request.Version {
+		return nil
+	}
+	if err := aliases.SetAlias(request.Alias, request.Version); err != nil {
+		return err
+	}
+	if !request.RemoveOld || oldVersion == "" {
+		return nil
+	}
+	go func() {
+		deadline := time.Now().Add(swapDrainTimeout)
+		for time.Now().Before(deadline) {
+			referenced, err := versionReferenced(jobsystem, oldVersion)
+			if err != nil {
+				log.Printf("database swap: cannot check references to %s: %s", oldVersion, err)
+				return
+			}
+			if !referenced {
+				if err := removeDatabaseVersion(databasesPath, oldVersion); err != nil {
+					log.Printf("database swap: removing %s failed: %s", oldVersion, err)
+				}
+				return
+			}
+			time.Sleep(swapDrainInterval)
+		}
+		log.Printf("database swap: %s still referenced after %s, keeping it", oldVersion, swapDrainTimeout)
+	}()
+	return nil
+}
+
+// RegisterDbSwapRoute adds POST /admin/databases/swap, the no-downtime
+// database upgrade: stage the new version with the installer first,
+// then swap the alias here.
+func RegisterDbSwapRoute(r *mux.Router, jobsystem JobSystem, aliases *DbAliases, databasesPath string, config ConfigAdmin) {
+	if config.Token == "" || aliases == nil {
+		return
+	}
+	r.HandleFunc("/admin/databases/swap", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		var request SwapRequest
+		if err := json.NewDecoder(req.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Alias == "" || request.Version == "" {
+			http.Error(w, "alias and version are required", http.StatusBadRequest)
+			return
+		}
+		if err := HotSwapDatabase(jobsystem, aliases, databasesPath, request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})).Methods("POST")
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// databaseChecksumFile lists "sha256  filename" pairs for a database's
+// files, written when the database is installed; boot verification
+// compares against it.
+const databaseChecksumFile = "CHECKSUMS"
+
+// mmseqsVersionFile records which mmseqs version built the indexes in a
+// database directory, so an mmseqs upgrade with an incompatible index
+// format is caught at boot instead of mid-job.
+const mmseqsVersionFile = "MMSEQS_VERSION"
+
+// WriteDatabaseChecksums records sizes and sha256 sums of a database's
+// files; verification at every later boot is a cheap size comparison
+// plus an optional full hash.
+func WriteDatabaseChecksums(databasesPath string, name string) error {
+	matches, err := filepath.Glob(filepath.Join(databasesPath, name+"*"))
+	if err != nil {
+		return err
+	}
+	var lines []string
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		sum, err := fileSha256(match)
+		if err != nil {
+			return err
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %s", sum, info.Size(), filepath.Base(match)))
+	}
+	payload := strings.Join(lines, "\n") + "\n"
+	return os.WriteFile(filepath.Join(databasesPath, name+"."+databaseChecksumFile), []byte(payload), 0644)
+}
+
+// verifyDatabaseFiles checks a database against its stored checksum
+// manifest. Sizes are always compared; full hashes only when deep is
+// set, because rehashing a multi-hundred-GB envdb on every boot is not
+// viable.
+func verifyDatabaseFiles(databasesPath string, name string, deep bool) error {
+	manifest := filepath.Join(databasesPath, name+"."+databaseChecksumFile)
+	file, err := os.Open(manifest)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// databases installed before checksums existed stay usable
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		path := filepath.Join(databasesPath, fields[2])
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("%s: missing file %s", name, fields[2])
+		}
+		if fmt.Sprintf("%d", info.Size()) != fields[1] {
+			return fmt.Errorf("%s: %s is %d bytes, expected %s (truncated download?)", name, fields[2], info.Size(), fields[1])
+		}
+		if deep {
+			sum, err := fileSha256(path)
+			if err != nil {
+				return err
+			}
+			if sum != fields[0] {
+				return fmt.Errorf("%s: checksum mismatch in %s", name, fields[2])
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// verifyIndexCompatibility compares the mmseqs version that built the
+// indexes against the installed binary.
+func verifyIndexCompatibility(databasesPath string, mmseqs string) error {
+	recorded, err := os.ReadFile(filepath.Join(databasesPath, mmseqsVersionFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	output, err := exec.Command(mmseqs, "version").Output()
+	if err != nil {
+		return fmt.Errorf("cannot determine mmseqs version: %w", err)
+	}
+	installed := strings.TrimSpace(string(output))
+	expected := strings.TrimSpace(string(recorded))
+	if installed != expected {
+		return fmt.Errorf("indexes were built with mmseqs %s but %s is installed; re-run createindex", expected, installed)
+	}
+	return nil
+}
+
+// RecordMmseqsVersion stores the installed mmseqs version next to the
+// databases; the installer calls it after index creation.
+func RecordMmseqsVersion(databasesPath string, mmseqs string) error {
+	output, err := exec.Command(mmseqs, "version").Output()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(databasesPath, mmseqsVersionFile), output, 0644)
+}
+
+// databaseVerifier runs the boot-time integrity verification once and
+// caches the outcome for /readyz.
+type databaseVerifier struct {
+	databasesPath string
+	databases     []string
+	mmseqs        string
+
+	once sync.Once
+	err  error
+}
+
+// NewDatabaseVerifier prepares boot verification for the configured
+// databases; pass the names jobs can target.
+func NewDatabaseVerifier(databasesPath string, databases []string, mmseqs string) *databaseVerifier {
+	return &databaseVerifier{databasesPath: databasesPath, databases: databases, mmseqs: mmseqs}
+}
+
+// Verify checks every configured database and the index compatibility;
+// the result is computed once and reused, so /readyz stays cheap.
+func (v *databaseVerifier) Verify() error {
+	v.once.Do(func() {
+		for _, name := range v.databases {
+			if err := verifyDatabaseFiles(v.databasesPath, name, false); err != nil {
+				v.err = err
+				return
+			}
+		}
+		v.err = verifyIndexCompatibility(v.databasesPath, v.mmseqs)
+	})
+	return v.err
+}
+
+// Check adapts the verification to the /readyz probe format; a server
+// with a silently truncated database never reports ready.
+func (v *databaseVerifier) Check() readinessCheck {
+	check := readinessCheck{Name: "database integrity"}
+	if err := v.Verify(); err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	check.Ok = true
+	return check
+}
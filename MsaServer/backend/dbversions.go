@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ConfigDbVersions maps stable database aliases to concrete versioned
+// directories, e.g. "uniref30" -> "uniref30_2302". Clients submit the
+// alias; the job records the concrete version it resolved to, so
+// results stay reproducible across database upgrades.
+type ConfigDbVersions struct {
+	Enabled bool              `json:"enabled"`
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// DbAliases resolves submitted database names against the alias map and
+// the versioned directories on disk. The map is mutable behind a lock
+// so an upgrade can switch an alias without a restart.
+type DbAliases struct {
+	path    string
+	mutex   sync.RWMutex
+	aliases map[string]string
+}
+
+func NewDbAliases(databasesPath string, config ConfigDbVersions) *DbAliases {
+	aliases := make(map[string]string, len(config.Aliases))
+	for alias, version := range config.Aliases {
+		aliases[alias] = version
+	}
+	return &DbAliases{path: databasesPath, aliases: aliases}
+}
+
+// Versions lists the concrete versions available for an alias, newest
+// last; version directories follow the <alias>_<version> naming of the
+// standard downloads.
+func (d *DbAliases) Versions(alias string) []string {
+	matches, err := filepath.Glob(filepath.Join(d.path, alias+"_*"))
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var versions []string
+	for _, match := range matches {
+		name := filepath.Base(match)
+		// strip db artifact suffixes so uniref30_2302_db.idx and
+		// friends all count as one version
+		name = strings.SplitN(name, ".", 2)[0]
+		name = strings.TrimSuffix(name, "_db")
+		if !seen[name] {
+			seen[name] = true
+			versions = append(versions, name)
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// Resolve maps a submitted database name to the concrete version the
+// job will run against. Already-versioned names pass through untouched,
+// which is how requests pin a version; plain aliases resolve via the
+// configured map, falling back to the newest version on disk.
+func (d *DbAliases) Resolve(name string) (string, error) {
+	if _, err := os.Stat(filepath.Join(d.path, name)); err == nil {
+		return name, nil
+	}
+	d.mutex.RLock()
+	version, mapped := d.aliases[name]
+	d.mutex.RUnlock()
+	if mapped {
+		return version, nil
+	}
+	versions := d.Versions(name)
+	if len(versions) == 0 {
+		return "", fmt.Errorf("no version of database %s is installed", name)
+	}
+	return versions[len(versions)-1], nil
+}
+
+// ResolveAll resolves every database of a submission; the returned
+// slice is what gets persisted in the job payload.
+func (d *DbAliases) ResolveAll(names []string) ([]string, error) {
+	resolved := make([]string, 0, len(names))
+	for _, name := range names {
+		version, err := d.Resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, version)
+	}
+	return resolved, nil
+}
+
+// Alias returns the current target of an alias, or the empty string if
+// it is not mapped.
+func (d *DbAliases) Alias(alias string) string {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.aliases[alias]
+}
+
+// SetAlias atomically repoints an alias at a new concrete version; new
+// submissions resolve to it immediately while running jobs keep the
+// version recorded in their payload.
+func (d *DbAliases) SetAlias(alias string, version string) error {
+	if _, err := os.Stat(filepath.Join(d.path, version)); err != nil {
+		if matches, globErr := filepath.Glob(filepath.Join(d.path, version+"*")); globErr != nil || len(matches) == 0 {
+			return fmt.Errorf("database version %s is not installed", version)
+		}
+	}
+	d.mutex.Lock()
+	d.aliases[alias] = version
+	d.mutex.Unlock()
+	return nil
+}
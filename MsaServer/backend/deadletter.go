@@ -0,0 +1,32 @@
+package main
+
+import "time"
+
+type ConfigDeadLetter struct {
+	// MaxFailures is the number of failed attempts after which a ticket
+	// moves to the dead-letter state instead of ERROR.
+	MaxFailures int `json:"maxfailures"`
+}
+
+type FailureRecord struct {
+	At      time.Time `json:"at"`
+	Message string    `json:"message"`
+}
+
+type DeadLetter struct {
+	Id       Id              `json:"id"`
+	Failures []FailureRecord `json:"failures"`
+}
+
+// DeadLetterSystem is implemented by job systems that keep per-ticket
+// failure histories and a dead-letter state.
+type DeadLetterSystem interface {
+	// RecordFailure appends to the ticket's error history and returns
+	// the resulting status: ERROR while under maxFailures attempts,
+	// DEADLETTER once the limit is reached.
+	RecordFailure(id Id, message string, maxFailures int) (Status, error)
+	DeadLetters() ([]DeadLetter, error)
+	// RequeueDeadLetter puts a dead-lettered ticket back into PENDING,
+	// keeping its failure history.
+	RequeueDeadLetter(id Id) error
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var ErrDecompressedTooLarge = errors.New("decompressed upload exceeds the configured maximum size")
+
+// maxDecompressedUploadBytes bounds how far a compressed query or
+// structure upload may inflate. Upload.MaxSizeBytes only limits the
+// bytes on the wire; without this bound a small zstd bomb could exhaust
+// memory when the payload is read back.
+const maxDecompressedUploadBytes = 256 << 20
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// DecompressingReader wraps an uploaded query stream and transparently
+// decompresses gzip and zstd payloads, detected by their magic bytes.
+// Plain uploads pass through untouched, so batch users can submit .gz
+// or .zst files without the server caring which. maxDecompressed bounds
+// the inflated size; a value of 0 disables the limit.
+func DecompressingReader(r io.Reader, maxDecompressed int64) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	var decompressed io.Reader
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		reader, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		decompressed = reader
+	case bytes.HasPrefix(magic, zstdMagic):
+		reader, err := zstd.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		decompressed = reader.IOReadCloser()
+	default:
+		decompressed = buffered
+	}
+	if maxDecompressed > 0 {
+		decompressed = &limitedDecompressor{reader: decompressed, remaining: maxDecompressed}
+	}
+	return decompressed, nil
+}
+
+// limitedDecompressor errors instead of silently truncating once the
+// decompressed stream exceeds the limit, so zip bombs surface as a
+// client error rather than a corrupt query file.
+type limitedDecompressor struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (l *limitedDecompressor) Read(p []byte) (int, error) {
+	if l.remaining <= 0 {
+		return 0, ErrDecompressedTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.reader.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrDecompressedTooLarge
+	}
+	return n, err
+}
+
+// ReadQueryUpload reads a possibly compressed query upload fully into
+// memory, enforcing the decompressed-size limit.
+func ReadQueryUpload(r io.Reader, maxDecompressed int64) ([]byte, error) {
+	decompressed, err := DecompressingReader(r, maxDecompressed)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(decompressed)
+}
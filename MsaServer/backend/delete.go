@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterDeleteRoute adds DELETE /ticket/{ticket}, which removes the
+// job's result directory and its entry in the job system. It satisfies
+// data-removal requests and hands storage back to the server without
+// waiting for the retention sweep. Only finished tickets can be
+// deleted; PENDING and RUNNING tickets must be cancelled first.
+func RegisterDeleteRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	store, ok := UnwrapJobSystem(jobsystem).(ArchivalSystem)
+	if !ok {
+		return
+	}
+	r.HandleFunc("/ticket/{ticket}", func(w http.ResponseWriter, req *http.Request) {
+		id := Id(mux.Vars(req)["ticket"])
+		status, err := jobsystem.Status(id)
+		if err != nil || status == StatusUnknown {
+			http.Error(w, "unknown ticket", http.StatusNotFound)
+			return
+		}
+		if !isTerminal(status) {
+			http.Error(w, "ticket is still queued or running; cancel it first", http.StatusConflict)
+			return
+		}
+		resultBase := filepath.Join(jobsbase, string(id))
+		if err := os.RemoveAll(resultBase); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// the result archive lives next to the per-job directory in
+		// some deployments; remove both spellings
+		os.Remove(filepath.Join(jobsbase, "mmseqs_results_"+string(id)+".tar.gz"))
+		if err := store.PurgeTicket(id); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+}
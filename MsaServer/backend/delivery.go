@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+)
+
+// deliveryFileName stores the per-job delivery target next to the job
+// inputs. File mode 0600: it contains customer credentials.
+const deliveryFileName = "delivery.json"
+
+// DeliveryTarget is a user-provided object storage destination; the
+// worker uploads the finished archive directly into the customer's
+// bucket instead of holding multi-GB results locally.
+type DeliveryTarget struct {
+	// Uri is s3://bucket/prefix or gs://bucket/prefix.
+	Uri string `json:"uri"`
+	// AccessKey and SecretKey are write-only credentials for s3
+	// targets; gs targets use the server's ambient credentials.
+	AccessKey string `json:"access_key,omitempty"`
+	SecretKey string `json:"secret_key,omitempty"`
+	Region    string `json:"region,omitempty"`
+	Endpoint  string `json:"endpoint,omitempty"`
+}
+
+var ErrInvalidDeliveryTarget = errors.New("invalid delivery target")
+
+func (t *DeliveryTarget) parse() (scheme string, bucket string, prefix string, err error) {
+	parsed, err := url.Parse(t.Uri)
+	if err != nil {
+		return "", "", "", err
+	}
+	if parsed.Scheme != "s3" && parsed.Scheme != "gs" {
+		return "", "", "", fmt.Errorf("%w: scheme must be s3 or gs", ErrInvalidDeliveryTarget)
+	}
+	if parsed.Host == "" {
+		return "", "", "", fmt.Errorf("%w: missing bucket", ErrInvalidDeliveryTarget)
+	}
+	return parsed.Scheme, parsed.Host, strings.Trim(parsed.Path, "/"), nil
+}
+
+// Validate checks the target without uploading anything.
+func (t *DeliveryTarget) Validate() error {
+	scheme, _, _, err := t.parse()
+	if err != nil {
+		return err
+	}
+	if scheme == "s3" && (t.AccessKey == "" || t.SecretKey == "") {
+		return fmt.Errorf("%w: s3 targets need access_key and secret_key", ErrInvalidDeliveryTarget)
+	}
+	return nil
+}
+
+// Probe writes a tiny marker object to verify the credentials can
+// actually write before the job is accepted.
+func (t *DeliveryTarget) Probe() error {
+	marker := fmt.Sprintf(".msaserver-probe-%d", time.Now().UnixNano())
+	return t.upload(marker, bytes.NewReader([]byte("probe")))
+}
+
+func (t *DeliveryTarget) upload(name string, body *bytes.Reader) error {
+	scheme, bucket, prefix, err := t.parse()
+	if err != nil {
+		return err
+	}
+	key := name
+	if prefix != "" {
+		key = prefix + "/" + name
+	}
+	switch scheme {
+	case "s3":
+		awsConfig := &aws.Config{
+			Region:      aws.String(t.Region),
+			Credentials: credentials.NewStaticCredentials(t.AccessKey, t.SecretKey, ""),
+		}
+		if t.Endpoint != "" {
+			awsConfig.Endpoint = aws.String(t.Endpoint)
+			awsConfig.S3ForcePathStyle = aws.Bool(true)
+		}
+		sess, err := session.NewSession(awsConfig)
+		if err != nil {
+			return err
+		}
+		_, err = s3.New(sess).PutObject(&s3.PutObjectInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   body,
+		})
+		return err
+	case "gs":
+		client, err := storage.NewClient(context.Background())
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+		writer := client.Bucket(bucket).Object(key).NewWriter(ctx)
+		if _, err := io.Copy(writer, body); err != nil {
+			writer.Close()
+			return err
+		}
+		return writer.Close()
+	}
+	return ErrInvalidDeliveryTarget
+}
+
+// SaveDeliveryTarget persists a validated target for the job; the
+// worker reads it back after archiving.
+func SaveDeliveryTarget(jobsbase string, id Id, target DeliveryTarget) error {
+	if err := target.Validate(); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(jobsbase, string(id)), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(jobsbase, string(id), deliveryFileName), payload, 0600)
+}
+
+// DeliverResult uploads the finished archive to the job's delivery
+// target, if one was stored. The multipart uploader streams from disk,
+// so archive size is not bounded by memory.
+func DeliverResult(jobsbase string, id Id) error {
+	raw, err := os.ReadFile(filepath.Join(jobsbase, string(id), deliveryFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var target DeliveryTarget
+	if err := json.Unmarshal(raw, &target); err != nil {
+		return err
+	}
+	scheme, bucket, prefix, err := target.parse()
+	if err != nil {
+		return err
+	}
+	archive := resultArchivePath(jobsbase, id)
+	key := filepath.Base(archive)
+	if prefix != "" {
+		key = prefix + "/" + key
+	}
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	switch scheme {
+	case "s3":
+		awsConfig := &aws.Config{
+			Region:      aws.String(target.Region),
+			Credentials: credentials.NewStaticCredentials(target.AccessKey, target.SecretKey, ""),
+		}
+		if target.Endpoint != "" {
+			awsConfig.Endpoint = aws.String(target.Endpoint)
+			awsConfig.S3ForcePathStyle = aws.Bool(true)
+		}
+		sess, err := session.NewSession(awsConfig)
+		if err != nil {
+			return err
+		}
+		_, err = s3manager.NewUploader(sess).Upload(&s3manager.UploadInput{
+			Bucket: aws.String(bucket),
+			Key:    aws.String(key),
+			Body:   file,
+		})
+		return err
+	case "gs":
+		client, err := storage.NewClient(context.Background())
+		if err != nil {
+			return err
+		}
+		defer client.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+		writer := client.Bucket(bucket).Object(key).NewWriter(ctx)
+		if _, err := io.Copy(writer, file); err != nil {
+			writer.Close()
+			return err
+		}
+		return writer.Close()
+	}
+	return ErrInvalidDeliveryTarget
+}
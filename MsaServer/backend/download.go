@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+)
+
+// resultArchivePath is where the worker leaves the finished archive.
+func resultArchivePath(jobsbase string, id Id) string {
+	return filepath.Join(jobsbase, string(id), "mmseqs_results_"+string(id)+".tar.gz")
+}
+
+// resultEtag returns the strong ETag (hex sha256) of the archive. The
+// hash is cached in a sidecar file keyed by size and mtime so repeated
+// conditional requests do not rehash multi-GB archives.
+func resultEtag(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	sidecar := path + ".etag"
+	if cached, err := os.ReadFile(sidecar); err == nil {
+		cachedInfo, err := os.Stat(sidecar)
+		if err == nil && cachedInfo.ModTime().After(info.ModTime()) {
+			return string(cached), nil
+		}
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	etag := hex.EncodeToString(hash.Sum(nil))
+	os.WriteFile(sidecar, []byte(etag), 0644)
+	return etag, nil
+}
+
+// RegisterDownloadRoute serves the result archive at
+// /result/download/{ticket} with a strong content-hash ETag and byte
+// range support. Clients and caching proxies sending If-None-Match get
+// a 304 instead of re-downloading an identical archive, and interrupted
+// downloads can resume with a Range request.
+func RegisterDownloadRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/result/download/{ticket}", func(w http.ResponseWriter, req *http.Request) {
+		id := Id(mux.Vars(req)["ticket"])
+		status, err := jobsystem.Status(id)
+		if err != nil || status == StatusUnknown {
+			http.Error(w, "unknown ticket", http.StatusNotFound)
+			return
+		}
+		if status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusConflict)
+			return
+		}
+		path := resultArchivePath(jobsbase, id)
+		switch chooseResultFormat(req) {
+		case "zip":
+			// prefer a zip the worker already persisted; otherwise
+			// re-encode the tarball on the fly
+			zipPath := filepath.Join(jobsbase, string(id), "mmseqs_results_"+string(id)+".zip")
+			if _, err := os.Stat(zipPath); err == nil {
+				w.Header().Set("Content-Type", "application/zip")
+				w.Header().Set("Content-Disposition", "attachment; filename=\"mmseqs_results_"+string(id)+".zip\"")
+				http.ServeFile(w, req, zipPath)
+				return
+			}
+			serveResultZip(w, path, id)
+			return
+		case "json":
+			serveResultJson(w, filepath.Join(jobsbase, string(id)), id)
+			return
+		case "tar.zst":
+			zstPath := filepath.Join(jobsbase, string(id), resultArchiveName(id, ResultCompressionZstd))
+			if _, err := os.Stat(zstPath); err != nil {
+				http.Error(w, "no zstd archive for ticket", http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/zstd")
+			w.Header().Set("Content-Disposition", "attachment; filename=\""+resultArchiveName(id, ResultCompressionZstd)+"\"")
+			http.ServeFile(w, req, zstPath)
+			return
+		}
+		file, err := os.Open(path)
+		if os.IsNotExist(err) {
+			// no pre-built archive yet: stream tar+gzip generation
+			// straight into the response and persist a copy for the
+			// next download
+			w.Header().Set("Content-Type", "application/gzip")
+			w.Header().Set("Content-Disposition", "attachment; filename=\"mmseqs_results_"+string(id)+".tar.gz\"")
+			StreamResultArchive(w, jobsbase, id, true)
+			return
+		}
+		if err != nil {
+			http.Error(w, "no result for ticket", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		if etag, err := resultEtag(path); err == nil {
+			quoted := `"` + etag + `"`
+			w.Header().Set("ETag", quoted)
+			if req.Header.Get("If-None-Match") == quoted {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"mmseqs_results_"+string(id)+".tar.gz\"")
+		info, err := file.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// ServeContent handles Range and If-Range, so interrupted
+		// downloads of large archives resume instead of restarting;
+		// the name stays empty to keep our Content-Type
+		http.ServeContent(w, req, "", info.ModTime(), file)
+	}).Methods("GET")
+	r.HandleFunc("/result/download/{ticket}/{database}", func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		id := Id(vars["ticket"])
+		database := vars["database"]
+		if database != filepath.Base(database) {
+			http.Error(w, "invalid database name", http.StatusBadRequest)
+			return
+		}
+		status, err := jobsystem.Status(id)
+		if err != nil || status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusNotFound)
+			return
+		}
+		path := filepath.Join(jobsbase, string(id), perDatabaseArchiveName(database))
+		if _, err := os.Stat(path); err != nil {
+			// per-database archives are created on demand; build them
+			// once and retry
+			if err := CreatePerDatabaseArchives(jobsbase, id); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if _, err := os.Stat(path); err != nil {
+				http.Error(w, "no results for this database", http.StatusNotFound)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+perDatabaseArchiveName(database)+"\"")
+		http.ServeFile(w, req, path)
+	}).Methods("GET")
+}
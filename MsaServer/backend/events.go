@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// RegisterEventRoutes adds GET /ticket/{ticket}/events, a server-sent
+// events stream that pushes a status event on every change and ends once
+// the ticket reaches a terminal status. Clients no longer need their own
+// poll loop.
+func RegisterEventRoutes(r *mux.Router, jobsystem JobSystem) {
+	r.HandleFunc("/ticket/{ticket}/events", func(w http.ResponseWriter, req *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		id := Id(mux.Vars(req)["ticket"])
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+
+		send := func(ticket Ticket) bool {
+			payload, err := json.Marshal(ticket)
+			if err != nil {
+				return false
+			}
+			if _, err := w.Write([]byte("event: status\ndata: ")); err != nil {
+				return false
+			}
+			if _, err := w.Write(payload); err != nil {
+				return false
+			}
+			if _, err := w.Write([]byte("\n\n")); err != nil {
+				return false
+			}
+			flusher.Flush()
+			return true
+		}
+
+		last := Status("")
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		keepalive := time.NewTicker(15 * time.Second)
+		defer keepalive.Stop()
+		for {
+			ticket, err := jobsystem.GetTicket(id)
+			if err != nil {
+				return
+			}
+			if ticket.Status != last {
+				last = ticket.Status
+				if !send(ticket) {
+					return
+				}
+			}
+			if isTerminal(ticket.Status) || ticket.Status == StatusUnknown {
+				return
+			}
+			select {
+			case <-req.Context().Done():
+				return
+			case <-keepalive.C:
+				// comment line keeps proxies from closing the stream
+				if _, err := w.Write([]byte(": keepalive\n\n")); err != nil {
+					return
+				}
+				flusher.Flush()
+			case <-ticker.C:
+			}
+		}
+	}).Methods("GET")
+}
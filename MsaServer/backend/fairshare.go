@@ -0,0 +1,44 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// fairRotor remembers when each API key was last served so Dequeue can
+// interleave tickets from different users instead of draining one heavy
+// user's backlog first.
+type fairRotor struct {
+	mu     sync.Mutex
+	served map[string]time.Time
+}
+
+func newFairRotor() *fairRotor {
+	return &fairRotor{served: make(map[string]time.Time)}
+}
+
+// Pick returns the key among candidates that was served least recently;
+// keys that were never served win over keys that were.
+func (r *fairRotor) Pick(candidates []string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	best := candidates[0]
+	bestTime, bestSeen := r.served[best]
+	for _, candidate := range candidates[1:] {
+		at, seen := r.served[candidate]
+		if !seen {
+			if bestSeen {
+				best, bestTime, bestSeen = candidate, at, false
+			}
+			continue
+		}
+		if bestSeen && at.Before(bestTime) {
+			best, bestTime = candidate, at
+		}
+	}
+	r.served[best] = time.Now()
+	return best
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// DbParams is the sidecar metadata file (<database>.params) describing
+// a database to the server and its clients.
+type DbParams struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Kind is "sequence" for mmseqs databases and "structure" for
+	// foldseek databases.
+	Kind string `json:"kind"`
+	// Taxonomy marks databases with taxid mapping files.
+	Taxonomy bool `json:"taxonomy,omitempty"`
+	// ProfileTarget marks the database as a profile database (Pfam,
+	// profile UniRef30 builds); searches run sequence-vs-profile
+	// against it.
+	ProfileTarget bool `json:"profile_target,omitempty"`
+	// GpuCapable requests the padded sequence database the mmseqs GPU
+	// search needs; IndexJob builds it alongside the index.
+	GpuCapable bool `json:"gpu_capable,omitempty"`
+	// Search carries extra freeform search parameters; prefer the
+	// structured Pipeline settings for anything the pipeline supports.
+	Search string `json:"search,omitempty"`
+	// Pipeline carries the structured per-database pipeline settings.
+	Pipeline *DbPipelineParams `json:"pipeline,omitempty"`
+	// Display carries the client-facing registry metadata.
+	Display *DbDisplay `json:"display,omitempty"`
+}
+
+// DbDisplay is what frontends render in their database picker, so the
+// list of offered databases no longer has to be hardcoded client-side.
+type DbDisplay struct {
+	// Label is the human-readable name, e.g. "UniRef30 2302".
+	Label string `json:"label"`
+	// Description is one sentence on content and intended use.
+	Description string `json:"description,omitempty"`
+	// Citation is the reference to credit when publishing results.
+	Citation string `json:"citation,omitempty"`
+	// Default marks databases preselected in the picker.
+	Default bool `json:"default,omitempty"`
+	// Order sorts the picker; lower comes first.
+	Order int `json:"order,omitempty"`
+}
+
+// DbPipelineParams is the structured form of the pipeline knobs the
+// ColabFold search hardcodes per database class; zero values fall back
+// to the pipeline defaults.
+type DbPipelineParams struct {
+	// Profile marks expandable profile databases (tsv2exprofiledb
+	// output) that support the expandaln step against their .idx.
+	Profile bool `json:"profile,omitempty"`
+	// Sensitivity is the -s value of the initial search.
+	Sensitivity float64 `json:"sensitivity,omitempty"`
+	// ExpandEval is the expandaln e-value cutoff (EXPAND_EVAL).
+	ExpandEval float64 `json:"expand_eval,omitempty"`
+	// AlignEval is the realignment e-value cutoff (ALIGN_EVAL).
+	AlignEval float64 `json:"align_eval,omitempty"`
+	// Diff is the MSA diversity filter's --diff value.
+	Diff int `json:"diff,omitempty"`
+	// Qsc is the --qsc threshold used when filtering is enabled.
+	Qsc float64 `json:"qsc,omitempty"`
+	// MaxAccept caps accepted alignments per query.
+	MaxAccept int `json:"max_accept,omitempty"`
+	// Coverage and CoverageMode map to -c and --cov-mode.
+	Coverage     float64 `json:"coverage,omitempty"`
+	CoverageMode int     `json:"coverage_mode,omitempty"`
+}
+
+// SearchArgs renders the structured settings as mmseqs arguments for
+// the initial search step.
+func (p *DbPipelineParams) SearchArgs() []string {
+	if p == nil {
+		return nil
+	}
+	var args []string
+	if p.Sensitivity > 0 {
+		args = append(args, "-s", fmt.Sprintf("%g", p.Sensitivity))
+	}
+	if p.MaxAccept > 0 {
+		args = append(args, "--max-accept", fmt.Sprintf("%d", p.MaxAccept))
+	}
+	if p.Coverage > 0 {
+		args = append(args, "-c", fmt.Sprintf("%g", p.Coverage))
+	}
+	if p.CoverageMode > 0 {
+		args = append(args, "--cov-mode", fmt.Sprintf("%d", p.CoverageMode))
+	}
+	return args
+}
+
+// ExpandArgs renders the expandaln settings; nil for databases that are
+// not expandable profiles.
+func (p *DbPipelineParams) ExpandArgs() []string {
+	if p == nil || !p.Profile {
+		return nil
+	}
+	var args []string
+	if p.ExpandEval > 0 {
+		args = append(args, "-e", fmt.Sprintf("%g", p.ExpandEval))
+	}
+	return args
+}
+
+// AlignArgs renders the realignment settings.
+func (p *DbPipelineParams) AlignArgs() []string {
+	if p == nil {
+		return nil
+	}
+	var args []string
+	if p.AlignEval > 0 {
+		args = append(args, "-e", fmt.Sprintf("%g", p.AlignEval))
+	}
+	return args
+}
+
+// FilterArgs renders the MSA diversity filter settings.
+func (p *DbPipelineParams) FilterArgs() []string {
+	if p == nil {
+		return nil
+	}
+	var args []string
+	if p.Diff > 0 {
+		args = append(args, "--diff", fmt.Sprintf("%d", p.Diff))
+	}
+	if p.Qsc != 0 {
+		args = append(args, "--qsc", fmt.Sprintf("%g", p.Qsc))
+	}
+	return args
+}
+
+// WriteDbParams stores the params sidecar next to the database files.
+func WriteDbParams(databasesPath string, name string, params DbParams) error {
+	payload, err := json.MarshalIndent(params, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(databasesPath, name+".params"), payload, 0644)
+}
+
+// ReadDbParams loads a database's params sidecar; databases without one
+// yield a zero value and no error.
+func ReadDbParams(databasesPath string, name string) (DbParams, error) {
+	raw, err := os.ReadFile(filepath.Join(databasesPath, name+".params"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DbParams{Name: name}, nil
+		}
+		return DbParams{}, err
+	}
+	var params DbParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return DbParams{}, err
+	}
+	return params, nil
+}
+
+// BuildFoldseekDatabase creates a searchable foldseek database from a
+// directory or tarball of PDB/mmCIF files, so labs can search their
+// internal predicted-structure collections. The database is registered
+// with a .params sidecar once createdb and createindex succeed.
+func BuildFoldseekDatabase(foldseek string, databasesPath string, name string, source string) error {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid database name %q", name)
+	}
+	if foldseek == "" {
+		foldseek = "foldseek"
+	}
+	structures := source
+	if strings.HasSuffix(source, ".tar.gz") || strings.HasSuffix(source, ".tgz") || strings.HasSuffix(source, ".tar") {
+		extracted, err := os.MkdirTemp(databasesPath, ".foldseek-src-*")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(extracted)
+		cmd := exec.Command("tar", "xf", source, "-C", extracted)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("extracting %s failed: %w: %s", source, err, string(output))
+		}
+		structures = extracted
+	}
+	if info, err := os.Stat(structures); err != nil || !info.IsDir() {
+		return fmt.Errorf("structure source %s is not a directory", source)
+	}
+	target := filepath.Join(databasesPath, name)
+	steps := [][]string{
+		{"createdb", structures, target},
+		{"createindex", target, filepath.Join(databasesPath, "tmp_"+name), "--remove-tmp-files", "1"},
+	}
+	for _, step := range steps {
+		cmd := exec.Command(foldseek, step...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s %s failed: %w: %s", foldseek, step[0], err, string(output))
+		}
+	}
+	return WriteDbParams(databasesPath, name, DbParams{Name: name, Kind: "structure"})
+}
+
+// RegisterFoldseekDbRoute adds POST /admin/databases/foldseek, which
+// builds a foldseek database in the background from a server-side
+// directory or tarball of structures (typically a completed upload).
+func RegisterFoldseekDbRoute(r *mux.Router, foldseek string, databasesPath string, config ConfigAdmin) {
+	if config.Token == "" {
+		return
+	}
+	r.HandleFunc("/admin/databases/foldseek", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		name := req.FormValue("name")
+		source := req.FormValue("source")
+		if name == "" || source == "" {
+			http.Error(w, "name and source are required", http.StatusBadRequest)
+			return
+		}
+		if _, err := os.Stat(source); err != nil {
+			http.Error(w, "source does not exist", http.StatusBadRequest)
+			return
+		}
+		go func() {
+			if err := BuildFoldseekDatabase(foldseek, databasesPath, name, source); err != nil {
+				log.Printf("foldseek database %s: %s", name, err)
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+	})).Methods("POST")
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// allowedOutputColumns is the allowlist of mmseqs --format-output
+// columns clients may request. It covers everything the bundled mmseqs
+// build emits; requests outside the list are rejected at submission
+// instead of failing inside the worker.
+var allowedOutputColumns = map[string]bool{
+	"query": true, "target": true, "evalue": true, "gapopen": true,
+	"pident": true, "fident": true, "nident": true, "qstart": true,
+	"qend": true, "qlen": true, "tstart": true, "tend": true,
+	"tlen": true, "alnlen": true, "raw": true, "bits": true,
+	"cigar": true, "qseq": true, "tseq": true, "qheader": true,
+	"theader": true, "qaln": true, "taln": true, "mismatch": true,
+	"qcov": true, "tcov": true, "qset": true, "qsetid": true,
+	"tset": true, "tsetid": true, "taxid": true, "taxname": true,
+	"taxlineage": true,
+}
+
+// allowedStructureOutputColumns extends the allowlist for foldseek
+// structure searches with alignment-geometry columns, so downstream
+// tools can superpose hits without recomputing alignments: qca/tca are
+// the aligned C-alpha coordinates, u/t the rotation matrix and
+// translation vector.
+var allowedStructureOutputColumns = map[string]bool{
+	"qca": true, "tca": true, "alntmscore": true, "qtmscore": true,
+	"ttmscore": true, "lddt": true, "lddtfull": true, "prob": true,
+	"u": true, "t": true, "rmsd": true,
+}
+
+// ValidateStructureOutputColumns checks a structure-search column set;
+// it accepts everything a sequence search accepts plus the geometry
+// columns.
+func ValidateStructureOutputColumns(columns []string) error {
+	for _, column := range columns {
+		if !allowedOutputColumns[column] && !allowedStructureOutputColumns[column] {
+			return fmt.Errorf("unknown output column %q", column)
+		}
+	}
+	return nil
+}
+
+// defaultOutputColumns is the classic 12-column m8 layout used when a
+// request does not pick its own columns.
+var defaultOutputColumns = []string{
+	"query", "target", "pident", "alnlen", "mismatch", "gapopen",
+	"qstart", "qend", "tstart", "tend", "evalue", "bits",
+}
+
+// ValidateOutputColumns checks a requested column set against the
+// allowlist.
+func ValidateOutputColumns(columns []string) error {
+	for _, column := range columns {
+		if !allowedOutputColumns[column] {
+			return fmt.Errorf("unknown output column %q", column)
+		}
+	}
+	return nil
+}
+
+// FormatOutputArg renders the columns as the mmseqs --format-output
+// argument, falling back to the default m8 layout.
+func FormatOutputArg(columns []string) string {
+	if len(columns) == 0 {
+		columns = defaultOutputColumns
+	}
+	return strings.Join(columns, ",")
+}
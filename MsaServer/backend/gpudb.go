@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// paddedDbSuffix names the padded companion database the mmseqs GPU
+// search requires (makepaddedseqdb output).
+const paddedDbSuffix = "_pad"
+
+// HasPaddedDatabase reports whether the GPU-padded companion of a
+// database exists.
+func HasPaddedDatabase(root string, name string) bool {
+	_, err := os.Stat(filepath.Join(root, name+paddedDbSuffix+".dbtype"))
+	return err == nil
+}
+
+// BuildPaddedDatabase runs makepaddedseqdb, producing <name>_pad next
+// to the database.
+func BuildPaddedDatabase(mmseqs string, root string, name string) error {
+	if mmseqs == "" {
+		mmseqs = "mmseqs"
+	}
+	source := filepath.Join(root, name)
+	cmd := exec.Command(mmseqs, "makepaddedseqdb", source, source+paddedDbSuffix)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("makepaddedseqdb %s failed: %w: %s", name, err, string(output))
+	}
+	return nil
+}
+
+// EnsurePaddedDatabase builds the padded database for gpu-capable
+// targets if it is missing; IndexJob calls it after index creation so
+// GPU workers find the padded DB without a separate admin step. It is
+// a no-op for databases not flagged gpu-capable in their params.
+func EnsurePaddedDatabase(mmseqs string, root string, name string) error {
+	params, err := ReadDbParams(root, name)
+	if err != nil {
+		return err
+	}
+	if !params.GpuCapable || HasPaddedDatabase(root, name) {
+		return nil
+	}
+	return BuildPaddedDatabase(mmseqs, root, name)
+}
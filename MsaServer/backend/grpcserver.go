@@ -0,0 +1,205 @@
+package main
+
+//go:generate protoc --go_out=. --go-grpc_out=. api/msaserver.proto
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	pb "msaserver/backend/msaserverpb"
+)
+
+type ConfigGrpc struct {
+	Enabled bool   `json:"enabled"`
+	Address string `json:"address"`
+}
+
+// grpcServer exposes the job system over gRPC next to the REST API; both
+// share the same JobSystem, so tickets are interchangeable between the
+// two.
+type grpcServer struct {
+	pb.UnimplementedMsaServerServer
+	jobsystem JobSystem
+	jobsbase  string
+}
+
+func (s *grpcServer) ticketResponse(ticket Ticket) *pb.TicketResponse {
+	response := &pb.TicketResponse{
+		Id:     string(ticket.Id),
+		Status: string(ticket.Status),
+	}
+	for _, transition := range ticket.History {
+		response.History = append(response.History, &pb.Transition{
+			Status: string(transition.Status),
+			At:     transition.At.Unix(),
+			Worker: transition.Worker,
+		})
+	}
+	return response
+}
+
+func (s *grpcServer) Submit(ctx context.Context, request *pb.SubmitRequest) (*pb.TicketResponse, error) {
+	hash := sha256.Sum256(append([]byte(request.GetType()), request.GetJob()...))
+	id := Id(hex.EncodeToString(hash[:])[:32])
+	ticket, err := NewJobIdempotent(s.jobsystem, JobRequest{
+		Id:       id,
+		Type:     JobType(request.GetType()),
+		Job:      json.RawMessage(request.GetJob()),
+		Email:    request.GetEmail(),
+		Priority: ParsePriority(request.GetPriority()),
+		ApiKey:   request.GetApiKey(),
+	}, s.jobsbase, false, request.GetIdempotencyKey())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s.ticketResponse(ticket), nil
+}
+
+func (s *grpcServer) Status(ctx context.Context, request *pb.StatusRequest) (*pb.TicketResponse, error) {
+	ticket, err := s.jobsystem.GetTicket(Id(request.GetId()))
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return s.ticketResponse(ticket), nil
+}
+
+func (s *grpcServer) WatchStatus(request *pb.StatusRequest, stream pb.MsaServer_WatchStatusServer) error {
+	last := Status("")
+	for {
+		ticket, err := s.jobsystem.GetTicket(Id(request.GetId()))
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if ticket.Status != last {
+			last = ticket.Status
+			if err := stream.Send(s.ticketResponse(ticket)); err != nil {
+				return err
+			}
+		}
+		if isTerminal(ticket.Status) || ticket.Status == StatusUnknown {
+			return nil
+		}
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (s *grpcServer) Result(request *pb.ResultRequest, stream pb.MsaServer_ResultServer) error {
+	// like the HTTP download route, the ticket lookup gates the file
+	// access: a fabricated id (e.g. with path separators) is unknown to
+	// the job system and never reaches the filesystem
+	id := Id(request.GetId())
+	ticketStatus, err := s.jobsystem.Status(id)
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+	if ticketStatus == StatusUnknown {
+		return status.Error(codes.NotFound, "unknown ticket")
+	}
+	if ticketStatus != StatusComplete {
+		return status.Error(codes.FailedPrecondition, "job is not complete")
+	}
+	path := resultArchivePath(s.jobsbase, id)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return status.Error(codes.NotFound, "no result for ticket")
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+	defer file.Close()
+	buffer := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buffer)
+		if n > 0 {
+			if err := stream.Send(&pb.ResultChunk{Data: buffer[:n]}); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+}
+
+// grpcScope maps RPCs to the API key scope they need, mirroring the
+// HTTP scopedAuth middleware: submissions need submit, result retrieval
+// needs download, and plain status reads pass through.
+func grpcScope(fullMethod string) string {
+	switch {
+	case strings.HasSuffix(fullMethod, "/Submit"):
+		return ScopeSubmit
+	case strings.HasSuffix(fullMethod, "/Result"):
+		return ScopeDownload
+	default:
+		return ""
+	}
+}
+
+// grpcCheckKey validates the x-api-key request metadata against the
+// issued-key store. OIDC principals are HTTP-only; the gRPC surface
+// accepts issued keys exclusively.
+func grpcCheckKey(ctx context.Context, store *ApiKeyStore, fullMethod string) error {
+	scope := grpcScope(fullMethod)
+	if scope == "" {
+		return nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	if keys := md.Get("x-api-key"); len(keys) > 0 && store.Check(keys[0], scope) {
+		return nil
+	}
+	return status.Error(codes.Unauthenticated, "missing or insufficient API key")
+}
+
+// StartGrpcServer serves the gRPC API on the configured address; it
+// returns immediately and serves in the background. With auth enabled
+// the RPCs require the same issued API keys as the HTTP surface, passed
+// as x-api-key request metadata.
+func StartGrpcServer(jobsystem JobSystem, jobsbase string, config ConfigGrpc, store *ApiKeyStore, authEnabled bool) (*grpc.Server, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	listener, err := net.Listen("tcp", config.Address)
+	if err != nil {
+		return nil, err
+	}
+	var options []grpc.ServerOption
+	if authEnabled {
+		options = append(options,
+			grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				if err := grpcCheckKey(ctx, store, info.FullMethod); err != nil {
+					return nil, err
+				}
+				return handler(ctx, req)
+			}),
+			grpc.ChainStreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				if err := grpcCheckKey(stream.Context(), store, info.FullMethod); err != nil {
+					return err
+				}
+				return handler(srv, stream)
+			}),
+		)
+	}
+	server := grpc.NewServer(options...)
+	pb.RegisterMsaServerServer(server, &grpcServer{jobsystem: jobsystem, jobsbase: jobsbase})
+	go server.Serve(listener)
+	return server, nil
+}
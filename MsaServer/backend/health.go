@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+)
+
+// readinessCheck is one named probe in the /readyz response.
+type readinessCheck struct {
+	Name  string `json:"name"`
+	Ok    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func checkDatabasesPresent(roots DbRoots) readinessCheck {
+	check := readinessCheck{Name: "databases"}
+	if len(roots) == 0 {
+		check.Error = "no database roots configured"
+		return check
+	}
+	populated := false
+	for _, root := range roots {
+		entries, err := os.ReadDir(root)
+		if err != nil {
+			check.Error = err.Error()
+			return check
+		}
+		if len(entries) > 0 {
+			populated = true
+		}
+	}
+	if !populated {
+		check.Error = "all database roots are empty"
+		return check
+	}
+	check.Ok = true
+	return check
+}
+
+func checkScratchWritable(scratchPath string) readinessCheck {
+	check := readinessCheck{Name: "scratch"}
+	if err := os.MkdirAll(scratchPath, 0755); err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	probe := filepath.Join(scratchPath, ".readyz")
+	if err := os.WriteFile(probe, []byte("ok"), 0644); err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	os.Remove(probe)
+	check.Ok = true
+	return check
+}
+
+func checkQueueReachable(jobsystem JobSystem) readinessCheck {
+	check := readinessCheck{Name: "queue"}
+	if _, err := jobsystem.QueueLength(); err != nil {
+		check.Error = err.Error()
+		return check
+	}
+	check.Ok = true
+	return check
+}
+
+// RegisterHealthRoutes adds the two probes load balancers and
+// Kubernetes expect: /healthz only says the process is alive, while
+// /readyz verifies the databases are present, scratch space is writable
+// and the queue backend answers — so traffic is not routed to a server
+// whose database mount disappeared. A non-nil verifier additionally
+// gates readiness on the boot-time database integrity verification.
+func RegisterHealthRoutes(r *mux.Router, jobsystem JobSystem, roots DbRoots, scratchPath string, verifier *databaseVerifier) {
+	r.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("ok\n"))
+	}).Methods("GET")
+	r.HandleFunc("/readyz", func(w http.ResponseWriter, req *http.Request) {
+		checks := []readinessCheck{
+			checkDatabasesPresent(roots),
+			checkScratchWritable(scratchPath),
+			checkQueueReachable(jobsystem),
+		}
+		if verifier != nil {
+			checks = append(checks, verifier.Check())
+		}
+		ready := true
+		for _, check := range checks {
+			if !check.Ok {
+				ready = false
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if !ready {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"ready":  ready,
+			"checks": checks,
+		})
+	}).Methods("GET")
+}
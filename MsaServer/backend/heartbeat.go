@@ -0,0 +1,18 @@
+package main
+
+import "time"
+
+// HeartbeatSystem is implemented by job systems that track worker
+// heartbeats on running tickets, so jobs from crashed workers can be
+// requeued automatically instead of sitting in RUNNING forever.
+type HeartbeatSystem interface {
+	// Heartbeat records that the given worker is still executing the
+	// ticket.
+	Heartbeat(id Id, worker string) error
+	// RequeueDead flips RUNNING tickets without a heartbeat within the
+	// visibility timeout back to PENDING. A ticket is only requeued
+	// maxRequeues times; after that it is treated as a failure so a
+	// reproducibly crashing job cannot loop forever. It returns the
+	// number of requeued tickets.
+	RequeueDead(visibility time.Duration, maxRequeues int) (int, error)
+}
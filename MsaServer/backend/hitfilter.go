@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// HitFilter is a per-request post-filter applied to alignment outputs
+// before archiving. Clients receiving millions of envdb hits use it to
+// cut the download by an order of magnitude server-side. Zero values
+// leave the respective dimension unfiltered.
+type HitFilter struct {
+	// MaxEvalue drops hits with a larger e-value.
+	MaxEvalue float64 `json:"max_evalue,omitempty"`
+	// MinIdentity drops hits below this sequence identity, in percent
+	// for m8 tables and as a fraction against the query for a3m rows.
+	MinIdentity float64 `json:"min_identity,omitempty"`
+	// MinCoverage drops a3m rows covering less of the query.
+	MinCoverage float64 `json:"min_coverage,omitempty"`
+	// MaxHits keeps only the best-scoring hits per query (by bit score
+	// for m8 tables, alignment order for a3m).
+	MaxHits int `json:"max_hits,omitempty"`
+}
+
+func (f *HitFilter) active() bool {
+	return f != nil && (f.MaxEvalue > 0 || f.MinIdentity > 0 || f.MinCoverage > 0 || f.MaxHits > 0)
+}
+
+// Validate rejects nonsensical filter settings at submission.
+func (f *HitFilter) Validate() error {
+	if f == nil {
+		return nil
+	}
+	if f.MaxEvalue < 0 {
+		return fmt.Errorf("max_evalue must not be negative")
+	}
+	if f.MinIdentity < 0 || f.MinIdentity > 100 {
+		return fmt.Errorf("min_identity must be between 0 and 100")
+	}
+	if f.MinCoverage < 0 || f.MinCoverage > 1 {
+		return fmt.Errorf("min_coverage must be between 0 and 1")
+	}
+	if f.MaxHits < 0 {
+		return fmt.Errorf("max_hits must not be negative")
+	}
+	return nil
+}
+
+func (f *HitFilter) keepM8(hit ResultHit) bool {
+	if f.MaxEvalue > 0 && hit.Evalue > f.MaxEvalue {
+		return false
+	}
+	if f.MinIdentity > 0 && hit.Identity < f.MinIdentity {
+		return false
+	}
+	return true
+}
+
+// rewriteFiltered streams src line by line into a temp file, keeping
+// the lines keep approves, then atomically replaces src.
+func rewriteFiltered(path string, keep func(string) bool) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	temp, err := os.CreateTemp(filepath.Dir(path), ".filter-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	writer := bufio.NewWriter(temp)
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !keep(line) {
+			continue
+		}
+		writer.WriteString(line)
+		writer.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// FilterM8File applies the filter to one m8 hit table in place. With
+// MaxHits set, hits are ranked by bit score per query and only the best
+// N survive.
+func (f *HitFilter) FilterM8File(path string) error {
+	if !f.active() {
+		return nil
+	}
+	kept := make(map[string]int)
+	if f.MaxHits > 0 {
+		// first pass: count how many better-scoring hits each query
+		// has, so the single-pass rewrite can cut at rank MaxHits
+		ranks, err := m8BitscoreRanks(path)
+		if err != nil {
+			return err
+		}
+		kept = ranks
+	}
+	line := 0
+	return rewriteFiltered(path, func(text string) bool {
+		line++
+		hit, ok := parseM8Line(text)
+		if !ok {
+			return true
+		}
+		if !f.keepM8(hit) {
+			return false
+		}
+		if f.MaxHits > 0 && kept[lineKey(hit.Query, line)] > f.MaxHits {
+			return false
+		}
+		return true
+	})
+}
+
+func lineKey(query string, line int) string {
+	return fmt.Sprintf("%s\x00%d", query, line)
+}
+
+// m8BitscoreRanks maps every line to the 1-based rank of its hit within
+// its query when sorted by descending bit score.
+func m8BitscoreRanks(path string) (map[string]int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	type scored struct {
+		line  int
+		score float64
+	}
+	perQuery := make(map[string][]scored)
+	line := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line++
+		hit, ok := parseM8Line(scanner.Text())
+		if !ok {
+			continue
+		}
+		perQuery[hit.Query] = append(perQuery[hit.Query], scored{line: line, score: hit.Bitscore})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	ranks := make(map[string]int)
+	for query, hits := range perQuery {
+		sort.SliceStable(hits, func(i, j int) bool { return hits[i].score > hits[j].score })
+		for rank, hit := range hits {
+			ranks[lineKey(query, hit.line)] = rank + 1
+		}
+	}
+	return ranks, nil
+}
+
+// FilterA3MFile applies identity and coverage filters against the query
+// to one a3m in place; the query row itself is always kept.
+func (f *HitFilter) FilterA3MFile(path string) error {
+	if f == nil || (f.MinIdentity <= 0 && f.MinCoverage <= 0 && f.MaxHits <= 0) {
+		return nil
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	entries, err := readA3MEntries(file)
+	file.Close()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+	query := entries[0].Sequence
+	keep := make(map[string]bool, len(entries))
+	keep[entries[0].Name] = true
+	for _, entry := range entries[1:] {
+		aligned := 0
+		identical := 0
+		for i := 0; i < len(query) && i < len(entry.Sequence); i++ {
+			if entry.Sequence[i] == '-' {
+				continue
+			}
+			aligned++
+			if entry.Sequence[i] == query[i] {
+				identical++
+			}
+		}
+		coverage := float64(aligned) / float64(len(query))
+		identity := 0.0
+		if aligned > 0 {
+			identity = float64(identical) / float64(aligned) * 100
+		}
+		if f.MinCoverage > 0 && coverage < f.MinCoverage {
+			continue
+		}
+		if f.MinIdentity > 0 && identity < f.MinIdentity {
+			continue
+		}
+		// a3m rows are ordered by significance, so a plain cutoff
+		// keeps the best MaxHits sequences
+		if f.MaxHits > 0 && len(keep)-1 >= f.MaxHits {
+			break
+		}
+		keep[entry.Name] = true
+	}
+	inKept := false
+	return rewriteFiltered(path, func(line string) bool {
+		if strings.HasPrefix(line, ">") {
+			fields := strings.Fields(line[1:])
+			name := ""
+			if len(fields) > 0 {
+				name = fields[0]
+			}
+			inKept = keep[name]
+		}
+		return inKept
+	})
+}
+
+// FilterResultHits applies the filter to every hit table and alignment
+// in the result directory; workers run it before archiving.
+func FilterResultHits(resultBase string, filter *HitFilter) error {
+	if !filter.active() {
+		return nil
+	}
+	tables, err := filepath.Glob(filepath.Join(resultBase, "*.m8"))
+	if err != nil {
+		return err
+	}
+	for _, path := range tables {
+		if err := filter.FilterM8File(path); err != nil {
+			return err
+		}
+	}
+	alignments, err := filepath.Glob(filepath.Join(resultBase, "*.a3m"))
+	if err != nil {
+		return err
+	}
+	for _, path := range alignments {
+		if err := filter.FilterA3MFile(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package main
+
+// IdempotencySystem maps client-chosen idempotency keys to tickets, so a
+// retried submission (flaky network, notebook re-run) returns the ticket
+// created by the first attempt instead of new compute. Keys are scoped
+// per API key so users cannot collide with each other.
+type IdempotencySystem interface {
+	ResolveIdempotencyKey(apiKey string, key string) (Id, bool, error)
+	SaveIdempotencyKey(apiKey string, key string, id Id) error
+}
+
+// NewJobIdempotent submits a job, honoring an optional idempotency key.
+// With an empty key it behaves exactly like JobSystem.NewJob.
+func NewJobIdempotent(jobsystem JobSystem, request JobRequest, jobsbase string, allowResubmit bool, idempotencyKey string) (Ticket, error) {
+	store, supported := UnwrapJobSystem(jobsystem).(IdempotencySystem)
+	if idempotencyKey != "" && supported {
+		id, found, err := store.ResolveIdempotencyKey(request.ApiKey, idempotencyKey)
+		if err != nil {
+			return Ticket{Id: request.Id, Status: StatusUnknown}, err
+		}
+		if found {
+			return jobsystem.GetTicket(id)
+		}
+	}
+	ticket, err := jobsystem.NewJob(request, jobsbase, allowResubmit)
+	if err != nil {
+		return ticket, err
+	}
+	if idempotencyKey != "" && supported {
+		if err := store.SaveIdempotencyKey(request.ApiKey, idempotencyKey, ticket.Id); err != nil {
+			return ticket, err
+		}
+	}
+	return ticket, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func testBoltJobSystem(t *testing.T) *BoltJobSystem {
+	t.Helper()
+	jobsystem, err := MakeBoltJobSystem(filepath.Join(t.TempDir(), "jobsystem.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { jobsystem.Close() })
+	return jobsystem
+}
+
+func testJobRequest(id string, apiKey string) JobRequest {
+	return JobRequest{
+		Id:     Id(id),
+		Type:   JobTypeMsa,
+		Job:    json.RawMessage(`{"query":">q\nACDEF\n"}`),
+		ApiKey: apiKey,
+	}
+}
+
+func TestNewJobIdempotentReturnsFirstTicket(t *testing.T) {
+	jobsystem := testBoltJobSystem(t)
+	jobsbase := t.TempDir()
+
+	first, err := NewJobIdempotent(jobsystem, testJobRequest("ticket-a", "key"), jobsbase, false, "retry-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Id != "ticket-a" {
+		t.Fatalf("first submission: got ticket %s, want ticket-a", first.Id)
+	}
+	// a retry with the same key but different content must return the
+	// original ticket instead of creating new work
+	second, err := NewJobIdempotent(jobsystem, testJobRequest("ticket-b", "key"), jobsbase, false, "retry-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.Id != "ticket-a" {
+		t.Errorf("retried submission: got ticket %s, want ticket-a", second.Id)
+	}
+	if status, err := jobsystem.Status("ticket-b"); err != nil || status != StatusUnknown {
+		t.Errorf("retried submission created ticket-b (status %s, err %v)", status, err)
+	}
+}
+
+func TestNewJobIdempotentScopedPerApiKey(t *testing.T) {
+	jobsystem := testBoltJobSystem(t)
+	jobsbase := t.TempDir()
+
+	if _, err := NewJobIdempotent(jobsystem, testJobRequest("ticket-a", "alice"), jobsbase, false, "retry-1"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewJobIdempotent(jobsystem, testJobRequest("ticket-b", "bob"), jobsbase, false, "retry-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Id != "ticket-b" {
+		t.Errorf("other user's submission: got ticket %s, want ticket-b", other.Id)
+	}
+}
+
+func TestNewJobIdempotentEmptyKey(t *testing.T) {
+	jobsystem := testBoltJobSystem(t)
+	jobsbase := t.TempDir()
+
+	for _, id := range []string{"ticket-a", "ticket-b"} {
+		ticket, err := NewJobIdempotent(jobsystem, testJobRequest(id, "key"), jobsbase, false, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ticket.Id != Id(id) {
+			t.Errorf("submission without key: got ticket %s, want %s", ticket.Id, id)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// IndexArtifact is the state of one expected index file in a dry-run
+// report.
+type IndexArtifact struct {
+	Name    string `json:"name"`
+	Present bool   `json:"present"`
+	// Outdated marks artifacts older than the database data they were
+	// built from.
+	Outdated bool   `json:"outdated,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// IndexPlan is the dry-run result of an IndexJob: what would be built,
+// how big it would be and roughly how long it would take — so
+// operators can plan maintenance windows before committing hours of
+// CPU.
+type IndexPlan struct {
+	Database  string          `json:"database"`
+	UpToDate  bool            `json:"up_to_date"`
+	Artifacts []IndexArtifact `json:"artifacts"`
+	// EstimatedIndexBytes extrapolates the index size from the
+	// sequence data.
+	EstimatedIndexBytes int64 `json:"estimated_index_bytes"`
+	// EstimatedBuildSeconds assumes indexBuildThroughput of input
+	// processed per second.
+	EstimatedBuildSeconds int64 `json:"estimated_build_seconds"`
+}
+
+// indexSizeFactor approximates how much larger the precomputed index is
+// than the sequence data it covers.
+const indexSizeFactor = 4
+
+// indexBuildThroughput is the assumed createindex processing rate, in
+// bytes of input per second; deliberately conservative.
+const indexBuildThroughput = 50 << 20
+
+// databaseDataSize sums the database files that are not index
+// artifacts.
+func databaseDataSize(root string, name string) (int64, time.Time) {
+	matches, err := filepath.Glob(filepath.Join(root, name+"*"))
+	if err != nil {
+		return 0, time.Time{}
+	}
+	total := int64(0)
+	var newest time.Time
+	for _, match := range matches {
+		if strings.Contains(filepath.Base(match), ".idx") {
+			continue
+		}
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		total += info.Size()
+		if info.ModTime().After(newest) {
+			newest = info.ModTime()
+		}
+	}
+	return total, newest
+}
+
+// PlanIndexJob inspects a database and reports what an IndexJob would
+// do, without building anything.
+func PlanIndexJob(roots DbRoots, name string) (*IndexPlan, error) {
+	root, found := roots.Locate(name)
+	if !found {
+		return nil, fmt.Errorf("database %s is not installed", name)
+	}
+	dataSize, dataChanged := databaseDataSize(root, name)
+	plan := &IndexPlan{Database: name, UpToDate: true}
+	indexes, err := filepath.Glob(filepath.Join(root, name+"*.idx*"))
+	if err != nil {
+		return nil, err
+	}
+	if len(indexes) == 0 {
+		plan.UpToDate = false
+		plan.Artifacts = append(plan.Artifacts, IndexArtifact{
+			Name: name + ".idx", Reason: "no precomputed index exists",
+		})
+	}
+	for _, index := range indexes {
+		artifact := IndexArtifact{Name: filepath.Base(index), Present: true}
+		if info, err := os.Stat(index); err == nil && info.ModTime().Before(dataChanged) {
+			artifact.Outdated = true
+			artifact.Reason = "database data is newer than the index"
+			plan.UpToDate = false
+		}
+		plan.Artifacts = append(plan.Artifacts, artifact)
+	}
+	if params, err := ReadDbParams(root, name); err == nil && params.GpuCapable {
+		artifact := IndexArtifact{Name: name + paddedDbSuffix, Present: HasPaddedDatabase(root, name)}
+		if !artifact.Present {
+			artifact.Reason = "gpu-capable database has no padded companion"
+			plan.UpToDate = false
+		}
+		plan.Artifacts = append(plan.Artifacts, artifact)
+	}
+	if !plan.UpToDate {
+		plan.EstimatedIndexBytes = dataSize * indexSizeFactor
+		plan.EstimatedBuildSeconds = dataSize / indexBuildThroughput
+		if dataSize > 0 && plan.EstimatedBuildSeconds == 0 {
+			plan.EstimatedBuildSeconds = 1
+		}
+	}
+	return plan, nil
+}
+
+// RegisterIndexPlanRoute adds GET /admin/databases/{name}/indexplan,
+// the dry-run view of an IndexJob.
+func RegisterIndexPlanRoute(r *mux.Router, roots DbRoots, config ConfigAdmin) {
+	if config.Token == "" {
+		return
+	}
+	r.HandleFunc("/admin/databases/{name}/indexplan", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		plan, err := PlanIndexJob(roots, mux.Vars(req)["name"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(plan)
+	})).Methods("GET")
+}
@@ -0,0 +1,81 @@
+package main
+
+import "encoding/json"
+
+const (
+	JobTypeMsa             JobType = "msa"
+	JobTypeSearch          JobType = "search"
+	JobTypeStructureSearch JobType = "structuresearch"
+	JobTypePair            JobType = "pair"
+	JobTypeIndex           JobType = "index"
+)
+
+// MsaJob builds the ColabFold MSAs (uniref/environmental) for one or
+// more query sequences.
+type MsaJob struct {
+	Mode  string   `json:"mode"`
+	Query string   `json:"query"`
+	DBs   []string `json:"dbs,omitempty"`
+	// Filter is applied to the alignments before archiving.
+	Filter *HitFilter `json:"filter,omitempty"`
+}
+
+// SearchJob is a plain mmseqs sequence search against the configured
+// databases.
+type SearchJob struct {
+	Query    string   `json:"query"`
+	Database []string `json:"database"`
+	Mode     string   `json:"mode"`
+	// OutputColumns picks the --format-output columns; empty means the
+	// classic 12-column m8 layout. Validated against the allowlist at
+	// submission.
+	OutputColumns []string `json:"output_columns,omitempty"`
+	// Filter is applied to the hit tables before archiving.
+	Filter *HitFilter `json:"filter,omitempty"`
+}
+
+// StructureSearchJob runs a foldseek search for an uploaded structure.
+type StructureSearchJob struct {
+	Query    string   `json:"query"`
+	Database []string `json:"database"`
+	Mode     string   `json:"mode"`
+	// OutputColumns picks the --format-output columns like SearchJob.
+	OutputColumns []string `json:"output_columns,omitempty"`
+	// Filter is applied to the hit tables before archiving.
+	Filter *HitFilter `json:"filter,omitempty"`
+}
+
+// PairJob computes paired MSAs for protein complexes.
+type PairJob struct {
+	Mode  string `json:"mode"`
+	Query string `json:"query"`
+}
+
+// IndexJob (re)builds the precomputed index of a database.
+type IndexJob struct {
+	Database []string `json:"database"`
+}
+
+// jobDatabases extracts the target databases from a raw job payload;
+// job types without database targets yield nil.
+func jobDatabases(jobType JobType, raw []byte) []string {
+	switch jobType {
+	case JobTypeSearch, JobTypeStructureSearch, JobTypeIndex:
+		var payload struct {
+			Database []string `json:"database"`
+		}
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil
+		}
+		return payload.Database
+	case JobTypeMsa:
+		var payload struct {
+			DBs []string `json:"dbs"`
+		}
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return nil
+		}
+		return payload.DBs
+	}
+	return nil
+}
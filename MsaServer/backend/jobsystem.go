@@ -0,0 +1,210 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"time"
+)
+
+var ErrInvalidTicket = errors.New("invalid ticket")
+
+var ErrIllegalTransition = errors.New("illegal status transition")
+
+type Id string
+
+type Status string
+
+const (
+	StatusPending  Status = "PENDING"
+	StatusRunning  Status = "RUNNING"
+	StatusComplete Status = "COMPLETE"
+	StatusError    Status = "ERROR"
+	StatusUnknown  Status = "UNKNOWN"
+	// StatusDeadLetter marks tickets that failed repeatedly; they are
+	// kept with their error history instead of being retried forever.
+	StatusDeadLetter Status = "DEADLETTER"
+	// StatusExpired marks tickets that sat queued longer than the
+	// configured TTL; running them days later would only surprise users
+	// who gave up long ago.
+	StatusExpired Status = "EXPIRED"
+	// StatusCancelled marks tickets that were cancelled by an operator
+	// or by their owner before completion.
+	StatusCancelled Status = "CANCELLED"
+)
+
+type Ticket struct {
+	Id     Id     `json:"id"`
+	Status Status `json:"status"`
+	// Stats is populated for COMPLETE tickets of MSA jobs from the
+	// stats.json written next to the result archive.
+	Stats map[string]MSAStats `json:"stats,omitempty"`
+	// History lists the status transitions of the ticket in order, so
+	// users can tell queue wait from run time.
+	History []TicketTransition `json:"history,omitempty"`
+}
+
+type TicketTransition struct {
+	Status Status    `json:"status"`
+	At     time.Time `json:"at"`
+	Worker string    `json:"worker,omitempty"`
+}
+
+// HistorySystem is implemented by job systems that record every status
+// transition with timestamp and worker identity.
+type HistorySystem interface {
+	History(id Id) ([]TicketTransition, error)
+}
+
+// UnwrapJobSystem follows wrapper job systems (pause switch, external
+// queues) down to the implementation that holds the ticket records, so
+// optional capabilities can be discovered with type assertions.
+func UnwrapJobSystem(jobsystem JobSystem) JobSystem {
+	type unwrapper interface{ Unwrap() JobSystem }
+	for {
+		wrapper, ok := jobsystem.(unwrapper)
+		if !ok {
+			return jobsystem
+		}
+		jobsystem = wrapper.Unwrap()
+	}
+}
+
+// AdminTicket is the operator view of a ticket.
+type AdminTicket struct {
+	Id       Id        `json:"id"`
+	Status   Status    `json:"status"`
+	Type     JobType   `json:"type"`
+	ApiKey   string    `json:"api_key,omitempty"`
+	Priority Priority  `json:"priority"`
+	Created  time.Time `json:"created"`
+	// Databases are the concrete databases the job targets, used by
+	// upgrade tooling to find tickets still referencing an old version.
+	Databases []string `json:"databases,omitempty"`
+}
+
+// AdminSystem is implemented by job systems that support the admin
+// queue-management API.
+type AdminSystem interface {
+	// ListTickets returns tickets in the given statuses, oldest first;
+	// an empty filter lists everything.
+	ListTickets(statuses []Status) ([]AdminTicket, error)
+	// Reprioritize changes the priority of a PENDING ticket.
+	Reprioritize(id Id, priority Priority) error
+	// Cancel stops a PENDING ticket from ever running and marks a
+	// RUNNING ticket so its result is discarded.
+	Cancel(id Id) error
+	// Requeue forces a ticket of any non-PENDING status back into the
+	// queue.
+	Requeue(id Id) error
+}
+
+// legalTransitions is the ticket state machine. SetStatus refuses
+// transitions not listed here, so a stray status write can no longer
+// resurrect a completed or cancelled ticket.
+var legalTransitions = map[Status][]Status{
+	StatusPending:    {StatusRunning, StatusCancelled, StatusExpired, StatusError},
+	StatusRunning:    {StatusComplete, StatusError, StatusCancelled, StatusPending, StatusDeadLetter},
+	StatusError:      {StatusPending, StatusDeadLetter},
+	StatusDeadLetter: {StatusPending},
+	StatusExpired:    {StatusPending},
+	StatusCancelled:  {StatusPending},
+}
+
+// LegalTransition reports whether a ticket may move from one status to
+// another. Resubmission of COMPLETE tickets goes through NewJob with
+// allowResubmit and is deliberately not a SetStatus edge.
+func LegalTransition(from Status, to Status) bool {
+	for _, allowed := range legalTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// CasSystem is implemented by job systems whose SetStatus enforces the
+// state machine; CompareAndSetStatus additionally fails when the ticket
+// is no longer in the expected status, for callers that must not stomp
+// on concurrent transitions.
+type CasSystem interface {
+	CompareAndSetStatus(id Id, from Status, to Status) error
+}
+
+type JobType string
+
+// Priority orders pending tickets in Dequeue; higher values run first.
+// Interactive (notebook) submissions outrank batch submissions so a large
+// batch upload cannot starve interactive users.
+type Priority int
+
+const (
+	PriorityBatch       Priority = 0
+	PriorityInteractive Priority = 10
+)
+
+// ParsePriority maps the user-facing priority class to its rank. Unknown
+// or empty values default to interactive, which keeps the behavior of
+// existing clients that do not send the field.
+func ParsePriority(value string) Priority {
+	switch value {
+	case "batch":
+		return PriorityBatch
+	default:
+		return PriorityInteractive
+	}
+}
+
+type JobRequest struct {
+	Id       Id          `json:"id"`
+	Status   Status      `json:"status"`
+	Type     JobType     `json:"type"`
+	Job      interface{} `json:"job"`
+	Email    string      `json:"email"`
+	Priority Priority    `json:"priority"`
+	// ApiKey identifies the submitting user for fair-share scheduling;
+	// anonymous submissions share one bucket.
+	ApiKey string `json:"api_key"`
+	// NotBefore delays execution: the ticket stays PENDING but is not
+	// dequeued before this time, so batch work can be queued during the
+	// day and run overnight. The zero value means run immediately.
+	NotBefore time.Time `json:"not_before,omitempty"`
+	// DependsOn holds the ticket PENDING until all listed tickets are
+	// COMPLETE; if any of them fails, this ticket fails too. The result
+	// directories of the dependencies are made available to the job.
+	DependsOn []Id `json:"depends_on,omitempty"`
+	// Origin references the ticket this one was resubmitted from, for
+	// provenance.
+	Origin Id `json:"origin,omitempty"`
+}
+
+// PayloadSystem is implemented by job systems that can return the stored
+// job parameters of a ticket.
+type PayloadSystem interface {
+	JobPayload(id Id) (JobType, []byte, error)
+}
+
+// DependencySystem is implemented by job systems that can return the
+// dependencies a ticket was submitted with.
+type DependencySystem interface {
+	Dependencies(id Id) ([]Id, error)
+}
+
+// DependencyResultPaths returns the result directories of a ticket's
+// dependencies, in declaration order, for use as job inputs.
+func DependencyResultPaths(jobsbase string, dependencies []Id) []string {
+	paths := make([]string, 0, len(dependencies))
+	for _, dependency := range dependencies {
+		paths = append(paths, filepath.Join(jobsbase, string(dependency)))
+	}
+	return paths
+}
+
+type JobSystem interface {
+	SetStatus(id Id, status Status) error
+	Status(id Id) (Status, error)
+	GetTicket(id Id) (Ticket, error)
+	NewJob(request JobRequest, jobsbase string, allowResubmit bool) (Ticket, error)
+	MultiStatus(ids []string) ([]Ticket, error)
+	Dequeue() (*Ticket, error)
+	QueueLength() (int, error)
+}
@@ -0,0 +1,880 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltTickets     = []byte("tickets")
+	boltQueue       = []byte("queue")
+	boltCpuUsage    = []byte("cpuusage")
+	boltIdempotency = []byte("idempotency")
+)
+
+// boltIdempotencyKey scopes idempotency keys per API key; the zero byte
+// cannot occur in either part.
+func boltIdempotencyKey(apiKey string, key string) []byte {
+	scoped := make([]byte, 0, len(apiKey)+1+len(key))
+	scoped = append(scoped, apiKey...)
+	scoped = append(scoped, 0)
+	return append(scoped, key...)
+}
+
+// boltQueueKey sorts queue entries by descending priority first and
+// insertion order second, so a plain forward cursor scan dequeues the
+// oldest ticket of the highest priority class.
+func boltQueueKey(priority Priority, sequence uint64) []byte {
+	key := make([]byte, 9)
+	key[0] = byte(255 - int(priority))
+	binary.BigEndian.PutUint64(key[1:], sequence)
+	return key
+}
+
+type boltTicket struct {
+	Status    Status          `json:"status"`
+	Type      JobType         `json:"type"`
+	Email     string          `json:"email"`
+	ApiKey    string          `json:"api_key"`
+	Priority  Priority        `json:"priority"`
+	NotBefore time.Time       `json:"not_before,omitempty"`
+	DependsOn []Id            `json:"depends_on,omitempty"`
+	Job       []byte          `json:"job"`
+	Created   time.Time       `json:"created"`
+	Failures  []FailureRecord `json:"failures,omitempty"`
+	Worker    string          `json:"worker,omitempty"`
+	Heartbeat time.Time       `json:"heartbeat,omitempty"`
+	Requeues  int             `json:"requeues,omitempty"`
+	// Transitions records the ticket's status history with timestamps
+	// and, where known, the executing worker.
+	Transitions []TicketTransition `json:"transitions,omitempty"`
+}
+
+// setStatus changes the ticket status and appends it to the recorded
+// history.
+func (t *boltTicket) setStatus(status Status, worker string) {
+	t.Status = status
+	t.Transitions = append(t.Transitions, TicketTransition{
+		Status: status,
+		At:     time.Now(),
+		Worker: worker,
+	})
+}
+
+// BoltJobSystem stores local job state in an embedded BoltDB file. Every
+// status change is a single serialized transaction, so a crash or power
+// loss can never leave a half-written status file or a ticket that is in
+// the queue but has no record.
+type BoltJobSystem struct {
+	db    *bolt.DB
+	rotor *fairRotor
+	// QueuedTTL expires tickets that stay PENDING longer than this;
+	// zero disables expiry.
+	QueuedTTL time.Duration
+	// types is non-nil when per-job-type queue weights are configured.
+	types *weightedPicker
+	// affinity batches searches against the same database back-to-back
+	// to keep its index hot in the page cache.
+	affinity databaseAffinity
+	// workers holds database-locality advertisements for DequeueFor.
+	workers workerAdvertisements
+}
+
+func (j *BoltJobSystem) AdvertiseWorker(worker string, databases []string) error {
+	j.workers.advertise(worker, databases)
+	return nil
+}
+
+// Workers lists the workers with fresh advertisements for the admin
+// status view.
+func (j *BoltJobSystem) Workers() ([]WorkerInfo, error) {
+	return j.workers.snapshot(), nil
+}
+
+// DequeueFor implements LocalityRouter: it only hands out tickets whose
+// databases the worker holds locally.
+func (j *BoltJobSystem) DequeueFor(worker string) (*Ticket, error) {
+	return j.dequeueAllowed(func(ticket *boltTicket) bool {
+		return j.workers.holds(worker, jobDatabases(ticket.Type, ticket.Job))
+	})
+}
+
+// SetTypeWeights enables weighted dequeuing across job types, e.g.
+// {"msa": 70, "structuresearch": 20, "index": 10}.
+func (j *BoltJobSystem) SetTypeWeights(weights map[string]int) {
+	j.types = newWeightedPicker(weights)
+}
+
+// pickPendingType chooses which job type to serve next according to the
+// configured weights; it returns ok=false when nothing is dequeueable.
+func (j *BoltJobSystem) pickPendingType(tx *bolt.Tx) (JobType, bool, error) {
+	seen := make(map[JobType]bool)
+	var available []string
+	err := tx.Bucket(boltQueue).ForEach(func(key, value []byte) error {
+		ticket, err := j.getTicket(tx, Id(value))
+		if err != nil {
+			return err
+		}
+		if ticket == nil || ticket.Status != StatusPending {
+			return nil
+		}
+		if !ticket.NotBefore.IsZero() && ticket.NotBefore.After(time.Now()) {
+			return nil
+		}
+		if !seen[ticket.Type] {
+			seen[ticket.Type] = true
+			available = append(available, string(ticket.Type))
+		}
+		return nil
+	})
+	if err != nil || len(available) == 0 {
+		return "", false, err
+	}
+	return JobType(j.types.Pick(available)), true, nil
+}
+
+func MakeBoltJobSystem(path string) (*BoltJobSystem, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 10 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltTickets); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(boltQueue); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(boltCpuUsage); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltIdempotency)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltJobSystem{db: db, rotor: newFairRotor()}, nil
+}
+
+func (j *BoltJobSystem) Close() error {
+	return j.db.Close()
+}
+
+func (j *BoltJobSystem) getTicket(tx *bolt.Tx, id Id) (*boltTicket, error) {
+	value := tx.Bucket(boltTickets).Get([]byte(id))
+	if value == nil {
+		return nil, nil
+	}
+	var ticket boltTicket
+	if err := json.Unmarshal(value, &ticket); err != nil {
+		return nil, err
+	}
+	return &ticket, nil
+}
+
+func (j *BoltJobSystem) putTicket(tx *bolt.Tx, id Id, ticket *boltTicket) error {
+	value, err := json.Marshal(ticket)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(boltTickets).Put([]byte(id), value)
+}
+
+func (j *BoltJobSystem) SetStatus(id Id, status Status) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil {
+			return ErrInvalidTicket
+		}
+		if !LegalTransition(ticket.Status, status) {
+			return ErrIllegalTransition
+		}
+		ticket.setStatus(status, "")
+		return j.putTicket(tx, id, ticket)
+	})
+}
+
+// CompareAndSetStatus transitions the ticket only if it still is in the
+// expected status; the single-writer transaction makes the
+// check-and-set atomic.
+func (j *BoltJobSystem) CompareAndSetStatus(id Id, from Status, to Status) error {
+	if !LegalTransition(from, to) {
+		return ErrIllegalTransition
+	}
+	return j.db.Update(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil {
+			return ErrInvalidTicket
+		}
+		if ticket.Status != from {
+			return ErrIllegalTransition
+		}
+		ticket.setStatus(to, "")
+		return j.putTicket(tx, id, ticket)
+	})
+}
+
+func (j *BoltJobSystem) Status(id Id) (Status, error) {
+	status := StatusUnknown
+	err := j.db.View(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket != nil {
+			status = ticket.Status
+		}
+		return nil
+	})
+	return status, err
+}
+
+func (j *BoltJobSystem) GetTicket(id Id) (Ticket, error) {
+	result := Ticket{Id: id, Status: StatusUnknown}
+	err := j.db.View(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket != nil {
+			result.Status = ticket.Status
+			result.History = ticket.Transitions
+		}
+		return nil
+	})
+	return result, err
+}
+
+// History implements HistorySystem.
+func (j *BoltJobSystem) History(id Id) ([]TicketTransition, error) {
+	ticket, err := j.GetTicket(id)
+	return ticket.History, err
+}
+
+func (j *BoltJobSystem) NewJob(request JobRequest, jobsbase string, allowResubmit bool) (Ticket, error) {
+	result := Ticket{Id: request.Id, Status: StatusUnknown}
+	err := j.db.Update(func(tx *bolt.Tx) error {
+		existing, err := j.getTicket(tx, request.Id)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.Status == StatusComplete && !allowResubmit {
+			result.Status = existing.Status
+			return nil
+		}
+		// a duplicate submission of a queued or in-flight ticket returns
+		// it untouched; resetting a RUNNING ticket to PENDING would break
+		// the worker's later RUNNING -> COMPLETE transition and discard
+		// the finished work. Only terminal failures are re-enqueued.
+		if existing != nil && !isTerminal(existing.Status) {
+			result.Status = existing.Status
+			return nil
+		}
+		job, err := json.Marshal(request.Job)
+		if err != nil {
+			return err
+		}
+		ticket := &boltTicket{
+			Type:      request.Type,
+			Email:     request.Email,
+			ApiKey:    request.ApiKey,
+			Priority:  request.Priority,
+			NotBefore: request.NotBefore,
+			DependsOn: request.DependsOn,
+			Job:       job,
+			Created:   time.Now(),
+		}
+		ticket.setStatus(StatusPending, "")
+		if err := j.putTicket(tx, request.Id, ticket); err != nil {
+			return err
+		}
+		if err := j.enqueue(tx, request.Id, request.Priority); err != nil {
+			return err
+		}
+		result.Status = StatusPending
+		return nil
+	})
+	return result, err
+}
+
+func (j *BoltJobSystem) MultiStatus(ids []string) ([]Ticket, error) {
+	result := make([]Ticket, 0, len(ids))
+	err := j.db.View(func(tx *bolt.Tx) error {
+		for _, id := range ids {
+			ticket, err := j.getTicket(tx, Id(id))
+			if err != nil {
+				return err
+			}
+			status := StatusUnknown
+			if ticket != nil {
+				status = ticket.Status
+			}
+			result = append(result, Ticket{Id: Id(id), Status: status})
+		}
+		return nil
+	})
+	return result, err
+}
+
+// Dequeue removes one queue entry and marks its ticket RUNNING in the
+// same transaction; either both happen or neither does. Within the
+// highest pending priority class the per-key queue heads are served
+// fair-share across API keys.
+func (j *BoltJobSystem) Dequeue() (*Ticket, error) {
+	return j.dequeueAllowed(nil)
+}
+
+func (j *BoltJobSystem) dequeueAllowed(allowed func(*boltTicket) bool) (*Ticket, error) {
+	var result *Ticket
+	err := j.db.Update(func(tx *bolt.Tx) error {
+		var chosenType JobType
+		typeFiltered := false
+		if j.types != nil {
+			var ok bool
+			var err error
+			chosenType, ok, err = j.pickPendingType(tx)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return nil
+			}
+			typeFiltered = true
+		}
+		queue := tx.Bucket(boltQueue)
+		cursor := queue.Cursor()
+		heads := make(map[string][]byte)
+		tickets := make(map[string]*boltTicket)
+		var keys []string
+		var stale [][]byte
+		var headPriority byte
+		for key, value := cursor.First(); key != nil; key, value = cursor.Next() {
+			ticket, err := j.getTicket(tx, Id(value))
+			if err != nil {
+				return err
+			}
+			// collect queue entries whose ticket was already
+			// cancelled or superseded by a resubmission; entries
+			// whose priority no longer matches the ticket were
+			// replaced by a reprioritization
+			if ticket == nil || ticket.Status != StatusPending || key[0] != byte(255-int(ticket.Priority)) {
+				stale = append(stale, append([]byte(nil), key...))
+				continue
+			}
+			// expire tickets that were queued longer than the TTL
+			if j.QueuedTTL > 0 && time.Since(ticket.Created) > j.QueuedTTL {
+				ticket.setStatus(StatusExpired, "")
+				if err := j.putTicket(tx, Id(value), ticket); err != nil {
+					return err
+				}
+				stale = append(stale, append([]byte(nil), key...))
+				continue
+			}
+			// scheduled tickets stay queued until their time comes
+			if !ticket.NotBefore.IsZero() && ticket.NotBefore.After(time.Now()) {
+				continue
+			}
+			held, failed, err := j.checkDependencies(tx, ticket)
+			if err != nil {
+				return err
+			}
+			if failed {
+				ticket.setStatus(StatusError, "")
+				if err := j.putTicket(tx, Id(value), ticket); err != nil {
+					return err
+				}
+				stale = append(stale, append([]byte(nil), key...))
+				continue
+			}
+			if held {
+				continue
+			}
+			if typeFiltered && ticket.Type != chosenType {
+				continue
+			}
+			if allowed != nil && !allowed(ticket) {
+				continue
+			}
+			if len(keys) > 0 && key[0] != headPriority {
+				break
+			}
+			headPriority = key[0]
+			if _, seen := heads[ticket.ApiKey]; seen {
+				continue
+			}
+			heads[ticket.ApiKey] = append([]byte(nil), key...)
+			tickets[ticket.ApiKey] = ticket
+			keys = append(keys, ticket.ApiKey)
+		}
+		for _, key := range stale {
+			if err := queue.Delete(key); err != nil {
+				return err
+			}
+		}
+		if len(keys) == 0 {
+			return nil
+		}
+		candidateDatabases := make(map[string][]string)
+		for _, key := range keys {
+			candidateDatabases[key] = jobDatabases(tickets[key].Type, tickets[key].Job)
+		}
+		chosen, matched := j.affinity.Choose(candidateDatabases)
+		if !matched {
+			chosen = j.rotor.Pick(keys)
+		}
+		j.affinity.Served(candidateDatabases[chosen])
+		id := Id(queue.Get(heads[chosen]))
+		if err := queue.Delete(heads[chosen]); err != nil {
+			return err
+		}
+		ticket := tickets[chosen]
+		ticket.setStatus(StatusRunning, "")
+		if err := j.putTicket(tx, id, ticket); err != nil {
+			return err
+		}
+		result = &Ticket{Id: id, Status: StatusRunning}
+		return nil
+	})
+	return result, err
+}
+
+// checkDependencies reports whether a ticket has to keep waiting for its
+// dependencies (held) or can never run because one of them failed.
+func (j *BoltJobSystem) checkDependencies(tx *bolt.Tx, ticket *boltTicket) (held bool, failed bool, err error) {
+	for _, dependency := range ticket.DependsOn {
+		parent, err := j.getTicket(tx, dependency)
+		if err != nil {
+			return false, false, err
+		}
+		if parent == nil {
+			return false, true, nil
+		}
+		switch parent.Status {
+		case StatusComplete:
+		case StatusError, StatusDeadLetter:
+			return false, true, nil
+		default:
+			held = true
+		}
+	}
+	return held, false, nil
+}
+
+// enqueue appends a queue entry for an existing ticket.
+func (j *BoltJobSystem) enqueue(tx *bolt.Tx, id Id, priority Priority) error {
+	queue := tx.Bucket(boltQueue)
+	sequence, err := queue.NextSequence()
+	if err != nil {
+		return err
+	}
+	return queue.Put(boltQueueKey(priority, sequence), []byte(id))
+}
+
+func (j *BoltJobSystem) RecordFailure(id Id, message string, maxFailures int) (Status, error) {
+	status := StatusError
+	err := j.db.Update(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil {
+			return ErrInvalidTicket
+		}
+		ticket.Failures = append(ticket.Failures, FailureRecord{
+			At:      time.Now(),
+			Message: message,
+		})
+		if maxFailures > 0 && len(ticket.Failures) >= maxFailures {
+			status = StatusDeadLetter
+		}
+		ticket.setStatus(status, "")
+		return j.putTicket(tx, id, ticket)
+	})
+	return status, err
+}
+
+func (j *BoltJobSystem) DeadLetters() ([]DeadLetter, error) {
+	var result []DeadLetter
+	err := j.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTickets).ForEach(func(key, value []byte) error {
+			var ticket boltTicket
+			if err := json.Unmarshal(value, &ticket); err != nil {
+				return err
+			}
+			if ticket.Status != StatusDeadLetter {
+				return nil
+			}
+			result = append(result, DeadLetter{Id: Id(key), Failures: ticket.Failures})
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (j *BoltJobSystem) RequeueDeadLetter(id Id) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil || ticket.Status != StatusDeadLetter {
+			return ErrInvalidTicket
+		}
+		ticket.setStatus(StatusPending, "")
+		if err := j.putTicket(tx, id, ticket); err != nil {
+			return err
+		}
+		return j.enqueue(tx, id, ticket.Priority)
+	})
+}
+
+func (j *BoltJobSystem) ListTickets(statuses []Status) ([]AdminTicket, error) {
+	wanted := make(map[Status]bool, len(statuses))
+	for _, status := range statuses {
+		wanted[status] = true
+	}
+	var result []AdminTicket
+	err := j.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTickets).ForEach(func(key, value []byte) error {
+			var ticket boltTicket
+			if err := json.Unmarshal(value, &ticket); err != nil {
+				return err
+			}
+			if len(wanted) > 0 && !wanted[ticket.Status] {
+				return nil
+			}
+			result = append(result, AdminTicket{
+				Id:        Id(key),
+				Status:    ticket.Status,
+				Type:      ticket.Type,
+				ApiKey:    ticket.ApiKey,
+				Priority:  ticket.Priority,
+				Created:   ticket.Created,
+				Databases: jobDatabases(ticket.Type, ticket.Job),
+			})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Created.Before(result[b].Created)
+	})
+	return result, nil
+}
+
+func (j *BoltJobSystem) Reprioritize(id Id, priority Priority) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil || ticket.Status != StatusPending {
+			return ErrInvalidTicket
+		}
+		ticket.Priority = priority
+		if err := j.putTicket(tx, id, ticket); err != nil {
+			return err
+		}
+		// the old queue entry becomes stale once the ticket is seen
+		// under the new one; re-enqueue at the new priority
+		return j.enqueue(tx, id, priority)
+	})
+}
+
+func (j *BoltJobSystem) Cancel(id Id) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil {
+			return ErrInvalidTicket
+		}
+		switch ticket.Status {
+		case StatusPending, StatusRunning:
+			ticket.setStatus(StatusCancelled, "")
+			return j.putTicket(tx, id, ticket)
+		}
+		return ErrInvalidTicket
+	})
+}
+
+func (j *BoltJobSystem) Requeue(id Id) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil || ticket.Status == StatusPending {
+			return ErrInvalidTicket
+		}
+		ticket.setStatus(StatusPending, "")
+		if err := j.putTicket(tx, id, ticket); err != nil {
+			return err
+		}
+		return j.enqueue(tx, id, ticket.Priority)
+	})
+}
+
+func (j *BoltJobSystem) Dependencies(id Id) ([]Id, error) {
+	var dependencies []Id
+	err := j.db.View(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil {
+			return ErrInvalidTicket
+		}
+		dependencies = append([]Id(nil), ticket.DependsOn...)
+		return nil
+	})
+	return dependencies, err
+}
+
+func (j *BoltJobSystem) JobPayload(id Id) (JobType, []byte, error) {
+	var jobType JobType
+	var payload []byte
+	err := j.db.View(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil {
+			return ErrInvalidTicket
+		}
+		jobType = ticket.Type
+		payload = append([]byte(nil), ticket.Job...)
+		return nil
+	})
+	return jobType, payload, err
+}
+
+func (j *BoltJobSystem) FinishedBefore(cutoff time.Time) ([]ArchivedTicket, error) {
+	var result []ArchivedTicket
+	err := j.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTickets).ForEach(func(key, value []byte) error {
+			var ticket boltTicket
+			if err := json.Unmarshal(value, &ticket); err != nil {
+				return err
+			}
+			if !isTerminal(ticket.Status) {
+				return nil
+			}
+			updated := ticket.Created
+			if len(ticket.Transitions) > 0 {
+				updated = ticket.Transitions[len(ticket.Transitions)-1].At
+			}
+			if !updated.Before(cutoff) {
+				return nil
+			}
+			result = append(result, ArchivedTicket{
+				Id:       Id(key),
+				Status:   ticket.Status,
+				Type:     ticket.Type,
+				ApiKey:   ticket.ApiKey,
+				Job:      json.RawMessage(append([]byte(nil), ticket.Job...)),
+				Created:  ticket.Created,
+				History:  ticket.Transitions,
+				Failures: ticket.Failures,
+			})
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (j *BoltJobSystem) PurgeTicket(id Id) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTickets).Delete([]byte(id))
+	})
+}
+
+func (j *BoltJobSystem) ResolveIdempotencyKey(apiKey string, key string) (Id, bool, error) {
+	var id Id
+	found := false
+	err := j.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltIdempotency).Get(boltIdempotencyKey(apiKey, key))
+		if value != nil {
+			id = Id(value)
+			found = true
+		}
+		return nil
+	})
+	return id, found, err
+}
+
+func (j *BoltJobSystem) SaveIdempotencyKey(apiKey string, key string, id Id) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltIdempotency)
+		scoped := boltIdempotencyKey(apiKey, key)
+		if bucket.Get(scoped) != nil {
+			return nil
+		}
+		return bucket.Put(scoped, []byte(id))
+	})
+}
+
+func (j *BoltJobSystem) Heartbeat(id Id, worker string) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		ticket, err := j.getTicket(tx, id)
+		if err != nil {
+			return err
+		}
+		if ticket == nil || ticket.Status != StatusRunning {
+			return nil
+		}
+		ticket.Worker = worker
+		ticket.Heartbeat = time.Now()
+		// stamp the worker identity onto the RUNNING transition once
+		for i := len(ticket.Transitions) - 1; i >= 0; i-- {
+			if ticket.Transitions[i].Status == StatusRunning {
+				if ticket.Transitions[i].Worker == "" {
+					ticket.Transitions[i].Worker = worker
+				}
+				break
+			}
+		}
+		return j.putTicket(tx, id, ticket)
+	})
+}
+
+func (j *BoltJobSystem) RequeueDead(visibility time.Duration, maxRequeues int) (int, error) {
+	requeued := 0
+	err := j.db.Update(func(tx *bolt.Tx) error {
+		type deadTicket struct {
+			id     Id
+			ticket *boltTicket
+		}
+		var dead []deadTicket
+		err := tx.Bucket(boltTickets).ForEach(func(key, value []byte) error {
+			var ticket boltTicket
+			if err := json.Unmarshal(value, &ticket); err != nil {
+				return err
+			}
+			if ticket.Status != StatusRunning {
+				return nil
+			}
+			last := ticket.Heartbeat
+			if last.IsZero() {
+				last = ticket.Created
+			}
+			if time.Since(last) < visibility {
+				return nil
+			}
+			copied := ticket
+			dead = append(dead, deadTicket{id: Id(key), ticket: &copied})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, entry := range dead {
+			entry.ticket.Requeues++
+			entry.ticket.Heartbeat = time.Time{}
+			if entry.ticket.Requeues > maxRequeues {
+				entry.ticket.setStatus(StatusError, "")
+			} else {
+				entry.ticket.setStatus(StatusPending, "")
+				if err := j.enqueue(tx, entry.id, entry.ticket.Priority); err != nil {
+					return err
+				}
+				requeued++
+			}
+			if err := j.putTicket(tx, entry.id, entry.ticket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return requeued, err
+}
+
+func (j *BoltJobSystem) UsageCounts(apiKey string) (int, int, error) {
+	running, queued := 0, 0
+	err := j.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltTickets).ForEach(func(key, value []byte) error {
+			var ticket boltTicket
+			if err := json.Unmarshal(value, &ticket); err != nil {
+				return err
+			}
+			if ticket.ApiKey != apiKey {
+				return nil
+			}
+			switch ticket.Status {
+			case StatusRunning:
+				running++
+			case StatusPending:
+				queued++
+			}
+			return nil
+		})
+	})
+	return running, queued, err
+}
+
+type boltCpuRecord struct {
+	ApiKey  string `json:"api_key"`
+	Seconds int64  `json:"seconds"`
+}
+
+func (j *BoltJobSystem) CpuSecondsSince(apiKey string, since time.Time) (int64, error) {
+	var total int64
+	err := j.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(boltCpuUsage).Cursor()
+		start := make([]byte, 8)
+		binary.BigEndian.PutUint64(start, uint64(since.UnixNano()))
+		for key, value := cursor.Seek(start); key != nil; key, value = cursor.Next() {
+			var record boltCpuRecord
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			if record.ApiKey == apiKey {
+				total += record.Seconds
+			}
+		}
+		return nil
+	})
+	return total, err
+}
+
+func (j *BoltJobSystem) AddCpuSeconds(apiKey string, seconds int64) error {
+	return j.db.Update(func(tx *bolt.Tx) error {
+		usage := tx.Bucket(boltCpuUsage)
+		sequence, err := usage.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 16)
+		binary.BigEndian.PutUint64(key, uint64(time.Now().UnixNano()))
+		binary.BigEndian.PutUint64(key[8:], sequence)
+		value, err := json.Marshal(boltCpuRecord{ApiKey: apiKey, Seconds: seconds})
+		if err != nil {
+			return err
+		}
+		return usage.Put(key, value)
+	})
+}
+
+func (j *BoltJobSystem) QueueLength() (int, error) {
+	length := 0
+	err := j.db.View(func(tx *bolt.Tx) error {
+		length = tx.Bucket(boltQueue).Stats().KeyN
+		return nil
+	})
+	return length, err
+}
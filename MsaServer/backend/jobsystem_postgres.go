@@ -0,0 +1,958 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type ConfigPostgres struct {
+	DSN string `json:"dsn"`
+}
+
+// PostgresJobSystem keeps tickets, their parameters and every state
+// transition in Postgres, so job history survives restarts and multiple
+// server nodes can share one queue.
+type PostgresJobSystem struct {
+	db    *sql.DB
+	rotor *fairRotor
+	// QueuedTTL expires tickets that stay PENDING longer than this;
+	// zero disables expiry.
+	QueuedTTL time.Duration
+	// types is non-nil when per-job-type queue weights are configured.
+	types *weightedPicker
+	// affinity batches searches against the same database back-to-back
+	// to keep its index hot in the page cache.
+	affinity databaseAffinity
+}
+
+// SetTypeWeights enables weighted dequeuing across job types, e.g.
+// {"msa": 70, "structuresearch": 20, "index": 10}.
+func (j *PostgresJobSystem) SetTypeWeights(weights map[string]int) {
+	j.types = newWeightedPicker(weights)
+}
+
+// pendingTypes lists the job types that currently have dequeueable work.
+func (j *PostgresJobSystem) pendingTypes() ([]string, error) {
+	rows, err := j.db.Query(
+		`SELECT DISTINCT type FROM tickets
+		 WHERE status = $1 AND (not_before IS NULL OR not_before <= now())`,
+		string(StatusPending),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var types []string
+	for rows.Next() {
+		var jobType string
+		if err := rows.Scan(&jobType); err != nil {
+			return nil, err
+		}
+		types = append(types, jobType)
+	}
+	return types, rows.Err()
+}
+
+const postgresSchema = `
+CREATE TABLE IF NOT EXISTS tickets (
+	id         TEXT PRIMARY KEY,
+	status     TEXT NOT NULL,
+	type       TEXT NOT NULL,
+	email      TEXT NOT NULL DEFAULT '',
+	api_key    TEXT NOT NULL DEFAULT '',
+	priority   INTEGER NOT NULL DEFAULT 0,
+	not_before TIMESTAMPTZ,
+	heartbeat  TIMESTAMPTZ,
+	worker     TEXT NOT NULL DEFAULT '',
+	requeues   INTEGER NOT NULL DEFAULT 0,
+	job        JSONB NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE TABLE IF NOT EXISTS ticket_transitions (
+	id        BIGSERIAL PRIMARY KEY,
+	ticket_id TEXT NOT NULL REFERENCES tickets(id),
+	status    TEXT NOT NULL,
+	worker    TEXT NOT NULL DEFAULT '',
+	at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS tickets_status_priority_idx ON tickets (status, priority DESC, created_at);
+CREATE INDEX IF NOT EXISTS ticket_transitions_ticket_idx ON ticket_transitions (ticket_id);
+CREATE TABLE IF NOT EXISTS ticket_dependencies (
+	ticket_id  TEXT NOT NULL REFERENCES tickets(id),
+	depends_on TEXT NOT NULL,
+	PRIMARY KEY (ticket_id, depends_on)
+);
+CREATE TABLE IF NOT EXISTS ticket_failures (
+	id        BIGSERIAL PRIMARY KEY,
+	ticket_id TEXT NOT NULL REFERENCES tickets(id),
+	message   TEXT NOT NULL,
+	at        TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS ticket_failures_ticket_idx ON ticket_failures (ticket_id);
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+	api_key   TEXT NOT NULL,
+	key       TEXT NOT NULL,
+	ticket_id TEXT NOT NULL REFERENCES tickets(id),
+	at        TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (api_key, key)
+);
+CREATE TABLE IF NOT EXISTS cpu_usage (
+	id      BIGSERIAL PRIMARY KEY,
+	api_key TEXT NOT NULL,
+	seconds BIGINT NOT NULL,
+	at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+CREATE INDEX IF NOT EXISTS cpu_usage_key_at_idx ON cpu_usage (api_key, at);
+CREATE TABLE IF NOT EXISTS workers (
+	name      TEXT PRIMARY KEY,
+	databases JSONB NOT NULL DEFAULT '[]',
+	seen_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+`
+
+func MakePostgresJobSystem(config ConfigPostgres) (*PostgresJobSystem, error) {
+	db, err := sql.Open("postgres", config.DSN)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(postgresSchema); err != nil {
+		return nil, err
+	}
+	return &PostgresJobSystem{db: db, rotor: newFairRotor()}, nil
+}
+
+func (j *PostgresJobSystem) SetStatus(id Id, status Status) error {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var current string
+	err = tx.QueryRow(
+		`SELECT status FROM tickets WHERE id = $1 FOR UPDATE`, string(id),
+	).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrInvalidTicket
+	}
+	if err != nil {
+		return err
+	}
+	if !LegalTransition(Status(current), status) {
+		return ErrIllegalTransition
+	}
+	if _, err := tx.Exec(
+		`UPDATE tickets SET status = $1, updated_at = now() WHERE id = $2`,
+		string(status), string(id),
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO ticket_transitions (ticket_id, status) VALUES ($1, $2)`,
+		string(id), string(status),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CompareAndSetStatus transitions the ticket only if it still is in the
+// expected status; the row lock makes the check-and-set atomic against
+// concurrent workers.
+func (j *PostgresJobSystem) CompareAndSetStatus(id Id, from Status, to Status) error {
+	if !LegalTransition(from, to) {
+		return ErrIllegalTransition
+	}
+	tx, err := j.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	var current string
+	err = tx.QueryRow(
+		`SELECT status FROM tickets WHERE id = $1 FOR UPDATE`, string(id),
+	).Scan(&current)
+	if err == sql.ErrNoRows {
+		return ErrInvalidTicket
+	}
+	if err != nil {
+		return err
+	}
+	if Status(current) != from {
+		return ErrIllegalTransition
+	}
+	if _, err := tx.Exec(
+		`UPDATE tickets SET status = $1, updated_at = now() WHERE id = $2`,
+		string(to), string(id),
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO ticket_transitions (ticket_id, status) VALUES ($1, $2)`,
+		string(id), string(to),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (j *PostgresJobSystem) Status(id Id) (Status, error) {
+	var status string
+	err := j.db.QueryRow(`SELECT status FROM tickets WHERE id = $1`, string(id)).Scan(&status)
+	if err == sql.ErrNoRows {
+		return StatusUnknown, nil
+	}
+	if err != nil {
+		return StatusError, err
+	}
+	return Status(status), nil
+}
+
+func (j *PostgresJobSystem) GetTicket(id Id) (Ticket, error) {
+	status, err := j.Status(id)
+	if err != nil {
+		return Ticket{Id: id, Status: status}, err
+	}
+	history, err := j.History(id)
+	return Ticket{Id: id, Status: status, History: history}, err
+}
+
+func (j *PostgresJobSystem) NewJob(request JobRequest, jobsbase string, allowResubmit bool) (Ticket, error) {
+	ticket := Ticket{Id: request.Id, Status: StatusUnknown}
+	status, err := j.Status(request.Id)
+	if err != nil {
+		return ticket, err
+	}
+	if status == StatusComplete && !allowResubmit {
+		ticket.Status = status
+		return ticket, nil
+	}
+	// a duplicate submission of a queued or in-flight ticket returns it
+	// untouched; resetting a RUNNING ticket to PENDING would break the
+	// worker's later RUNNING -> COMPLETE transition and discard the
+	// finished work. Only terminal failures are re-enqueued.
+	if status != StatusUnknown && !isTerminal(status) {
+		ticket.Status = status
+		return ticket, nil
+	}
+	job, err := json.Marshal(request.Job)
+	if err != nil {
+		return ticket, err
+	}
+	tx, err := j.db.Begin()
+	if err != nil {
+		return ticket, err
+	}
+	defer tx.Rollback()
+	var notBefore interface{}
+	if !request.NotBefore.IsZero() {
+		notBefore = request.NotBefore
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO tickets (id, status, type, email, api_key, priority, not_before, job)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		 ON CONFLICT (id) DO UPDATE
+		 SET status = $2, type = $3, email = $4, api_key = $5, priority = $6, not_before = $7, job = $8, updated_at = now()`,
+		string(request.Id), string(StatusPending), string(request.Type), request.Email, request.ApiKey, int(request.Priority), notBefore, job,
+	); err != nil {
+		return ticket, err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO ticket_transitions (ticket_id, status) VALUES ($1, $2)`,
+		string(request.Id), string(StatusPending),
+	); err != nil {
+		return ticket, err
+	}
+	if _, err := tx.Exec(
+		`DELETE FROM ticket_dependencies WHERE ticket_id = $1`, string(request.Id),
+	); err != nil {
+		return ticket, err
+	}
+	for _, dependency := range request.DependsOn {
+		if _, err := tx.Exec(
+			`INSERT INTO ticket_dependencies (ticket_id, depends_on) VALUES ($1, $2)`,
+			string(request.Id), string(dependency),
+		); err != nil {
+			return ticket, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return ticket, err
+	}
+	ticket.Status = StatusPending
+	return ticket, nil
+}
+
+func (j *PostgresJobSystem) Heartbeat(id Id, worker string) error {
+	if _, err := j.db.Exec(
+		`UPDATE tickets SET heartbeat = now(), worker = $1 WHERE id = $2 AND status = $3`,
+		worker, string(id), string(StatusRunning),
+	); err != nil {
+		return err
+	}
+	// stamp the worker identity onto the RUNNING transition once
+	_, err := j.db.Exec(
+		`UPDATE ticket_transitions SET worker = $1
+		 WHERE worker = '' AND id = (
+		   SELECT max(id) FROM ticket_transitions WHERE ticket_id = $2 AND status = $3
+		 )`,
+		worker, string(id), string(StatusRunning),
+	)
+	return err
+}
+
+func (j *PostgresJobSystem) RequeueDead(visibility time.Duration, maxRequeues int) (int, error) {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	// the bolt backend increments first and errors on requeues >
+	// maxRequeues, so a ticket gets exactly maxRequeues extra attempts;
+	// express the same post-increment rule here
+	rows, err := tx.Query(
+		`UPDATE tickets
+		 SET status = CASE WHEN requeues + 1 > $4 THEN $5 ELSE $1 END,
+		     requeues = requeues + 1,
+		     heartbeat = NULL,
+		     updated_at = now()
+		 WHERE status = $2
+		 AND COALESCE(heartbeat, updated_at) < now() - $3 * INTERVAL '1 second'
+		 RETURNING id, status`,
+		string(StatusPending), string(StatusRunning),
+		int64(visibility.Seconds()), maxRequeues, string(StatusError),
+	)
+	if err != nil {
+		return 0, err
+	}
+	type change struct{ id, status string }
+	var changes []change
+	for rows.Next() {
+		var c change
+		if err := rows.Scan(&c.id, &c.status); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		changes = append(changes, c)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	requeued := 0
+	for _, c := range changes {
+		if c.status == string(StatusPending) {
+			requeued++
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO ticket_transitions (ticket_id, status) VALUES ($1, $2)`,
+			c.id, c.status,
+		); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return requeued, nil
+}
+
+// ExpireStaleQueued moves tickets that were queued longer than QueuedTTL
+// to EXPIRED, so a backlog from a worker outage does not suddenly run
+// days after the users gave up.
+func (j *PostgresJobSystem) ExpireStaleQueued() error {
+	if j.QueuedTTL <= 0 {
+		return nil
+	}
+	tx, err := j.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	rows, err := tx.Query(
+		`UPDATE tickets SET status = $1, updated_at = now()
+		 WHERE status = $2 AND created_at < now() - $3 * INTERVAL '1 second'
+		 RETURNING id`,
+		string(StatusExpired), string(StatusPending), int64(j.QueuedTTL.Seconds()),
+	)
+	if err != nil {
+		return err
+	}
+	var expired []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		expired = append(expired, id)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	for _, id := range expired {
+		if _, err := tx.Exec(
+			`INSERT INTO ticket_transitions (ticket_id, status) VALUES ($1, $2)`,
+			id, string(StatusExpired),
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// failBrokenDependents marks pending tickets whose dependencies failed,
+// so they do not wait forever on a result that will never appear.
+func (j *PostgresJobSystem) failBrokenDependents() error {
+	_, err := j.db.Exec(
+		`UPDATE tickets SET status = $1, updated_at = now()
+		 WHERE status = $2 AND EXISTS (
+		   SELECT 1 FROM ticket_dependencies d
+		   JOIN tickets p ON p.id = d.depends_on
+		   WHERE d.ticket_id = tickets.id AND p.status IN ($3, $4)
+		 )`,
+		string(StatusError), string(StatusPending),
+		string(StatusError), string(StatusDeadLetter),
+	)
+	return err
+}
+
+func (j *PostgresJobSystem) MultiStatus(ids []string) ([]Ticket, error) {
+	result := make([]Ticket, 0, len(ids))
+	for _, id := range ids {
+		status, err := j.Status(Id(id))
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, Ticket{Id: Id(id), Status: status})
+	}
+	return result, nil
+}
+
+// Dequeue claims a pending ticket; SKIP LOCKED makes it safe for many
+// workers on different hosts to poll the same table. Within the highest
+// pending priority class, the per-key queue heads are served fair-share
+// so one user's backlog cannot monopolize the workers.
+func (j *PostgresJobSystem) Dependencies(id Id) ([]Id, error) {
+	rows, err := j.db.Query(
+		`SELECT depends_on FROM ticket_dependencies WHERE ticket_id = $1`, string(id),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var dependencies []Id
+	for rows.Next() {
+		var dependency string
+		if err := rows.Scan(&dependency); err != nil {
+			return nil, err
+		}
+		dependencies = append(dependencies, Id(dependency))
+	}
+	return dependencies, rows.Err()
+}
+
+func (j *PostgresJobSystem) JobPayload(id Id) (JobType, []byte, error) {
+	var jobType string
+	var payload []byte
+	err := j.db.QueryRow(
+		`SELECT type, job FROM tickets WHERE id = $1`, string(id),
+	).Scan(&jobType, &payload)
+	if err == sql.ErrNoRows {
+		return "", nil, ErrInvalidTicket
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return JobType(jobType), payload, nil
+}
+
+func (j *PostgresJobSystem) FinishedBefore(cutoff time.Time) ([]ArchivedTicket, error) {
+	rows, err := j.db.Query(
+		`SELECT id, status, type, api_key, job, created_at FROM tickets
+		 WHERE status = ANY($1) AND updated_at < $2
+		 ORDER BY created_at`,
+		pq.Array([]string{
+			string(StatusComplete), string(StatusError), string(StatusExpired),
+			string(StatusCancelled), string(StatusDeadLetter),
+		}),
+		cutoff,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []ArchivedTicket
+	for rows.Next() {
+		var ticket ArchivedTicket
+		var status, jobType string
+		if err := rows.Scan(&ticket.Id, &status, &jobType, &ticket.ApiKey, (*[]byte)(&ticket.Job), &ticket.Created); err != nil {
+			return nil, err
+		}
+		ticket.Status = Status(status)
+		ticket.Type = JobType(jobType)
+		result = append(result, ticket)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	for i := range result {
+		history, err := j.History(result[i].Id)
+		if err != nil {
+			return nil, err
+		}
+		result[i].History = history
+	}
+	return result, nil
+}
+
+func (j *PostgresJobSystem) PurgeTicket(id Id) error {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, statement := range []string{
+		`DELETE FROM ticket_transitions WHERE ticket_id = $1`,
+		`DELETE FROM ticket_failures WHERE ticket_id = $1`,
+		`DELETE FROM ticket_dependencies WHERE ticket_id = $1`,
+		`DELETE FROM idempotency_keys WHERE ticket_id = $1`,
+		`DELETE FROM tickets WHERE id = $1`,
+	} {
+		if _, err := tx.Exec(statement, string(id)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (j *PostgresJobSystem) AdvertiseWorker(worker string, databases []string) error {
+	encoded, err := json.Marshal(databases)
+	if err != nil {
+		return err
+	}
+	_, err = j.db.Exec(
+		`INSERT INTO workers (name, databases, seen_at) VALUES ($1, $2, now())
+		 ON CONFLICT (name) DO UPDATE SET databases = $2, seen_at = now()`,
+		worker, encoded,
+	)
+	return err
+}
+
+// / Workers lists the workers with fresh advertisements for the admin
+// status view.
+func (j *PostgresJobSystem) Workers() ([]WorkerInfo, error) {
+	rows, err := j.db.Query(
+		`SELECT name, databases, seen_at FROM workers
+		 WHERE seen_at > now() - $1 * INTERVAL '1 second'`,
+		int64(advertisementTTL.Seconds()),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var workers []WorkerInfo
+	for rows.Next() {
+		var info WorkerInfo
+		var encoded []byte
+		if err := rows.Scan(&info.Name, &encoded, &info.Seen); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(encoded, &info.Databases); err != nil {
+			return nil, err
+		}
+		workers = append(workers, info)
+	}
+	return workers, rows.Err()
+}
+
+// DequeueFor implements LocalityRouter: it only hands out tickets whose
+// databases the worker holds locally. Workers without a fresh
+// advertisement accept everything.
+func (j *PostgresJobSystem) DequeueFor(worker string) (*Ticket, error) {
+	var encoded []byte
+	err := j.db.QueryRow(
+		`SELECT databases FROM workers
+		 WHERE name = $1 AND seen_at > now() - $2 * INTERVAL '1 second'`,
+		worker, int64(advertisementTTL.Seconds()),
+	).Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return j.Dequeue()
+	}
+	if err != nil {
+		return nil, err
+	}
+	var databases []string
+	if err := json.Unmarshal(encoded, &databases); err != nil {
+		return nil, err
+	}
+	return j.dequeueFiltered(databases)
+}
+
+func (j *PostgresJobSystem) Dequeue() (*Ticket, error) {
+	return j.dequeueFiltered(nil)
+}
+
+func (j *PostgresJobSystem) dequeueFiltered(workerDatabases []string) (*Ticket, error) {
+	if err := j.ExpireStaleQueued(); err != nil {
+		return nil, err
+	}
+	if err := j.failBrokenDependents(); err != nil {
+		return nil, err
+	}
+	query := `SELECT DISTINCT ON (api_key) id, api_key, priority, type, job
+		 FROM tickets
+		 WHERE status = $1 AND (not_before IS NULL OR not_before <= now())
+		 AND NOT EXISTS (
+		   SELECT 1 FROM ticket_dependencies d
+		   JOIN tickets p ON p.id = d.depends_on
+		   WHERE d.ticket_id = tickets.id AND p.status <> $2
+		 )`
+	args := []interface{}{string(StatusPending), string(StatusComplete)}
+	if j.types != nil {
+		available, err := j.pendingTypes()
+		if err != nil {
+			return nil, err
+		}
+		if len(available) == 0 {
+			return nil, nil
+		}
+		query += ` AND type = $3`
+		args = append(args, j.types.Pick(available))
+	}
+	if workerDatabases != nil {
+		query += ` AND NOT EXISTS (
+		   SELECT 1 FROM jsonb_array_elements_text(
+		     COALESCE(job->'database', job->'dbs', '[]'::jsonb)
+		   ) AS dbs(name)
+		   WHERE dbs.name <> ALL($` + strconv.Itoa(len(args)+1) + `)
+		 )`
+		args = append(args, pq.Array(workerDatabases))
+	}
+	query += ` ORDER BY api_key, priority DESC, created_at`
+	rows, err := j.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	heads := make(map[string]string)
+	priorities := make(map[string]int)
+	databases := make(map[string][]string)
+	var keys []string
+	maxPriority := 0
+	for rows.Next() {
+		var headId, apiKey, jobType string
+		var priority int
+		var job []byte
+		if err := rows.Scan(&headId, &apiKey, &priority, &jobType, &job); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		if len(keys) == 0 || priority > maxPriority {
+			maxPriority = priority
+		}
+		heads[apiKey] = headId
+		priorities[apiKey] = priority
+		databases[apiKey] = jobDatabases(JobType(jobType), job)
+		keys = append(keys, apiKey)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	candidates := keys[:0]
+	candidateDatabases := make(map[string][]string)
+	for _, key := range keys {
+		if priorities[key] == maxPriority {
+			candidates = append(candidates, key)
+			candidateDatabases[key] = databases[key]
+		}
+	}
+	chosen, matched := j.affinity.Choose(candidateDatabases)
+	if !matched {
+		chosen = j.rotor.Pick(candidates)
+	}
+	j.affinity.Served(databases[chosen])
+	id := heads[chosen]
+
+	tx, err := j.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	var claimed string
+	err = tx.QueryRow(
+		`SELECT id FROM tickets WHERE id = $1 AND status = $2
+		 FOR UPDATE SKIP LOCKED`,
+		id, string(StatusPending),
+	).Scan(&claimed)
+	if err == sql.ErrNoRows {
+		// another worker won the race; the caller polls again
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(
+		`UPDATE tickets SET status = $1, updated_at = now() WHERE id = $2`,
+		string(StatusRunning), id,
+	); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO ticket_transitions (ticket_id, status) VALUES ($1, $2)`,
+		id, string(StatusRunning),
+	); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &Ticket{Id: Id(id), Status: StatusRunning}, nil
+}
+
+func (j *PostgresJobSystem) QueueLength() (int, error) {
+	var length int
+	err := j.db.QueryRow(
+		`SELECT COUNT(*) FROM tickets WHERE status = $1`, string(StatusPending),
+	).Scan(&length)
+	return length, err
+}
+
+// History returns the recorded state transitions of a ticket in order,
+// which exposes queue wait and run time for post-hoc analysis.
+func (j *PostgresJobSystem) History(id Id) ([]TicketTransition, error) {
+	rows, err := j.db.Query(
+		`SELECT status, worker, at FROM ticket_transitions WHERE ticket_id = $1 ORDER BY at`,
+		string(id),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var transitions []TicketTransition
+	for rows.Next() {
+		var status, worker string
+		var at time.Time
+		if err := rows.Scan(&status, &worker, &at); err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, TicketTransition{Status: Status(status), At: at, Worker: worker})
+	}
+	return transitions, rows.Err()
+}
+
+func (j *PostgresJobSystem) RecordFailure(id Id, message string, maxFailures int) (Status, error) {
+	tx, err := j.db.Begin()
+	if err != nil {
+		return StatusError, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(
+		`INSERT INTO ticket_failures (ticket_id, message) VALUES ($1, $2)`,
+		string(id), message,
+	); err != nil {
+		return StatusError, err
+	}
+	var failures int
+	if err := tx.QueryRow(
+		`SELECT COUNT(*) FROM ticket_failures WHERE ticket_id = $1`, string(id),
+	).Scan(&failures); err != nil {
+		return StatusError, err
+	}
+	status := StatusError
+	if maxFailures > 0 && failures >= maxFailures {
+		status = StatusDeadLetter
+	}
+	if _, err := tx.Exec(
+		`UPDATE tickets SET status = $1, updated_at = now() WHERE id = $2`,
+		string(status), string(id),
+	); err != nil {
+		return StatusError, err
+	}
+	if _, err := tx.Exec(
+		`INSERT INTO ticket_transitions (ticket_id, status) VALUES ($1, $2)`,
+		string(id), string(status),
+	); err != nil {
+		return StatusError, err
+	}
+	return status, tx.Commit()
+}
+
+func (j *PostgresJobSystem) DeadLetters() ([]DeadLetter, error) {
+	rows, err := j.db.Query(
+		`SELECT t.id, f.message, f.at
+		 FROM tickets t JOIN ticket_failures f ON f.ticket_id = t.id
+		 WHERE t.status = $1
+		 ORDER BY t.id, f.at`,
+		string(StatusDeadLetter),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []DeadLetter
+	for rows.Next() {
+		var id, message string
+		var at time.Time
+		if err := rows.Scan(&id, &message, &at); err != nil {
+			return nil, err
+		}
+		record := FailureRecord{At: at, Message: message}
+		if len(result) > 0 && result[len(result)-1].Id == Id(id) {
+			last := &result[len(result)-1]
+			last.Failures = append(last.Failures, record)
+			continue
+		}
+		result = append(result, DeadLetter{Id: Id(id), Failures: []FailureRecord{record}})
+	}
+	return result, rows.Err()
+}
+
+func (j *PostgresJobSystem) RequeueDeadLetter(id Id) error {
+	status, err := j.Status(id)
+	if err != nil {
+		return err
+	}
+	if status != StatusDeadLetter {
+		return ErrInvalidTicket
+	}
+	return j.SetStatus(id, StatusPending)
+}
+
+func (j *PostgresJobSystem) ListTickets(statuses []Status) ([]AdminTicket, error) {
+	query := `SELECT id, status, type, api_key, priority, created_at, job FROM tickets`
+	var args []interface{}
+	if len(statuses) > 0 {
+		query += ` WHERE status = ANY($1)`
+		values := make([]string, 0, len(statuses))
+		for _, status := range statuses {
+			values = append(values, string(status))
+		}
+		args = append(args, pq.Array(values))
+	}
+	query += ` ORDER BY created_at`
+	rows, err := j.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []AdminTicket
+	for rows.Next() {
+		var ticket AdminTicket
+		var status, jobType string
+		var priority int
+		var job []byte
+		if err := rows.Scan(&ticket.Id, &status, &jobType, &ticket.ApiKey, &priority, &ticket.Created, &job); err != nil {
+			return nil, err
+		}
+		ticket.Status = Status(status)
+		ticket.Type = JobType(jobType)
+		ticket.Priority = Priority(priority)
+		ticket.Databases = jobDatabases(ticket.Type, job)
+		result = append(result, ticket)
+	}
+	return result, rows.Err()
+}
+
+func (j *PostgresJobSystem) Reprioritize(id Id, priority Priority) error {
+	result, err := j.db.Exec(
+		`UPDATE tickets SET priority = $1, updated_at = now() WHERE id = $2 AND status = $3`,
+		int(priority), string(id), string(StatusPending),
+	)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrInvalidTicket
+	}
+	return nil
+}
+
+func (j *PostgresJobSystem) Cancel(id Id) error {
+	status, err := j.Status(id)
+	if err != nil {
+		return err
+	}
+	switch status {
+	case StatusPending, StatusRunning:
+		return j.SetStatus(id, StatusCancelled)
+	}
+	return ErrInvalidTicket
+}
+
+func (j *PostgresJobSystem) Requeue(id Id) error {
+	status, err := j.Status(id)
+	if err != nil {
+		return err
+	}
+	if status == StatusPending || status == StatusUnknown {
+		return ErrInvalidTicket
+	}
+	return j.SetStatus(id, StatusPending)
+}
+
+func (j *PostgresJobSystem) ResolveIdempotencyKey(apiKey string, key string) (Id, bool, error) {
+	var id string
+	err := j.db.QueryRow(
+		`SELECT ticket_id FROM idempotency_keys WHERE api_key = $1 AND key = $2`,
+		apiKey, key,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return Id(id), true, nil
+}
+
+func (j *PostgresJobSystem) SaveIdempotencyKey(apiKey string, key string, id Id) error {
+	_, err := j.db.Exec(
+		`INSERT INTO idempotency_keys (api_key, key, ticket_id) VALUES ($1, $2, $3)
+		 ON CONFLICT (api_key, key) DO NOTHING`,
+		apiKey, key, string(id),
+	)
+	return err
+}
+
+func (j *PostgresJobSystem) UsageCounts(apiKey string) (int, int, error) {
+	var running, queued int
+	err := j.db.QueryRow(
+		`SELECT
+		   COUNT(*) FILTER (WHERE status = $2),
+		   COUNT(*) FILTER (WHERE status = $3)
+		 FROM tickets WHERE api_key = $1`,
+		apiKey, string(StatusRunning), string(StatusPending),
+	).Scan(&running, &queued)
+	return running, queued, err
+}
+
+func (j *PostgresJobSystem) CpuSecondsSince(apiKey string, since time.Time) (int64, error) {
+	var seconds int64
+	err := j.db.QueryRow(
+		`SELECT COALESCE(SUM(seconds), 0) FROM cpu_usage WHERE api_key = $1 AND at >= $2`,
+		apiKey, since,
+	).Scan(&seconds)
+	return seconds, err
+}
+
+func (j *PostgresJobSystem) AddCpuSeconds(apiKey string, seconds int64) error {
+	_, err := j.db.Exec(
+		`INSERT INTO cpu_usage (api_key, seconds) VALUES ($1, $2)`,
+		apiKey, seconds,
+	)
+	return err
+}
@@ -0,0 +1,66 @@
+package main
+
+import "testing"
+
+func TestLegalTransition(t *testing.T) {
+	allowed := []struct{ from, to Status }{
+		{StatusPending, StatusRunning},
+		{StatusPending, StatusCancelled},
+		{StatusPending, StatusExpired},
+		{StatusRunning, StatusComplete},
+		{StatusRunning, StatusError},
+		{StatusRunning, StatusPending},
+		{StatusRunning, StatusDeadLetter},
+		{StatusError, StatusPending},
+		{StatusError, StatusDeadLetter},
+		{StatusDeadLetter, StatusPending},
+		{StatusExpired, StatusPending},
+		{StatusCancelled, StatusPending},
+	}
+	for _, transition := range allowed {
+		if !LegalTransition(transition.from, transition.to) {
+			t.Errorf("%s -> %s should be legal", transition.from, transition.to)
+		}
+	}
+	forbidden := []struct{ from, to Status }{
+		// COMPLETE is terminal; resubmission goes through NewJob
+		{StatusComplete, StatusPending},
+		{StatusComplete, StatusRunning},
+		{StatusComplete, StatusError},
+		// tickets cannot skip the RUNNING stage
+		{StatusPending, StatusComplete},
+		{StatusPending, StatusDeadLetter},
+		// only PENDING tickets expire
+		{StatusRunning, StatusExpired},
+		{StatusError, StatusComplete},
+		{StatusDeadLetter, StatusRunning},
+		{StatusUnknown, StatusRunning},
+	}
+	for _, transition := range forbidden {
+		if LegalTransition(transition.from, transition.to) {
+			t.Errorf("%s -> %s should be illegal", transition.from, transition.to)
+		}
+	}
+}
+
+func TestBoltSetStatusEnforcesTransitions(t *testing.T) {
+	jobsystem := testBoltJobSystem(t)
+	if _, err := jobsystem.NewJob(testJobRequest("ticket-a", "key"), t.TempDir(), false); err != nil {
+		t.Fatal(err)
+	}
+	if err := jobsystem.SetStatus("ticket-a", StatusComplete); err != ErrIllegalTransition {
+		t.Errorf("PENDING -> COMPLETE: got %v, want ErrIllegalTransition", err)
+	}
+	if err := jobsystem.SetStatus("ticket-a", StatusRunning); err != nil {
+		t.Fatalf("PENDING -> RUNNING: %v", err)
+	}
+	if err := jobsystem.SetStatus("ticket-a", StatusComplete); err != nil {
+		t.Fatalf("RUNNING -> COMPLETE: %v", err)
+	}
+	if err := jobsystem.SetStatus("ticket-a", StatusRunning); err != ErrIllegalTransition {
+		t.Errorf("COMPLETE -> RUNNING: got %v, want ErrIllegalTransition", err)
+	}
+	if status, err := jobsystem.Status("ticket-a"); err != nil || status != StatusComplete {
+		t.Errorf("final status: got %s (err %v), want COMPLETE", status, err)
+	}
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// makeJobSystem builds the configured ticket store, the optional
+// external queue in front of it and the pause switch around both.
+func makeJobSystem(config ConfigRoot) (JobSystem, error) {
+	var base JobSystem
+	var err error
+	switch config.Backend.Type {
+	case "postgres":
+		base, err = MakePostgresJobSystem(config.Backend.Postgres)
+	default:
+		base, err = MakeBoltJobSystem(config.Backend.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	jobsystem := base
+	switch config.Backend.Queue {
+	case "sqs":
+		queue, err := MakeSqsQueue(config.Backend.Sqs)
+		if err != nil {
+			return nil, err
+		}
+		jobsystem = MakeQueuedJobSystem(jobsystem, queue)
+	case "pubsub":
+		queue, err := MakePubSubQueue(config.Backend.PubSub)
+		if err != nil {
+			return nil, err
+		}
+		jobsystem = MakeQueuedJobSystem(jobsystem, queue)
+	}
+	return MakePausableJobSystem(jobsystem, config.Backend.StartPaused), nil
+}
+
+// scopedAuth applies the key scopes by route shape: submissions need
+// the submit scope, result retrieval the download scope, and plain
+// status reads pass through. Admin routes carry their own token check.
+func scopedAuth(store *ApiKeyStore, enabled bool) mux.MiddlewareFunc {
+	submit := ScopeMiddleware(store, ScopeSubmit, enabled)
+	download := ScopeMiddleware(store, ScopeDownload, enabled)
+	return func(next http.Handler) http.Handler {
+		submitNext := submit(next)
+		downloadNext := download(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			path := req.URL.Path
+			switch {
+			case req.Method == "POST" && (strings.HasPrefix(path, "/ticket") ||
+				strings.HasPrefix(path, "/tickets") ||
+				strings.HasPrefix(path, "/api/v2/tickets") ||
+				strings.HasPrefix(path, "/uploads")):
+				submitNext.ServeHTTP(w, req)
+			case strings.HasPrefix(path, "/result/") ||
+				strings.Contains(path, "/file/") ||
+				strings.Contains(path, "/fasta/"):
+				downloadNext.ServeHTTP(w, req)
+			default:
+				next.ServeHTTP(w, req)
+			}
+		})
+	}
+}
+
+func main() {
+	configPath := flag.String("config", "config.json", "server configuration file")
+	flag.Parse()
+
+	config, err := ReadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("reading config %s: %v", *configPath, err)
+	}
+	if !config.Verbose {
+		log.SetFlags(log.LstdFlags)
+	}
+	for _, path := range []string{config.Paths.Results, config.Paths.Temporary} {
+		if err := os.MkdirAll(path, 0755); err != nil {
+			log.Fatalf("creating %s: %v", path, err)
+		}
+	}
+
+	jobsystem, err := makeJobSystem(config)
+	if err != nil {
+		log.Fatalf("starting job system: %v", err)
+	}
+	jobsbase := config.Paths.Results
+
+	var store *ApiKeyStore
+	if config.Auth.Enabled || config.DbAcl.Enabled {
+		store, err = NewApiKeyStore(config.Auth.KeyFile)
+		if err != nil {
+			log.Fatalf("loading API keys: %v", err)
+		}
+	}
+
+	roots := config.Paths.Databases
+	if config.Staging.Enabled {
+		staged, err := StageDatabases(config.Staging, roots)
+		if err != nil {
+			log.Fatalf("staging databases: %v", err)
+		}
+		roots = staged
+	}
+
+	var aliases *DbAliases
+	if config.DbVersions.Enabled {
+		aliases = NewDbAliases(roots.Primary(), config.DbVersions)
+	}
+	acl := NewDbAcl(config.DbAcl, store)
+	retirement := NewDbRetirement(roots)
+
+	var names []string
+	for name := range discoverDatabases(roots) {
+		names = append(names, name)
+	}
+	verifier := NewDatabaseVerifier(roots.Primary(), names, config.Paths.Mmseqs)
+	go func() {
+		if err := verifier.Verify(); err != nil {
+			log.Printf("database verification: %v", err)
+		}
+	}()
+	if config.Warmup.Enabled {
+		go func() {
+			if err := WarmupDatabases(config.Warmup, roots.Primary()); err != nil {
+				log.Printf("warmup: %v", err)
+			}
+		}()
+	}
+
+	stats, err := NewDbStats(config.Paths.Temporary + "/dbstats.json")
+	if err != nil {
+		log.Fatalf("loading database stats: %v", err)
+	}
+	objectStore, err := MakeObjectStore(config.ObjectStore)
+	if err != nil {
+		log.Fatalf("connecting object store: %v", err)
+	}
+
+	r := mux.NewRouter()
+	if config.Cors.Enabled {
+		r.Use(CorsMiddleware(config.Cors))
+	}
+	if config.Oidc.Enabled {
+		r.Use(BearerMiddleware(NewOidcValidator(config.Oidc), true))
+	}
+	if config.RateLimit.Enabled {
+		limiter := MakeRateLimiter(config.RateLimit)
+		r.Use(func(next http.Handler) http.Handler {
+			return RateLimitMiddleware(limiter, next)
+		})
+	}
+	r.Use(scopedAuth(store, config.Auth.Enabled))
+
+	RegisterHealthRoutes(r, jobsystem, roots, config.Paths.Temporary, verifier)
+	RegisterTicketRoutes(r, jobsystem, jobsbase, config, aliases, acl, retirement)
+	RegisterApiV2(r, jobsystem, jobsbase, aliases, acl, retirement)
+	RegisterCombinedRoutes(r, jobsystem, jobsbase)
+	RegisterEventRoutes(r, jobsystem)
+	RegisterBulkStatusRoute(r, jobsystem)
+	RegisterMyJobsRoute(r, jobsystem)
+	RegisterDownloadRoute(r, jobsystem, jobsbase)
+	RegisterResultFileRoute(r, jobsystem, jobsbase)
+	RegisterAlignedFastaRoute(r, jobsystem, jobsbase)
+	RegisterStockholmRoute(r, jobsystem, jobsbase)
+	RegisterChecksumRoute(r, jobsystem, jobsbase)
+	RegisterHitsRoute(r, jobsystem, jobsbase)
+	RegisterParquetRoute(r, jobsystem, jobsbase)
+	RegisterVizRoute(r, jobsystem, jobsbase)
+	RegisterAF3Route(r, jobsystem, jobsbase)
+	RegisterDiffRoute(r, jobsystem, jobsbase)
+	RegisterResubmitRoute(r, jobsystem, jobsbase)
+	RegisterDeleteRoute(r, jobsystem, jobsbase)
+	RegisterPinRoutes(r, jobsystem, jobsbase)
+	RegisterValidateRoute(r, roots)
+	RegisterDatabasesRoute(r, roots, jobsystem)
+	RegisterUsageRoute(r, jobsystem, jobsbase, config.Quotas)
+	RegisterOpenApiRoute(r)
+	if config.Upload.Enabled {
+		RegisterUploadRoutes(r, config.Upload)
+	}
+	if config.UserDb.Enabled {
+		RegisterUserDbRoutes(r, config.UserDb, roots.Primary())
+	}
+	if objectStore != nil {
+		RegisterResultUrlRoute(r, jobsystem, objectStore, config.ObjectStore)
+	}
+
+	RegisterAdminRoutes(r, jobsystem, config.Admin)
+	RegisterAdminStatusRoute(r, jobsystem, config.Admin)
+	RegisterDbStatsRoute(r, stats, config.Admin)
+	RegisterDbSwapRoute(r, jobsystem, aliases, roots.Primary(), config.Admin)
+	RegisterRetireRoute(r, jobsystem, retirement, config.Admin)
+	RegisterIndexPlanRoute(r, roots, config.Admin)
+	RegisterMirrorSyncRoute(r, config.MirrorSync, roots, config.Admin)
+	RegisterFoldseekDbRoute(r, config.Paths.Foldseek, roots.Primary(), config.Admin)
+	if store != nil {
+		RegisterApiKeyAdminRoutes(r, store, config.Admin)
+	}
+	if config.DbSetup.Enabled {
+		RegisterDbSetupRoutes(r, NewDbSetup(config.DbSetup), config.Admin)
+	}
+	if config.Taxdump.Enabled {
+		RegisterTaxdumpRoutes(r, NewTaxdumpManager(config.Taxdump), config.Admin)
+	}
+
+	stop := make(chan struct{})
+	if config.Archival.Enabled {
+		go ArchivalLoop(jobsystem, config.Archival, stop)
+	}
+	if config.Retention.Enabled {
+		go RetentionLoop(jobsystem, jobsbase, config.Retention, stop)
+	}
+	if config.Reindex.Enabled {
+		go ReindexLoop(jobsystem, jobsbase, config.Reindex, stop)
+	}
+	if config.MirrorSync.Enabled && config.MirrorSync.IntervalSeconds > 0 {
+		go MirrorSyncLoop(config.MirrorSync, roots, stop)
+	}
+	if config.Upload.Enabled && config.Upload.StaleAfter > 0 {
+		go func() {
+			ticker := time.NewTicker(time.Hour)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if err := CleanStaleUploads(config.Upload.Directory, config.Upload.StaleAfter); err != nil {
+						log.Printf("cleaning stale uploads: %v", err)
+					}
+				}
+			}
+		}()
+	}
+	if beats, ok := UnwrapJobSystem(jobsystem).(HeartbeatSystem); ok {
+		visibility := time.Duration(config.Worker.VisibilitySeconds) * time.Second
+		maxRequeues := config.DeadLetter.MaxFailures
+		if maxRequeues <= 0 {
+			maxRequeues = 3
+		}
+		go func() {
+			ticker := time.NewTicker(visibility / 2)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if requeued, err := beats.RequeueDead(visibility, maxRequeues); err != nil {
+						log.Printf("dead-worker sweep: %v", err)
+					} else if requeued > 0 {
+						log.Printf("dead-worker sweep requeued %d tickets", requeued)
+					}
+					if expirer, ok := UnwrapJobSystem(jobsystem).(interface{ ExpireStaleQueued() error }); ok {
+						if err := expirer.ExpireStaleQueued(); err != nil {
+							log.Printf("expiring stale tickets: %v", err)
+						}
+					}
+				}
+			}
+		}()
+	}
+	if config.Worker.Enabled {
+		for i := 0; i < config.Worker.Workers; i++ {
+			go RunWorker(jobsystem, config, roots, stats, objectStore, stop)
+		}
+	}
+	if config.Grpc.Enabled {
+		grpcServer, err := StartGrpcServer(jobsystem, jobsbase, config.Grpc, store, config.Auth.Enabled)
+		if err != nil {
+			log.Fatalf("starting gRPC server: %v", err)
+		}
+		defer grpcServer.GracefulStop()
+	}
+
+	server := &http.Server{Addr: config.Server.Address, Handler: r}
+	go func() {
+		log.Printf("listening on %s", config.Server.Address)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatalf("http server: %v", err)
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	<-signals
+	log.Printf("shutting down")
+	close(stop)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// gitRevision is stamped at build time via
+// -ldflags "-X main.gitRevision=$(git rev-parse HEAD)".
+var gitRevision = "unknown"
+
+// ManifestFile is one result file with its checksum.
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// ResultManifest is the machine-readable manifest.json packaged into
+// every archive, listing each file with its SHA256 plus the effective
+// command lines, database versions and server revision — what a
+// reproducibility audit needs to re-run the job.
+type ResultManifest struct {
+	Ticket         Id                `json:"ticket"`
+	Generated      time.Time         `json:"generated"`
+	ServerRevision string            `json:"server_revision"`
+	Commands       []string          `json:"commands,omitempty"`
+	Databases      map[string]string `json:"databases,omitempty"`
+	Files          []ManifestFile    `json:"files"`
+}
+
+func fileSha256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// WriteResultManifest writes manifest.json into the result directory so
+// it gets packaged into the archive alongside the result files. The
+// worker passes the command lines it ran and the versions of the
+// databases it searched.
+func WriteResultManifest(jobsbase string, id Id, commands []string, databases map[string]string) (*ResultManifest, error) {
+	resultBase := filepath.Join(jobsbase, string(id))
+	manifest := &ResultManifest{
+		Ticket:         id,
+		Generated:      time.Now().UTC(),
+		ServerRevision: gitRevision,
+		Commands:       commands,
+		Databases:      databases,
+	}
+	entries, err := os.ReadDir(resultBase)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == "manifest.json" || strings.HasPrefix(name, "mmseqs_results_") ||
+			strings.HasPrefix(name, "alis_") || strings.HasPrefix(name, ".") ||
+			strings.HasSuffix(name, ".etag") || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		checksum, err := fileSha256(filepath.Join(resultBase, name))
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, ManifestFile{
+			Name:   name,
+			Size:   info.Size(),
+			Sha256: checksum,
+		})
+	}
+	payload, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(resultBase, "manifest.json"), payload, 0644); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ReadResultManifest loads a previously written manifest; it returns
+// nil without error when the job predates manifests.
+func ReadResultManifest(jobsbase string, id Id) (*ResultManifest, error) {
+	raw, err := os.ReadFile(filepath.Join(jobsbase, string(id), "manifest.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var manifest ResultManifest
+	if err := json.Unmarshal(raw, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
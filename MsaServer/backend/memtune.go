@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DbLoadSettings is the memory tuning chosen for one database: the
+// --db-load-mode and, for databases larger than RAM, a
+// --split-memory-limit that keeps the search from thrashing.
+type DbLoadSettings struct {
+	LoadMode int
+	// SplitMemoryLimit is an mmseqs size string like "64G"; empty means
+	// no split limit.
+	SplitMemoryLimit string
+}
+
+// Args renders the settings as mmseqs arguments.
+func (s DbLoadSettings) Args() []string {
+	args := []string{"--db-load-mode", strconv.Itoa(s.LoadMode)}
+	if s.SplitMemoryLimit != "" {
+		args = append(args, "--split-memory-limit", s.SplitMemoryLimit)
+	}
+	return args
+}
+
+// availableMemoryBytes reads MemAvailable from /proc/meminfo; zero
+// means the amount could not be determined.
+func availableMemoryBytes() int64 {
+	file, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "MemAvailable:" {
+			kilobytes, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kilobytes * 1024
+		}
+	}
+	return 0
+}
+
+// databaseIndexSize sums the sizes of a database's index files; without
+// an index, the data files stand in.
+func databaseIndexSize(databasesPath string, name string) int64 {
+	matches, err := filepath.Glob(filepath.Join(databasesPath, name+"*.idx*"))
+	if err != nil || len(matches) == 0 {
+		matches, err = filepath.Glob(filepath.Join(databasesPath, name+"*"))
+		if err != nil {
+			return 0
+		}
+	}
+	total := int64(0)
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// ChooseDbLoadSettings picks --db-load-mode per database from available
+// RAM and index size instead of the hardcoded mode 2: indexes that fit
+// comfortably are mapped and touched up front (mode 3), larger ones are
+// mapped lazily (mode 2) with a split-memory-limit so a single split
+// still fits.
+func ChooseDbLoadSettings(databasesPath string, name string) DbLoadSettings {
+	available := availableMemoryBytes()
+	if available == 0 {
+		// unknown platform; keep the historic behavior
+		return DbLoadSettings{LoadMode: 2}
+	}
+	indexSize := databaseIndexSize(databasesPath, name)
+	// leave a fifth of RAM for the query database and working memory
+	budget := available / 5 * 4
+	if indexSize > 0 && indexSize <= budget {
+		return DbLoadSettings{LoadMode: 3}
+	}
+	return DbLoadSettings{
+		LoadMode:         2,
+		SplitMemoryLimit: fmt.Sprintf("%dM", budget>>20),
+	}
+}
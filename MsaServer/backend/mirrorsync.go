@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ConfigMirrorSync pulls database snapshots from a mirror, downloading
+// only files whose checksums changed — a nightly uniref refresh moves
+// a few profile files, not the whole multi-hundred-GB snapshot.
+type ConfigMirrorSync struct {
+	Enabled bool `json:"enabled"`
+	// Url is the mirror base; each snapshot publishes a
+	// <name>.CHECKSUMS manifest in the format WriteDatabaseChecksums
+	// produces.
+	Url string `json:"url"`
+	// Databases lists the snapshot names to keep in sync, e.g.
+	// uniref30_2404.
+	Databases []string `json:"databases,omitempty"`
+	// IntervalSeconds is the automatic sync cadence; zero syncs only
+	// on the admin endpoint.
+	IntervalSeconds int64 `json:"interval,omitempty"`
+}
+
+// mirrorManifestEntry is one line of a snapshot manifest.
+type mirrorManifestEntry struct {
+	Sha256 string
+	Size   int64
+	Name   string
+}
+
+// fetchMirrorManifest downloads and parses a snapshot's checksum
+// manifest.
+func fetchMirrorManifest(url string, name string) ([]mirrorManifestEntry, error) {
+	response, err := http.Get(url + "/" + name + "." + databaseChecksumFile)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mirror has no manifest for %s: %s", name, response.Status)
+	}
+	var entries []mirrorManifestEntry
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, mirrorManifestEntry{Sha256: fields[0], Size: size, Name: fields[2]})
+	}
+	return entries, scanner.Err()
+}
+
+// mirrorFileCurrent reports whether the local copy already matches the
+// manifest entry; size first, hash only when the size matches, so
+// unchanged files cost one stat and changed files skip the hash.
+func mirrorFileCurrent(path string, entry mirrorManifestEntry) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != entry.Size {
+		return false
+	}
+	sum, err := fileSha256(path)
+	return err == nil && sum == entry.Sha256
+}
+
+// SyncDatabase brings one snapshot up to date with the mirror and
+// returns how many files were downloaded. The refreshed manifest is
+// stored locally, registering the version for verification and
+// hot-swap.
+func SyncDatabase(config ConfigMirrorSync, roots DbRoots, name string) (int, error) {
+	entries, err := fetchMirrorManifest(config.Url, name)
+	if err != nil {
+		return 0, err
+	}
+	root := roots.Primary()
+	downloaded := 0
+	var lines []string
+	for _, entry := range entries {
+		if entry.Name != filepath.Base(entry.Name) {
+			return downloaded, fmt.Errorf("mirror manifest for %s names invalid file %q", name, entry.Name)
+		}
+		path := filepath.Join(root, entry.Name)
+		if !mirrorFileCurrent(path, entry) {
+			if err := downloadResumable(config.Url+"/"+entry.Name, path); err != nil {
+				return downloaded, err
+			}
+			sum, err := fileSha256(path)
+			if err != nil {
+				return downloaded, err
+			}
+			if sum != entry.Sha256 {
+				return downloaded, fmt.Errorf("mirror sync: %s corrupt after download", entry.Name)
+			}
+			downloaded++
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %s", entry.Sha256, entry.Size, entry.Name))
+	}
+	manifest := strings.Join(lines, "\n") + "\n"
+	if err := os.WriteFile(filepath.Join(root, name+"."+databaseChecksumFile), []byte(manifest), 0644); err != nil {
+		return downloaded, err
+	}
+	return downloaded, nil
+}
+
+// syncAll runs one pass over all configured snapshots.
+func syncAll(config ConfigMirrorSync, roots DbRoots) {
+	for _, name := range config.Databases {
+		downloaded, err := SyncDatabase(config, roots, name)
+		if err != nil {
+			log.Printf("mirror sync: %s: %s", name, err)
+			continue
+		}
+		if downloaded > 0 {
+			log.Printf("mirror sync: %s: %d files updated", name, downloaded)
+		}
+	}
+}
+
+// MirrorSyncLoop periodically syncs the configured snapshots until stop
+// closes.
+func MirrorSyncLoop(config ConfigMirrorSync, roots DbRoots, stop chan struct{}) {
+	if !config.Enabled || config.IntervalSeconds <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(config.IntervalSeconds) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			syncAll(config, roots)
+		}
+	}
+}
+
+// RegisterMirrorSyncRoute adds POST /admin/databases/sync, triggering
+// one sync pass in the background.
+func RegisterMirrorSyncRoute(r *mux.Router, syncConfig ConfigMirrorSync, roots DbRoots, config ConfigAdmin) {
+	if config.Token == "" || !syncConfig.Enabled {
+		return
+	}
+	r.HandleFunc("/admin/databases/sync", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		go syncAll(syncConfig, roots)
+		w.WriteHeader(http.StatusAccepted)
+	})).Methods("POST")
+}
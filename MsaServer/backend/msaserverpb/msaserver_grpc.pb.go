@@ -0,0 +1,256 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: msaserver.proto
+
+package msaserverpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	MsaServer_Submit_FullMethodName      = "/msaserver.MsaServer/Submit"
+	MsaServer_Status_FullMethodName      = "/msaserver.MsaServer/Status"
+	MsaServer_WatchStatus_FullMethodName = "/msaserver.MsaServer/WatchStatus"
+	MsaServer_Result_FullMethodName      = "/msaserver.MsaServer/Result"
+)
+
+// MsaServerClient is the client API for MsaServer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// MsaServer mirrors the REST API for high-throughput pipeline clients:
+// typed submission, server-side status streaming and result retrieval
+// without multipart/form-data overhead.
+type MsaServerClient interface {
+	Submit(ctx context.Context, in *SubmitRequest, opts ...grpc.CallOption) (*TicketResponse, error)
+	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*TicketResponse, error)
+	// WatchStatus streams a TicketResponse on every status change until
+	// the ticket reaches a terminal status.
+	WatchStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TicketResponse], error)
+	// Result streams the result archive in chunks.
+	Result(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ResultChunk], error)
+}
+
+type msaServerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMsaServerClient(cc grpc.ClientConnInterface) MsaServerClient {
+	return &msaServerClient{cc}
+}
+
+func (c *msaServerClient) Submit(ctx context.Context, in *SubmitRequest, opts ...grpc.CallOption) (*TicketResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TicketResponse)
+	err := c.cc.Invoke(ctx, MsaServer_Submit_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *msaServerClient) Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*TicketResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(TicketResponse)
+	err := c.cc.Invoke(ctx, MsaServer_Status_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *msaServerClient) WatchStatus(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[TicketResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MsaServer_ServiceDesc.Streams[0], MsaServer_WatchStatus_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[StatusRequest, TicketResponse]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MsaServer_WatchStatusClient = grpc.ServerStreamingClient[TicketResponse]
+
+func (c *msaServerClient) Result(ctx context.Context, in *ResultRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[ResultChunk], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &MsaServer_ServiceDesc.Streams[1], MsaServer_Result_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ResultRequest, ResultChunk]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MsaServer_ResultClient = grpc.ServerStreamingClient[ResultChunk]
+
+// MsaServerServer is the server API for MsaServer service.
+// All implementations must embed UnimplementedMsaServerServer
+// for forward compatibility.
+//
+// MsaServer mirrors the REST API for high-throughput pipeline clients:
+// typed submission, server-side status streaming and result retrieval
+// without multipart/form-data overhead.
+type MsaServerServer interface {
+	Submit(context.Context, *SubmitRequest) (*TicketResponse, error)
+	Status(context.Context, *StatusRequest) (*TicketResponse, error)
+	// WatchStatus streams a TicketResponse on every status change until
+	// the ticket reaches a terminal status.
+	WatchStatus(*StatusRequest, grpc.ServerStreamingServer[TicketResponse]) error
+	// Result streams the result archive in chunks.
+	Result(*ResultRequest, grpc.ServerStreamingServer[ResultChunk]) error
+	mustEmbedUnimplementedMsaServerServer()
+}
+
+// UnimplementedMsaServerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMsaServerServer struct{}
+
+func (UnimplementedMsaServerServer) Submit(context.Context, *SubmitRequest) (*TicketResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Submit not implemented")
+}
+func (UnimplementedMsaServerServer) Status(context.Context, *StatusRequest) (*TicketResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Status not implemented")
+}
+func (UnimplementedMsaServerServer) WatchStatus(*StatusRequest, grpc.ServerStreamingServer[TicketResponse]) error {
+	return status.Error(codes.Unimplemented, "method WatchStatus not implemented")
+}
+func (UnimplementedMsaServerServer) Result(*ResultRequest, grpc.ServerStreamingServer[ResultChunk]) error {
+	return status.Error(codes.Unimplemented, "method Result not implemented")
+}
+func (UnimplementedMsaServerServer) mustEmbedUnimplementedMsaServerServer() {}
+func (UnimplementedMsaServerServer) testEmbeddedByValue()                   {}
+
+// UnsafeMsaServerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MsaServerServer will
+// result in compilation errors.
+type UnsafeMsaServerServer interface {
+	mustEmbedUnimplementedMsaServerServer()
+}
+
+func RegisterMsaServerServer(s grpc.ServiceRegistrar, srv MsaServerServer) {
+	// If the following call panics, it indicates UnimplementedMsaServerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&MsaServer_ServiceDesc, srv)
+}
+
+func _MsaServer_Submit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SubmitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsaServerServer).Submit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MsaServer_Submit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsaServerServer).Submit(ctx, req.(*SubmitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MsaServer_Status_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MsaServerServer).Status(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: MsaServer_Status_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MsaServerServer).Status(ctx, req.(*StatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MsaServer_WatchStatus_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StatusRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MsaServerServer).WatchStatus(m, &grpc.GenericServerStream[StatusRequest, TicketResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MsaServer_WatchStatusServer = grpc.ServerStreamingServer[TicketResponse]
+
+func _MsaServer_Result_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ResultRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MsaServerServer).Result(m, &grpc.GenericServerStream[ResultRequest, ResultChunk]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type MsaServer_ResultServer = grpc.ServerStreamingServer[ResultChunk]
+
+// MsaServer_ServiceDesc is the grpc.ServiceDesc for MsaServer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var MsaServer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "msaserver.MsaServer",
+	HandlerType: (*MsaServerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Submit",
+			Handler:    _MsaServer_Submit_Handler,
+		},
+		{
+			MethodName: "Status",
+			Handler:    _MsaServer_Status_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchStatus",
+			Handler:       _MsaServer_WatchStatus_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Result",
+			Handler:       _MsaServer_Result_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "msaserver.proto",
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MSAStats summarizes the depth of a single a3m alignment so clients can
+// judge MSA quality without downloading and parsing the archive themselves.
+type MSAStats struct {
+	Sequences    int       `json:"sequences"`
+	QueryLength  int       `json:"query_length"`
+	Neff         float64   `json:"neff"`
+	Coverage     []float64 `json:"coverage"`
+	MeanCoverage float64   `json:"mean_coverage"`
+}
+
+// neffSampleLimit caps the number of sequences used for the pairwise
+// identity weighting; beyond this the O(N^2) computation dominates the
+// whole job, so we estimate Neff from a prefix sample and rescale.
+const neffSampleLimit = 2000
+
+const neffIdentityCutoff = 0.8
+
+// readA3MMatched reads an a3m stream and returns the sequences reduced to
+// query columns, i.e. with insertions (lowercase) removed.
+func readA3MMatched(reader io.Reader) ([]string, error) {
+	var sequences []string
+	var current strings.Builder
+	inSequence := false
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	flush := func() {
+		if inSequence {
+			sequences = append(sequences, current.String())
+			current.Reset()
+		}
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] == '#' {
+			continue
+		}
+		if line[0] == '>' {
+			flush()
+			inSequence = true
+			continue
+		}
+		if !inSequence {
+			continue
+		}
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			// lowercase residues are insertions relative to the query
+			if c >= 'a' && c <= 'z' {
+				continue
+			}
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sequences, nil
+}
+
+func pairwiseIdentity(a, b string) float64 {
+	length := len(a)
+	if len(b) < length {
+		length = len(b)
+	}
+	if length == 0 {
+		return 0
+	}
+	identical := 0
+	for i := 0; i < length; i++ {
+		if a[i] == b[i] && a[i] != '-' {
+			identical++
+		}
+	}
+	return float64(identical) / float64(length)
+}
+
+// ComputeMSAStats computes depth statistics for an a3m alignment. Neff is
+// the sum of per-sequence weights 1/n_i, where n_i counts the sequences
+// within neffIdentityCutoff sequence identity of sequence i.
+func ComputeMSAStats(reader io.Reader) (MSAStats, error) {
+	var stats MSAStats
+	sequences, err := readA3MMatched(reader)
+	if err != nil {
+		return stats, err
+	}
+	stats.Sequences = len(sequences)
+	if len(sequences) == 0 {
+		return stats, nil
+	}
+	queryLength := len(sequences[0])
+	stats.QueryLength = queryLength
+	stats.Coverage = make([]float64, queryLength)
+	for _, sequence := range sequences {
+		for i := 0; i < queryLength && i < len(sequence); i++ {
+			if sequence[i] != '-' {
+				stats.Coverage[i]++
+			}
+		}
+	}
+	sum := 0.0
+	for i := range stats.Coverage {
+		stats.Coverage[i] /= float64(len(sequences))
+		sum += stats.Coverage[i]
+	}
+	stats.MeanCoverage = sum / float64(queryLength)
+
+	sample := sequences
+	if len(sample) > neffSampleLimit {
+		sample = sample[:neffSampleLimit]
+	}
+	weights := make([]float64, len(sample))
+	for i := range sample {
+		neighbors := 1
+		for j := range sample {
+			if i == j {
+				continue
+			}
+			if pairwiseIdentity(sample[i], sample[j]) >= neffIdentityCutoff {
+				neighbors++
+			}
+		}
+		weights[i] = 1.0 / float64(neighbors)
+	}
+	neff := 0.0
+	for _, w := range weights {
+		neff += w
+	}
+	if len(sample) < len(sequences) {
+		neff *= float64(len(sequences)) / float64(len(sample))
+	}
+	stats.Neff = math.Round(neff*100) / 100
+	return stats, nil
+}
+
+func ComputeMSAStatsFile(path string) (MSAStats, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return MSAStats{}, err
+	}
+	defer file.Close()
+	return ComputeMSAStats(file)
+}
+
+// ComputeResultStats computes statistics for every a3m file directly in
+// the given result directory, keyed by file name.
+func ComputeResultStats(base string) (map[string]MSAStats, error) {
+	matches, err := filepath.Glob(filepath.Join(base, "*.a3m"))
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]MSAStats)
+	for _, match := range matches {
+		stats, err := ComputeMSAStatsFile(match)
+		if err != nil {
+			return nil, err
+		}
+		result[filepath.Base(match)] = stats
+	}
+	return result, nil
+}
+
+// WriteResultStats writes stats.json into the result directory so it gets
+// packaged into the archive alongside the a3m files.
+func WriteResultStats(base string) (map[string]MSAStats, error) {
+	stats, err := ComputeResultStats(base)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(filepath.Join(base, "stats.json"))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	if err := json.NewEncoder(file).Encode(stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// AttachResultStats fills Ticket.Stats from the stats.json the worker
+// wrote next to the archive; it only applies to COMPLETE tickets and
+// leaves the ticket untouched when no statistics were computed.
+func AttachResultStats(jobsbase string, ticket *Ticket) {
+	if ticket.Status != StatusComplete {
+		return
+	}
+	stats, err := ReadResultStats(filepath.Join(jobsbase, string(ticket.Id)))
+	if err != nil {
+		return
+	}
+	ticket.Stats = stats
+}
+
+// ReadResultStats loads a previously written stats.json; it returns nil
+// without error if no statistics were computed for the job.
+func ReadResultStats(base string) (map[string]MSAStats, error) {
+	file, err := os.Open(filepath.Join(base, "stats.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+	var stats map[string]MSAStats
+	if err := json.NewDecoder(file).Decode(&stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestComputeMSAStats(t *testing.T) {
+	a3m := strings.Join([]string{
+		">query",
+		"ACDEF",
+		">close",
+		"ACDEF",
+		">distant",
+		"AC--F",
+	}, "\n")
+	stats, err := ComputeMSAStats(strings.NewReader(a3m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sequences != 3 {
+		t.Errorf("sequences: got %d, want 3", stats.Sequences)
+	}
+	if stats.QueryLength != 5 {
+		t.Errorf("query length: got %d, want 5", stats.QueryLength)
+	}
+	// query and close are each other's only neighbor above the 0.8
+	// identity cutoff (weight 1/2 each); distant sits at 0.6 identity
+	// and keeps weight 1, so Neff = 0.5 + 0.5 + 1 = 2
+	if stats.Neff != 2.0 {
+		t.Errorf("neff: got %v, want 2", stats.Neff)
+	}
+	// two of five columns are gapped in one of three sequences
+	want := 13.0 / 15.0
+	if math.Abs(stats.MeanCoverage-want) > 1e-9 {
+		t.Errorf("mean coverage: got %v, want %v", stats.MeanCoverage, want)
+	}
+}
+
+func TestComputeMSAStatsInsertions(t *testing.T) {
+	// lowercase residues are insertions relative to the query and must
+	// not count as columns
+	a3m := ">query\nACDEF\n>hit\nACdefDEF\n"
+	stats, err := ComputeMSAStats(strings.NewReader(a3m))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.QueryLength != 5 {
+		t.Errorf("query length: got %d, want 5", stats.QueryLength)
+	}
+	if stats.Neff != 1.0 {
+		t.Errorf("neff: got %v, want 1", stats.Neff)
+	}
+}
+
+func TestComputeMSAStatsEmpty(t *testing.T) {
+	stats, err := ComputeMSAStats(strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Sequences != 0 || stats.Neff != 0 {
+		t.Errorf("empty alignment: got %+v, want zero stats", stats)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// vizSequenceLimit caps how many sequences the viewer payload carries;
+// the alignment viewer subsamples anyway and the full MSA stays
+// available as a3m.
+const vizSequenceLimit = 500
+
+// VizSequence is one alignment row in the viewer payload.
+type VizSequence struct {
+	Name     string `json:"name"`
+	Sequence string `json:"sequence"`
+	TaxId    string `json:"taxid,omitempty"`
+}
+
+// VizTaxon is one taxonomy color key: the viewer assigns the color, the
+// server supplies the grouping and how common it is.
+type VizTaxon struct {
+	TaxId string `json:"taxid"`
+	Count int    `json:"count"`
+}
+
+// MsaVisualization is the compact JSON representation of an MSA for the
+// web frontend's alignment viewer, so the browser never parses huge a3m
+// files: match-state sequences only, per-position coverage and the
+// taxonomy groups present.
+type MsaVisualization struct {
+	Query     string        `json:"query"`
+	Total     int           `json:"total_sequences"`
+	Sequences []VizSequence `json:"sequences"`
+	Coverage  []float64     `json:"coverage"`
+	Taxa      []VizTaxon    `json:"taxa,omitempty"`
+}
+
+// BuildMsaVisualization reduces an a3m alignment (plus optional
+// taxonomy annotations) to the viewer payload.
+func BuildMsaVisualization(entries []a3mEntry, taxonomy map[string]string) MsaVisualization {
+	viz := MsaVisualization{Total: len(entries)}
+	if len(entries) == 0 {
+		return viz
+	}
+	viz.Query = entries[0].Name
+	queryLength := len(entries[0].Sequence)
+	viz.Coverage = make([]float64, queryLength)
+	taxCounts := make(map[string]int)
+	for index, entry := range entries {
+		for i := 0; i < queryLength && i < len(entry.Sequence); i++ {
+			if entry.Sequence[i] != '-' {
+				viz.Coverage[i]++
+			}
+		}
+		taxid := taxonomy[entry.Name]
+		if taxid != "" {
+			taxCounts[taxid]++
+		}
+		if index < vizSequenceLimit {
+			viz.Sequences = append(viz.Sequences, VizSequence{
+				Name:     entry.Name,
+				Sequence: entry.Sequence,
+				TaxId:    taxid,
+			})
+		}
+	}
+	for i := range viz.Coverage {
+		viz.Coverage[i] /= float64(len(entries))
+	}
+	for taxid, count := range taxCounts {
+		viz.Taxa = append(viz.Taxa, VizTaxon{TaxId: taxid, Count: count})
+	}
+	sort.Slice(viz.Taxa, func(i, j int) bool {
+		if viz.Taxa[i].Count != viz.Taxa[j].Count {
+			return viz.Taxa[i].Count > viz.Taxa[j].Count
+		}
+		return viz.Taxa[i].TaxId < viz.Taxa[j].TaxId
+	})
+	return viz
+}
+
+// RegisterVizRoute adds GET /ticket/{ticket}/viz/{name}, which serves
+// the viewer payload for one a3m of a finished job. The payload is
+// cached as <name>.viz.json in the result directory so repeated viewer
+// loads skip the a3m parse.
+func RegisterVizRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/viz/{name}", func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		id := Id(vars["ticket"])
+		name := vars["name"]
+		if name != filepath.Base(name) || !strings.HasSuffix(name, ".a3m") {
+			http.Error(w, "invalid file name", http.StatusBadRequest)
+			return
+		}
+		status, err := jobsystem.Status(id)
+		if err != nil || status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusNotFound)
+			return
+		}
+		path := filepath.Join(jobsbase, string(id), name)
+		cached := path + ".viz.json"
+		if payload, err := os.ReadFile(cached); err == nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(payload)
+			return
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			http.Error(w, "no such result file", http.StatusNotFound)
+			return
+		}
+		entries, err := readA3MEntries(file)
+		file.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		viz := BuildMsaVisualization(entries, readTaxAnnotations(path+".tax"))
+		payload, err := json.Marshal(viz)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		os.WriteFile(cached, payload, 0644)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(payload)
+	}).Methods("GET")
+}
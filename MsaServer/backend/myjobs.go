@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const myJobsDefaultLimit = 50
+
+// myJobsPage is one page of a user's tickets plus the cursor for the
+// next request.
+type myJobsPage struct {
+	Tickets []AdminTicket `json:"tickets"`
+	Total   int           `json:"total"`
+	Offset  int           `json:"offset"`
+	Limit   int           `json:"limit"`
+}
+
+// RegisterMyJobsRoute adds GET /my/tickets, which lists the tickets
+// submitted under the caller's API key with optional status, type and
+// date-range filters plus offset/limit pagination. Users no longer have
+// to keep their own ticket ledger.
+//
+// Query parameters: status (comma separated), type, since, until
+// (RFC 3339), offset, limit.
+func RegisterMyJobsRoute(r *mux.Router, jobsystem JobSystem) {
+	store, ok := UnwrapJobSystem(jobsystem).(AdminSystem)
+	if !ok {
+		return
+	}
+	r.HandleFunc("/my/tickets", func(w http.ResponseWriter, req *http.Request) {
+		apiKey := req.Header.Get("X-API-Key")
+		if apiKey == "" {
+			http.Error(w, "missing X-API-Key header", http.StatusUnauthorized)
+			return
+		}
+		var statuses []Status
+		if filter := req.URL.Query().Get("status"); filter != "" {
+			for _, status := range strings.Split(filter, ",") {
+				statuses = append(statuses, Status(status))
+			}
+		}
+		jobType := req.URL.Query().Get("type")
+		var since, until time.Time
+		if value := req.URL.Query().Get("since"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "invalid since timestamp", http.StatusBadRequest)
+				return
+			}
+			since = parsed
+		}
+		if value := req.URL.Query().Get("until"); value != "" {
+			parsed, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				http.Error(w, "invalid until timestamp", http.StatusBadRequest)
+				return
+			}
+			until = parsed
+		}
+		offset, _ := strconv.Atoi(req.URL.Query().Get("offset"))
+		if offset < 0 {
+			offset = 0
+		}
+		limit, err := strconv.Atoi(req.URL.Query().Get("limit"))
+		if err != nil || limit <= 0 || limit > 1000 {
+			limit = myJobsDefaultLimit
+		}
+		tickets, err := store.ListTickets(statuses)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		var mine []AdminTicket
+		for _, ticket := range tickets {
+			if ticket.ApiKey != apiKey {
+				continue
+			}
+			if jobType != "" && ticket.Type != JobType(jobType) {
+				continue
+			}
+			if !since.IsZero() && ticket.Created.Before(since) {
+				continue
+			}
+			if !until.IsZero() && ticket.Created.After(until) {
+				continue
+			}
+			mine = append(mine, ticket)
+		}
+		page := myJobsPage{Total: len(mine), Offset: offset, Limit: limit}
+		if offset < len(mine) {
+			end := offset + limit
+			if end > len(mine) {
+				end = len(mine)
+			}
+			page.Tickets = mine[offset:end]
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(page)
+	}).Methods("GET")
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// chooseResultFormat picks the delivery format for a result download
+// from the format query parameter, falling back to the Accept header;
+// tar.gz remains the default.
+func chooseResultFormat(req *http.Request) string {
+	switch req.URL.Query().Get("format") {
+	case "zip":
+		return "zip"
+	case "json":
+		return "json"
+	case "tar.zst", "tarzst":
+		return "tar.zst"
+	case "tar.gz", "targz":
+		return "tar.gz"
+	}
+	accept := req.Header.Get("Accept")
+	switch {
+	case strings.Contains(accept, "application/zip"):
+		return "zip"
+	case strings.Contains(accept, "application/zstd"):
+		return "tar.zst"
+	case strings.Contains(accept, "application/json"):
+		return "json"
+	}
+	return "tar.gz"
+}
+
+// serveResultZip re-encodes the stored tar.gz archive as a zip on the
+// fly for Windows-leaning clients that cannot unpack tarballs.
+func serveResultZip(w http.ResponseWriter, path string, id Id) {
+	file, err := os.Open(path)
+	if err != nil {
+		http.Error(w, "no result for ticket", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	decompressed, err := gzip.NewReader(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer decompressed.Close()
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", "attachment; filename=\"mmseqs_results_"+string(id)+".zip\"")
+	archive := zip.NewWriter(w)
+	defer archive.Close()
+	reader := tar.NewReader(decompressed)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			return
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     header.Name,
+			Method:   zip.Deflate,
+			Modified: header.ModTime,
+		})
+		if err != nil {
+			return
+		}
+		if _, err := io.Copy(entry, reader); err != nil {
+			return
+		}
+	}
+}
+
+// ResultHit is one row of an m8 hit table with typed fields, so web
+// frontends and notebooks can consume hits without a custom parser.
+type ResultHit struct {
+	Query       string  `json:"query"`
+	Target      string  `json:"target"`
+	Identity    float64 `json:"identity"`
+	AlnLength   int     `json:"alnlen"`
+	Mismatches  int     `json:"mismatches"`
+	GapOpens    int     `json:"gapopens"`
+	QueryStart  int     `json:"qstart"`
+	QueryEnd    int     `json:"qend"`
+	TargetStart int     `json:"tstart"`
+	TargetEnd   int     `json:"tend"`
+	Evalue      float64 `json:"evalue"`
+	Bitscore    float64 `json:"bitscore"`
+	// TaxId and TaxName are set when the search ran with taxonomy
+	// columns appended to the m8 output.
+	TaxId   string `json:"taxid,omitempty"`
+	TaxName string `json:"taxname,omitempty"`
+}
+
+// parseM8Line parses one tab-separated m8 row; extra trailing columns
+// are interpreted as taxonomy annotations.
+func parseM8Line(line string) (ResultHit, bool) {
+	fields := strings.Split(line, "\t")
+	if len(fields) < 12 {
+		return ResultHit{}, false
+	}
+	atoi := func(value string) int {
+		parsed, _ := strconv.Atoi(value)
+		return parsed
+	}
+	atof := func(value string) float64 {
+		parsed, _ := strconv.ParseFloat(value, 64)
+		return parsed
+	}
+	hit := ResultHit{
+		Query:       fields[0],
+		Target:      fields[1],
+		Identity:    atof(fields[2]),
+		AlnLength:   atoi(fields[3]),
+		Mismatches:  atoi(fields[4]),
+		GapOpens:    atoi(fields[5]),
+		QueryStart:  atoi(fields[6]),
+		QueryEnd:    atoi(fields[7]),
+		TargetStart: atoi(fields[8]),
+		TargetEnd:   atoi(fields[9]),
+		Evalue:      atof(fields[10]),
+		Bitscore:    atof(fields[11]),
+	}
+	if len(fields) > 12 {
+		hit.TaxId = fields[12]
+	}
+	if len(fields) > 13 {
+		hit.TaxName = fields[13]
+	}
+	return hit, true
+}
+
+// serveResultJson answers with a summary of the m8 hit tables in the
+// result directory instead of the archive, for clients that only want
+// the hits.
+func serveResultJson(w http.ResponseWriter, resultBase string, id Id) {
+	matches, err := filepath.Glob(filepath.Join(resultBase, "*.m8"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	summary := make(map[string][]ResultHit)
+	for _, path := range matches {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		var hits []ResultHit
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for scanner.Scan() {
+			if hit, ok := parseM8Line(scanner.Text()); ok {
+				hits = append(hits, hit)
+			}
+		}
+		file.Close()
+		summary[filepath.Base(path)] = hits
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":   id,
+		"hits": summary,
+	})
+}
+
+// RegisterHitsRoute adds GET /ticket/{ticket}/hits, the structured JSON
+// view over the m8 hit tables of a finished search or structure search.
+func RegisterHitsRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/hits", func(w http.ResponseWriter, req *http.Request) {
+		id := Id(mux.Vars(req)["ticket"])
+		status, err := jobsystem.Status(id)
+		if err != nil || status == StatusUnknown {
+			http.Error(w, "unknown ticket", http.StatusNotFound)
+			return
+		}
+		if status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusConflict)
+			return
+		}
+		serveResultJson(w, filepath.Join(jobsbase, string(id)), id)
+	}).Methods("GET")
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/gorilla/mux"
+)
+
+type ConfigObjectStore struct {
+	Enabled bool `json:"enabled"`
+	// Provider is "s3" or "gcs".
+	Provider string `json:"provider"`
+	Bucket   string `json:"bucket"`
+	// Prefix is prepended to all object keys.
+	Prefix string `json:"prefix"`
+	// Region and Endpoint configure the S3 client; Endpoint allows
+	// S3-compatible stores like MinIO.
+	Region   string `json:"region"`
+	Endpoint string `json:"endpoint"`
+	// UrlExpiryMinutes bounds how long presigned URLs stay valid.
+	UrlExpiryMinutes int `json:"urlexpiryminutes"`
+}
+
+// ObjectStore pushes finished archives to object storage and hands out
+// presigned download URLs, keeping multi-GB transfers off the Go server.
+type ObjectStore interface {
+	Upload(localPath string, key string) error
+	Exists(key string) (bool, error)
+	PresignedUrl(key string, expiry time.Duration) (string, error)
+}
+
+func (c *ConfigObjectStore) expiry() time.Duration {
+	if c.UrlExpiryMinutes <= 0 {
+		return time.Hour
+	}
+	return time.Duration(c.UrlExpiryMinutes) * time.Minute
+}
+
+// resultObjectKey is where a ticket's archive lives in the bucket.
+func resultObjectKey(prefix string, id Id) string {
+	key := "results/" + string(id) + ".tar.gz"
+	if prefix != "" {
+		key = prefix + "/" + key
+	}
+	return key
+}
+
+// MakeObjectStore builds the configured store; it returns nil when
+// offloading is disabled.
+func MakeObjectStore(config ConfigObjectStore) (ObjectStore, error) {
+	if !config.Enabled {
+		return nil, nil
+	}
+	switch config.Provider {
+	case "s3":
+		awsConfig := &aws.Config{Region: aws.String(config.Region)}
+		if config.Endpoint != "" {
+			awsConfig.Endpoint = aws.String(config.Endpoint)
+			awsConfig.S3ForcePathStyle = aws.Bool(true)
+		}
+		sess, err := session.NewSession(awsConfig)
+		if err != nil {
+			return nil, err
+		}
+		return &s3ObjectStore{bucket: config.Bucket, client: s3.New(sess), uploader: s3manager.NewUploader(sess)}, nil
+	case "gcs":
+		client, err := storage.NewClient(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return &gcsObjectStore{bucket: config.Bucket, client: client}, nil
+	}
+	return nil, errors.New("unknown object store provider " + config.Provider)
+}
+
+type s3ObjectStore struct {
+	bucket   string
+	client   *s3.S3
+	uploader *s3manager.Uploader
+}
+
+func (s *s3ObjectStore) Upload(localPath string, key string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = s.uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   file,
+	})
+	return err
+}
+
+func (s *s3ObjectStore) Exists(key string) (bool, error) {
+	_, err := s.client.HeadObject(&s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var failure awserr.RequestFailure
+		if errors.As(err, &failure) && failure.StatusCode() == http.StatusNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *s3ObjectStore) PresignedUrl(key string, expiry time.Duration) (string, error) {
+	request, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	return request.Presign(expiry)
+}
+
+type gcsObjectStore struct {
+	bucket string
+	client *storage.Client
+}
+
+func (g *gcsObjectStore) Upload(localPath string, key string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	writer := g.client.Bucket(g.bucket).Object(key).NewWriter(ctx)
+	if _, err := io.Copy(writer, file); err != nil {
+		writer.Close()
+		return err
+	}
+	return writer.Close()
+}
+
+func (g *gcsObjectStore) Exists(key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err := g.client.Bucket(g.bucket).Object(key).Attrs(ctx)
+	if err == storage.ErrObjectNotExist {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (g *gcsObjectStore) PresignedUrl(key string, expiry time.Duration) (string, error) {
+	return g.client.Bucket(g.bucket).SignedURL(key, &storage.SignedURLOptions{
+		Method:  "GET",
+		Expires: time.Now().Add(expiry),
+	})
+}
+
+// OffloadResultArchive pushes a finished archive to the object store;
+// workers call it after archiving when offloading is enabled.
+func OffloadResultArchive(store ObjectStore, config ConfigObjectStore, jobsbase string, id Id) error {
+	if store == nil {
+		return nil
+	}
+	return store.Upload(resultArchivePath(jobsbase, id), resultObjectKey(config.Prefix, id))
+}
+
+// RegisterResultUrlRoute adds GET /result/url/{ticket}, which returns a
+// time-limited presigned URL for the offloaded archive instead of
+// streaming it through this server.
+func RegisterResultUrlRoute(r *mux.Router, jobsystem JobSystem, store ObjectStore, config ConfigObjectStore) {
+	if store == nil {
+		return
+	}
+	r.HandleFunc("/result/url/{ticket}", func(w http.ResponseWriter, req *http.Request) {
+		id := Id(mux.Vars(req)["ticket"])
+		status, err := jobsystem.Status(id)
+		if err != nil || status == StatusUnknown {
+			http.Error(w, "unknown ticket", http.StatusNotFound)
+			return
+		}
+		if status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusConflict)
+			return
+		}
+		// never hand out a URL that will 404 at the store
+		offloaded, err := store.Exists(resultObjectKey(config.Prefix, id))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !offloaded {
+			http.Error(w, "result is not offloaded", http.StatusNotFound)
+			return
+		}
+		expiry := config.expiry()
+		url, err := store.PresignedUrl(resultObjectKey(config.Prefix, id), expiry)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"id":      string(id),
+			"url":     url,
+			"expires": time.Now().Add(expiry).UTC().Format(time.RFC3339),
+		})
+	}).Methods("GET")
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// openapiSummaries carries the hand-written operation summaries; paths
+// are keyed as "METHOD /path". Routes without an entry still appear in
+// the spec — the path list itself is walked from the live router, so
+// the description cannot drift from what the server actually serves.
+var openapiSummaries = map[string]string{
+	"POST /ticket/msa":               "Submit an MSA job",
+	"POST /ticket/pair":              "Submit a paired-MSA job",
+	"POST /ticket/search":            "Submit a sequence search",
+	"POST /ticket/structuresearch":   "Submit a foldseek structure search",
+	"POST /ticket/combined":          "Submit a combined sequence and structure job",
+	"GET /ticket/{ticket}":           "Ticket status with transition history",
+	"DELETE /ticket/{ticket}":        "Delete a ticket and its results",
+	"GET /ticket/{ticket}/events":    "Server-sent events stream of status changes",
+	"POST /ticket/{ticket}/resubmit": "Resubmit a ticket reusing its stored inputs",
+	"POST /api/v2/tickets":           "Submit a structured v2 job",
+	"GET /api/v2/tickets/{ticket}":   "Ticket status (v2)",
+	"POST /tickets":                  "Bulk ticket status",
+	"GET /result/download/{ticket}":  "Download the result archive",
+	"POST /validate":                 "Validate a query without submitting it",
+	"GET /databases":                 "List installed databases with metadata",
+	"GET /healthz":                   "Liveness probe",
+	"GET /readyz":                    "Readiness probe",
+	"POST /uploads":                  "Start a resumable upload",
+	"GET /openapi.json":              "This API description",
+}
+
+var openapiPathParameter = regexp.MustCompile(`\{([^}]+)\}`)
+
+// openapiSpec builds the OpenAPI 3.0 description from the routes that
+// are actually registered on the router.
+func openapiSpec(r *mux.Router) map[string]interface{} {
+	ticketSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id": map[string]interface{}{"type": "string"},
+			"status": map[string]interface{}{
+				"type": "string",
+				"enum": []string{
+					string(StatusPending), string(StatusRunning),
+					string(StatusComplete), string(StatusError),
+					string(StatusUnknown), string(StatusDeadLetter),
+					string(StatusExpired), string(StatusCancelled),
+				},
+			},
+			"history": map[string]interface{}{
+				"type": "array",
+				"items": map[string]interface{}{
+					"$ref": "#/components/schemas/Transition",
+				},
+			},
+		},
+	}
+	transitionSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"status": map[string]interface{}{"type": "string"},
+			"at":     map[string]interface{}{"type": "string", "format": "date-time"},
+			"worker": map[string]interface{}{"type": "string"},
+		},
+	}
+
+	paths := make(map[string]interface{})
+	r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			// subrouter matchers and method-less routes are not endpoints
+			return nil
+		}
+		var parameters []interface{}
+		for _, match := range openapiPathParameter.FindAllStringSubmatch(template, -1) {
+			parameters = append(parameters, map[string]interface{}{
+				"name":     match[1],
+				"in":       "path",
+				"required": true,
+				"schema":   map[string]interface{}{"type": "string"},
+			})
+		}
+		entry, found := paths[template].(map[string]interface{})
+		if !found {
+			entry = make(map[string]interface{})
+			paths[template] = entry
+		}
+		sort.Strings(methods)
+		for _, method := range methods {
+			operation := map[string]interface{}{
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{"description": "success"},
+				},
+			}
+			if summary, documented := openapiSummaries[method+" "+template]; documented {
+				operation["summary"] = summary
+			}
+			if len(parameters) > 0 {
+				operation["parameters"] = parameters
+			}
+			entry[strings.ToLower(method)] = operation
+		}
+		return nil
+	})
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "ColabFold MSA server",
+			"version": "1.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Ticket":     ticketSchema,
+				"Transition": transitionSchema,
+			},
+		},
+	}
+}
+
+// RegisterOpenApiRoute serves the API description at /openapi.json. The
+// spec is walked from the router at request time, so routes registered
+// after this call are included too.
+func RegisterOpenApiRoute(r *mux.Router) {
+	r.HandleFunc("/openapi.json", func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(openapiSpec(r))
+	}).Methods("GET")
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetHit is the Parquet schema for exported alignment hits; the
+// File column records which m8 table the row came from so multi-database
+// results stay distinguishable after loading into pandas or Spark.
+type parquetHit struct {
+	File        string  `parquet:"file,dict"`
+	Query       string  `parquet:"query,dict"`
+	Target      string  `parquet:"target"`
+	Identity    float64 `parquet:"identity"`
+	AlnLength   int32   `parquet:"alnlen"`
+	Mismatches  int32   `parquet:"mismatches"`
+	GapOpens    int32   `parquet:"gapopens"`
+	QueryStart  int32   `parquet:"qstart"`
+	QueryEnd    int32   `parquet:"qend"`
+	TargetStart int32   `parquet:"tstart"`
+	TargetEnd   int32   `parquet:"tend"`
+	Evalue      float64 `parquet:"evalue"`
+	Bitscore    float64 `parquet:"bitscore"`
+	TaxId       string  `parquet:"taxid,dict,optional"`
+	TaxName     string  `parquet:"taxname,dict,optional"`
+}
+
+func parquetFromHit(file string, hit ResultHit) parquetHit {
+	return parquetHit{
+		File:        file,
+		Query:       hit.Query,
+		Target:      hit.Target,
+		Identity:    hit.Identity,
+		AlnLength:   int32(hit.AlnLength),
+		Mismatches:  int32(hit.Mismatches),
+		GapOpens:    int32(hit.GapOpens),
+		QueryStart:  int32(hit.QueryStart),
+		QueryEnd:    int32(hit.QueryEnd),
+		TargetStart: int32(hit.TargetStart),
+		TargetEnd:   int32(hit.TargetEnd),
+		Evalue:      hit.Evalue,
+		Bitscore:    hit.Bitscore,
+		TaxId:       hit.TaxId,
+		TaxName:     hit.TaxName,
+	}
+}
+
+// collectResultHits reads every m8 table in the result directory into
+// Parquet rows.
+func collectResultHits(resultBase string) ([]parquetHit, error) {
+	matches, err := filepath.Glob(filepath.Join(resultBase, "*.m8"))
+	if err != nil {
+		return nil, err
+	}
+	var rows []parquetHit
+	for _, path := range matches {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+		for scanner.Scan() {
+			if hit, ok := parseM8Line(scanner.Text()); ok {
+				rows = append(rows, parquetFromHit(filepath.Base(path), hit))
+			}
+		}
+		file.Close()
+	}
+	return rows, nil
+}
+
+// RegisterParquetRoute adds GET /ticket/{ticket}/hits.parquet, which
+// exports all alignment hits of a finished batch search as one Parquet
+// file for direct loading into pandas or Spark.
+func RegisterParquetRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/hits.parquet", func(w http.ResponseWriter, req *http.Request) {
+		id := Id(mux.Vars(req)["ticket"])
+		status, err := jobsystem.Status(id)
+		if err != nil || status == StatusUnknown {
+			http.Error(w, "unknown ticket", http.StatusNotFound)
+			return
+		}
+		if status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusConflict)
+			return
+		}
+		rows, err := collectResultHits(filepath.Join(jobsbase, string(id)))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apache.parquet")
+		w.Header().Set("Content-Disposition", "attachment; filename=\"hits_"+string(id)+".parquet\"")
+		writer := parquet.NewGenericWriter[parquetHit](w, parquet.Compression(&parquet.Zstd))
+		if _, err := writer.Write(rows); err != nil {
+			return
+		}
+		writer.Close()
+	}).Methods("GET")
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+)
+
+// mmseqs .dbtype values; only the ones the server distinguishes.
+const (
+	dbTypeAminoAcid = 0
+	dbTypeProfile   = 2
+)
+
+// readDbType reads the type tag of an mmseqs database; -1 when it
+// cannot be determined.
+func readDbType(path string) int {
+	raw, err := os.ReadFile(path + ".dbtype")
+	if err != nil || len(raw) < 4 {
+		return -1
+	}
+	return int(binary.LittleEndian.Uint32(raw[:4]))
+}
+
+// IsProfileDatabase reports whether a search target is a profile
+// database (e.g. Pfam or a UniRef30 profile build). The .params
+// sidecar can force it; otherwise the .dbtype tag decides.
+func IsProfileDatabase(root string, name string) bool {
+	if params, err := ReadDbParams(root, name); err == nil && params.ProfileTarget {
+		return true
+	}
+	return readDbType(filepath.Join(root, name)) == dbTypeProfile
+}
+
+// AdjustSearchArgsForTarget fixes up search arguments for the target
+// kind: iterative profile search is only valid sequence-vs-sequence,
+// so against a profile target --num-iterations is dropped (mmseqs
+// refuses profile-vs-profile iteration) and the plain
+// sequence-vs-profile direction is used.
+func AdjustSearchArgsForTarget(args []string, profileTarget bool) []string {
+	if !profileTarget {
+		return args
+	}
+	adjusted := make([]string, 0, len(args))
+	for index := 0; index < len(args); index++ {
+		if args[index] == "--num-iterations" {
+			index++
+			continue
+		}
+		adjusted = append(adjusted, args[index])
+	}
+	return adjusted
+}
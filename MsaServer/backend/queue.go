@@ -0,0 +1,88 @@
+package main
+
+// TicketQueue abstracts the pending-ticket transport so deployments can
+// queue through a managed service while ticket state lives in one of the
+// stateful job systems.
+type TicketQueue interface {
+	Push(id Id, priority Priority) error
+	// Pop returns the next ticket id; ok is false when the queue is
+	// currently empty.
+	Pop() (id Id, ok bool, err error)
+	Length() (int, error)
+}
+
+// QueuedJobSystem combines a stateful job system (ticket records, status,
+// history) with an external queue transport (SQS, Pub/Sub). The store's
+// own queue is bypassed; ordering guarantees are those of the transport.
+type QueuedJobSystem struct {
+	store JobSystem
+	queue TicketQueue
+}
+
+func MakeQueuedJobSystem(store JobSystem, queue TicketQueue) *QueuedJobSystem {
+	return &QueuedJobSystem{store: store, queue: queue}
+}
+
+// Unwrap exposes the ticket store so capability checks (admin,
+// dead-letter, history) can reach the underlying implementation.
+func (j *QueuedJobSystem) Unwrap() JobSystem {
+	return j.store
+}
+
+func (j *QueuedJobSystem) SetStatus(id Id, status Status) error {
+	return j.store.SetStatus(id, status)
+}
+
+func (j *QueuedJobSystem) Status(id Id) (Status, error) {
+	return j.store.Status(id)
+}
+
+func (j *QueuedJobSystem) GetTicket(id Id) (Ticket, error) {
+	return j.store.GetTicket(id)
+}
+
+func (j *QueuedJobSystem) NewJob(request JobRequest, jobsbase string, allowResubmit bool) (Ticket, error) {
+	ticket, err := j.store.NewJob(request, jobsbase, allowResubmit)
+	if err != nil {
+		return ticket, err
+	}
+	if ticket.Status == StatusPending {
+		if err := j.queue.Push(ticket.Id, request.Priority); err != nil {
+			return ticket, err
+		}
+	}
+	return ticket, nil
+}
+
+func (j *QueuedJobSystem) MultiStatus(ids []string) ([]Ticket, error) {
+	return j.store.MultiStatus(ids)
+}
+
+func (j *QueuedJobSystem) Dequeue() (*Ticket, error) {
+	for {
+		id, ok, err := j.queue.Pop()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, nil
+		}
+		status, err := j.store.Status(id)
+		if err != nil {
+			return nil, err
+		}
+		// drop messages for tickets that were cancelled or already
+		// handled elsewhere
+		if status != StatusPending {
+			continue
+		}
+		if err := j.store.SetStatus(id, StatusRunning); err != nil {
+			return nil, err
+		}
+		return &Ticket{Id: id, Status: StatusRunning}, nil
+	}
+}
+
+func (j *QueuedJobSystem) QueueLength() (int, error) {
+	return j.queue.Length()
+}
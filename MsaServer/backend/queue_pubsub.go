@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"cloud.google.com/go/pubsub"
+)
+
+type ConfigPubSub struct {
+	Project      string `json:"project"`
+	Topic        string `json:"topic"`
+	Subscription string `json:"subscription"`
+}
+
+// PubSubQueue queues pending ticket ids through Google Cloud Pub/Sub.
+// The streaming subscription is bridged into the pull-style TicketQueue
+// interface through an internal channel.
+type PubSubQueue struct {
+	client   *pubsub.Client
+	topic    *pubsub.Topic
+	messages chan Id
+	cancel   context.CancelFunc
+	err      error
+}
+
+func MakePubSubQueue(config ConfigPubSub) (*PubSubQueue, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	client, err := pubsub.NewClient(ctx, config.Project)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	queue := &PubSubQueue{
+		client:   client,
+		topic:    client.Topic(config.Topic),
+		messages: make(chan Id, 64),
+		cancel:   cancel,
+	}
+	subscription := client.Subscription(config.Subscription)
+	subscription.ReceiveSettings.MaxOutstandingMessages = cap(queue.messages)
+	go func() {
+		queue.err = subscription.Receive(ctx, func(ctx context.Context, message *pubsub.Message) {
+			select {
+			case queue.messages <- Id(message.Data):
+				message.Ack()
+			case <-ctx.Done():
+				message.Nack()
+			}
+		})
+		close(queue.messages)
+	}()
+	return queue, nil
+}
+
+func (q *PubSubQueue) Close() {
+	q.cancel()
+	q.topic.Stop()
+	q.client.Close()
+}
+
+func (q *PubSubQueue) Push(id Id, priority Priority) error {
+	result := q.topic.Publish(context.Background(), &pubsub.Message{
+		Data: []byte(id),
+		Attributes: map[string]string{
+			"priority": strconv.Itoa(int(priority)),
+		},
+	})
+	_, err := result.Get(context.Background())
+	return err
+}
+
+func (q *PubSubQueue) Pop() (Id, bool, error) {
+	select {
+	case id, ok := <-q.messages:
+		if !ok {
+			return "", false, q.err
+		}
+		return id, true, nil
+	default:
+		return "", false, nil
+	}
+}
+
+// Length is not exposed by Pub/Sub without the monitoring API; only the
+// locally buffered backlog is reported.
+func (q *PubSubQueue) Length() (int, error) {
+	return len(q.messages), nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+type ConfigSqs struct {
+	Region   string `json:"region"`
+	QueueURL string `json:"queueurl"`
+}
+
+// SqsQueue queues pending ticket ids through Amazon SQS. Priorities are
+// attached as a message attribute; SQS itself does not reorder, so
+// deployments that need strict priority ordering should use one queue
+// per class.
+type SqsQueue struct {
+	client   *sqs.SQS
+	queueURL string
+}
+
+func MakeSqsQueue(config ConfigSqs) (*SqsQueue, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(config.Region)})
+	if err != nil {
+		return nil, err
+	}
+	return &SqsQueue{client: sqs.New(sess), queueURL: config.QueueURL}, nil
+}
+
+func (q *SqsQueue) Push(id Id, priority Priority) error {
+	_, err := q.client.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:    aws.String(q.queueURL),
+		MessageBody: aws.String(string(id)),
+		MessageAttributes: map[string]*sqs.MessageAttributeValue{
+			"priority": {
+				DataType:    aws.String("Number"),
+				StringValue: aws.String(strconv.Itoa(int(priority))),
+			},
+		},
+	})
+	return err
+}
+
+func (q *SqsQueue) Pop() (Id, bool, error) {
+	response, err := q.client.ReceiveMessage(&sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(q.queueURL),
+		MaxNumberOfMessages: aws.Int64(1),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if len(response.Messages) == 0 {
+		return "", false, nil
+	}
+	message := response.Messages[0]
+	if _, err := q.client.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      aws.String(q.queueURL),
+		ReceiptHandle: message.ReceiptHandle,
+	}); err != nil {
+		return "", false, err
+	}
+	return Id(aws.StringValue(message.Body)), true, nil
+}
+
+func (q *SqsQueue) Length() (int, error) {
+	response, err := q.client.GetQueueAttributes(&sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(q.queueURL),
+		AttributeNames: []*string{aws.String(sqs.QueueAttributeNameApproximateNumberOfMessages)},
+	})
+	if err != nil {
+		return 0, err
+	}
+	value := response.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages]
+	if value == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(aws.StringValue(value))
+}
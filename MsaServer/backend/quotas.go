@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// QuotaLimits bounds what a single API key may consume. Zero values mean
+// unlimited, so deployments can enable only the limits they care about.
+type QuotaLimits struct {
+	MaxRunning    int     `json:"maxrunning"`
+	MaxQueued     int     `json:"maxqueued"`
+	DailyCpuHours float64 `json:"dailycpuhours"`
+}
+
+type ConfigQuotas struct {
+	Enabled bool        `json:"enabled"`
+	Default QuotaLimits `json:"default"`
+	// Keys overrides the default limits for individual API keys.
+	Keys map[string]QuotaLimits `json:"keys"`
+}
+
+func (c *ConfigQuotas) limitsFor(apiKey string) QuotaLimits {
+	if limits, found := c.Keys[apiKey]; found {
+		return limits
+	}
+	return c.Default
+}
+
+// QuotaError is returned to the API as a quota-exceeded response (HTTP
+// 429) with enough detail for the user to understand which limit they hit.
+type QuotaError struct {
+	Limit string `json:"limit"`
+	Used  string `json:"used"`
+	Max   string `json:"max"`
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded: %s (%s of %s used)", e.Limit, e.Used, e.Max)
+}
+
+// QuotaBackend is the usage accounting a job system has to provide for
+// quota enforcement.
+type QuotaBackend interface {
+	UsageCounts(apiKey string) (running int, queued int, err error)
+	CpuSecondsSince(apiKey string, since time.Time) (int64, error)
+	AddCpuSeconds(apiKey string, seconds int64) error
+}
+
+type QuotaEnforcer struct {
+	config  ConfigQuotas
+	backend QuotaBackend
+}
+
+func MakeQuotaEnforcer(config ConfigQuotas, backend QuotaBackend) *QuotaEnforcer {
+	return &QuotaEnforcer{config: config, backend: backend}
+}
+
+func startOfDay(now time.Time) time.Time {
+	year, month, day := now.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// CheckSubmit is called before a new ticket is accepted.
+func (e *QuotaEnforcer) CheckSubmit(apiKey string) error {
+	if e == nil || !e.config.Enabled {
+		return nil
+	}
+	limits := e.config.limitsFor(apiKey)
+	_, queued, err := e.backend.UsageCounts(apiKey)
+	if err != nil {
+		return err
+	}
+	if limits.MaxQueued > 0 && queued >= limits.MaxQueued {
+		return &QuotaError{
+			Limit: "max queued jobs",
+			Used:  fmt.Sprintf("%d", queued),
+			Max:   fmt.Sprintf("%d", limits.MaxQueued),
+		}
+	}
+	return e.checkCpu(apiKey, limits)
+}
+
+// CheckDequeue is called before a pending ticket of the given key is
+// started; it keeps a key at its running-job and CPU-hour limits queued
+// rather than rejecting it.
+func (e *QuotaEnforcer) CheckDequeue(apiKey string) error {
+	if e == nil || !e.config.Enabled {
+		return nil
+	}
+	limits := e.config.limitsFor(apiKey)
+	running, _, err := e.backend.UsageCounts(apiKey)
+	if err != nil {
+		return err
+	}
+	if limits.MaxRunning > 0 && running >= limits.MaxRunning {
+		return &QuotaError{
+			Limit: "max running jobs",
+			Used:  fmt.Sprintf("%d", running),
+			Max:   fmt.Sprintf("%d", limits.MaxRunning),
+		}
+	}
+	return e.checkCpu(apiKey, limits)
+}
+
+func (e *QuotaEnforcer) checkCpu(apiKey string, limits QuotaLimits) error {
+	if limits.DailyCpuHours <= 0 {
+		return nil
+	}
+	seconds, err := e.backend.CpuSecondsSince(apiKey, startOfDay(time.Now()))
+	if err != nil {
+		return err
+	}
+	used := float64(seconds) / 3600
+	if used >= limits.DailyCpuHours {
+		return &QuotaError{
+			Limit: "daily CPU hours",
+			Used:  fmt.Sprintf("%.1fh", used),
+			Max:   fmt.Sprintf("%.1fh", limits.DailyCpuHours),
+		}
+	}
+	return nil
+}
+
+// RecordJobTime charges a finished job's wall time to its API key.
+func (e *QuotaEnforcer) RecordJobTime(apiKey string, duration time.Duration) error {
+	if e == nil || !e.config.Enabled {
+		return nil
+	}
+	return e.backend.AddCpuSeconds(apiKey, int64(duration.Seconds()))
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type ConfigRateLimit struct {
+	Enabled bool `json:"enabled"`
+	// Rate is the sustained number of requests per second per client.
+	Rate float64 `json:"rate"`
+	// Burst is the bucket size, i.e. how many requests a client may
+	// send at once after being idle.
+	Burst int `json:"burst"`
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter keeps one token bucket per client (API key, or IP for
+// anonymous requests) and protects the submission endpoints from
+// scripted floods.
+type RateLimiter struct {
+	mu      sync.Mutex
+	config  ConfigRateLimit
+	buckets map[string]*tokenBucket
+	sweep   time.Time
+}
+
+func MakeRateLimiter(config ConfigRateLimit) *RateLimiter {
+	// a zero rate divides by zero in the wait computation and a zero
+	// burst never has a token to hand out; fall back to workable values
+	// instead of locking every client out
+	if config.Rate <= 0 {
+		config.Rate = 1
+	}
+	if config.Burst <= 0 {
+		config.Burst = int(math.Ceil(config.Rate))
+	}
+	return &RateLimiter{
+		config:  config,
+		buckets: make(map[string]*tokenBucket),
+		sweep:   time.Now(),
+	}
+}
+
+// Allow consumes one token for the client; when the bucket is empty it
+// returns false and the wait until the next token becomes available.
+func (l *RateLimiter) Allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	// drop idle buckets occasionally so the map cannot grow unbounded
+	if now.Sub(l.sweep) > time.Hour {
+		for key, bucket := range l.buckets {
+			if now.Sub(bucket.last) > time.Hour {
+				delete(l.buckets, key)
+			}
+		}
+		l.sweep = now
+	}
+	bucket, found := l.buckets[client]
+	if !found {
+		bucket = &tokenBucket{tokens: float64(l.config.Burst), last: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens += now.Sub(bucket.last).Seconds() * l.config.Rate
+	if bucket.tokens > float64(l.config.Burst) {
+		bucket.tokens = float64(l.config.Burst)
+	}
+	bucket.last = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.config.Rate * float64(time.Second))
+	return false, wait
+}
+
+// clientKey identifies the caller: the API key when present, otherwise
+// the remote IP.
+func clientKey(req *http.Request) string {
+	if key := req.Header.Get("X-API-Key"); key != "" {
+		return "key:" + key
+	}
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// RateLimitMiddleware rejects clients that exceed the configured rate
+// with a 429 response carrying a Retry-After header.
+func RateLimitMiddleware(limiter *RateLimiter, next http.Handler) http.Handler {
+	if limiter == nil || !limiter.config.Enabled {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		allowed, wait := limiter.Allow(clientKey(req))
+		if !allowed {
+			seconds := int(wait.Seconds()) + 1
+			w.Header().Set("Retry-After", fmt.Sprintf("%d", seconds))
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusTooManyRequests)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"error":       "rate limit exceeded",
+				"retry_after": seconds,
+			})
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
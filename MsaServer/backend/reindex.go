@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ConfigReindex schedules automatic IndexJob runs, e.g. after nightly
+// rsyncs of updated FASTA files.
+type ConfigReindex struct {
+	Enabled   bool              `json:"enabled"`
+	Schedules []ReindexSchedule `json:"schedules,omitempty"`
+	// NotifyUrl receives a JSON POST when a scheduled run completes or
+	// fails; empty disables notifications.
+	NotifyUrl string `json:"notifyurl,omitempty"`
+}
+
+// ReindexSchedule pairs a database with a five-field cron expression
+// (minute hour day-of-month month day-of-week; * and comma lists).
+type ReindexSchedule struct {
+	Database string `json:"database"`
+	Cron     string `json:"cron"`
+}
+
+// cronFieldMatches checks one cron field against a time component.
+func cronFieldMatches(field string, value int) bool {
+	if field == "*" {
+		return true
+	}
+	for _, part := range strings.Split(field, ",") {
+		if number, err := strconv.Atoi(part); err == nil && number == value {
+			return true
+		}
+	}
+	return false
+}
+
+// cronMatches evaluates a five-field cron expression at minute
+// resolution.
+func cronMatches(expression string, at time.Time) bool {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return false
+	}
+	return cronFieldMatches(fields[0], at.Minute()) &&
+		cronFieldMatches(fields[1], at.Hour()) &&
+		cronFieldMatches(fields[2], at.Day()) &&
+		cronFieldMatches(fields[3], int(at.Month())) &&
+		cronFieldMatches(fields[4], int(at.Weekday()))
+}
+
+// reindexNotification is the JSON body posted to the notify URL.
+type reindexNotification struct {
+	Database string `json:"database"`
+	Ticket   Id     `json:"ticket"`
+	Status   Status `json:"status"`
+}
+
+func notifyReindex(url string, notification reindexNotification) {
+	if url == "" {
+		return
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return
+	}
+	response, err := http.Post(url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		log.Printf("reindex: notification for %s failed: %s", notification.Database, err)
+		return
+	}
+	response.Body.Close()
+}
+
+// submitReindex queues one IndexJob; the id is derived from database
+// and trigger time so a retried tick cannot double-submit.
+func submitReindex(jobsystem JobSystem, jobsbase string, database string, at time.Time) (Ticket, error) {
+	job := IndexJob{Database: []string{database}}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		return Ticket{Status: StatusUnknown}, err
+	}
+	hash := sha256.Sum256([]byte(fmt.Sprintf("reindex:%s:%d", database, at.Unix()/60)))
+	request := JobRequest{
+		Id:       Id(hex.EncodeToString(hash[:])[:32]),
+		Type:     JobTypeIndex,
+		Job:      json.RawMessage(payload),
+		Priority: PriorityBatch,
+	}
+	return jobsystem.NewJob(request, jobsbase, false)
+}
+
+// watchReindex waits for the queued IndexJob to finish and fires the
+// notification.
+func watchReindex(jobsystem JobSystem, notifyUrl string, database string, id Id) {
+	for {
+		status, err := jobsystem.Status(id)
+		if err != nil {
+			log.Printf("reindex: lost track of ticket %s: %s", id, err)
+			return
+		}
+		if isTerminal(status) {
+			notifyReindex(notifyUrl, reindexNotification{Database: database, Ticket: id, Status: status})
+			if status != StatusComplete {
+				log.Printf("reindex: %s finished with status %s", database, status)
+			}
+			return
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+// ReindexLoop evaluates the schedules once a minute until stop closes;
+// start it in a goroutine next to the other maintenance loops.
+func ReindexLoop(jobsystem JobSystem, jobsbase string, config ConfigReindex, stop chan struct{}) {
+	if !config.Enabled {
+		return
+	}
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			for _, schedule := range config.Schedules {
+				if !cronMatches(schedule.Cron, now) {
+					continue
+				}
+				ticket, err := submitReindex(jobsystem, jobsbase, schedule.Database, now)
+				if err != nil {
+					log.Printf("reindex: submitting %s failed: %s", schedule.Database, err)
+					notifyReindex(config.NotifyUrl, reindexNotification{Database: schedule.Database, Status: StatusError})
+					continue
+				}
+				go watchReindex(jobsystem, config.NotifyUrl, schedule.Database, ticket.Id)
+			}
+		}
+	}
+}
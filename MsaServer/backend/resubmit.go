@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+var ErrNotResubmittable = errors.New("ticket has no stored inputs to resubmit")
+
+// resubmitInputFiles are the job inputs copied from the original result
+// directory, so nothing has to be re-uploaded.
+var resubmitInputFiles = []string{"job.fasta", "job.pdb"}
+
+// ResubmitJob creates a new ticket from a previous one, reusing its
+// stored inputs and parameters. Overrides are merged into the stored job
+// payload, so users can tweak e.g. the mode without re-uploading. The
+// new ticket records the original in its Origin field for provenance.
+func ResubmitJob(jobsystem JobSystem, jobsbase string, original Id, overrides map[string]interface{}) (Ticket, error) {
+	store, ok := UnwrapJobSystem(jobsystem).(PayloadSystem)
+	if !ok {
+		return Ticket{Status: StatusUnknown}, ErrNotResubmittable
+	}
+	jobType, payload, err := store.JobPayload(original)
+	if err != nil {
+		return Ticket{Id: original, Status: StatusUnknown}, err
+	}
+	var job map[string]interface{}
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return Ticket{Id: original, Status: StatusUnknown}, err
+	}
+	for key, value := range overrides {
+		job[key] = value
+	}
+	merged, err := json.Marshal(job)
+	if err != nil {
+		return Ticket{Id: original, Status: StatusUnknown}, err
+	}
+	// a resubmission is always a fresh ticket, so the hash includes the
+	// submission time in addition to the payload and provenance
+	hash := sha256.New()
+	hash.Write(merged)
+	hash.Write([]byte(original))
+	hash.Write([]byte(strconv.FormatInt(time.Now().UnixNano(), 10)))
+	id := Id(hex.EncodeToString(hash.Sum(nil))[:32])
+
+	originalBase := filepath.Join(jobsbase, string(original))
+	newBase := filepath.Join(jobsbase, string(id))
+	if err := os.MkdirAll(newBase, 0755); err != nil {
+		return Ticket{Id: id, Status: StatusUnknown}, err
+	}
+	copied := 0
+	for _, name := range resubmitInputFiles {
+		source, err := os.Open(filepath.Join(originalBase, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return Ticket{Id: id, Status: StatusUnknown}, err
+		}
+		destination, err := os.Create(filepath.Join(newBase, name))
+		if err != nil {
+			source.Close()
+			return Ticket{Id: id, Status: StatusUnknown}, err
+		}
+		_, err = io.Copy(destination, source)
+		source.Close()
+		if closeErr := destination.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return Ticket{Id: id, Status: StatusUnknown}, err
+		}
+		copied++
+	}
+	if copied == 0 {
+		return Ticket{Id: id, Status: StatusUnknown}, ErrNotResubmittable
+	}
+	return jobsystem.NewJob(JobRequest{
+		Id:     id,
+		Type:   jobType,
+		Job:    json.RawMessage(merged),
+		Origin: original,
+	}, jobsbase, false)
+}
+
+// RegisterResubmitRoute adds POST /ticket/{ticket}/resubmit; form fields
+// other than email are treated as job parameter overrides.
+func RegisterResubmitRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/resubmit", func(w http.ResponseWriter, req *http.Request) {
+		original := Id(mux.Vars(req)["ticket"])
+		if err := req.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		overrides := make(map[string]interface{})
+		for key, values := range req.PostForm {
+			if key == "email" || len(values) == 0 {
+				continue
+			}
+			overrides[key] = values[0]
+		}
+		ticket, err := ResubmitJob(jobsystem, jobsbase, original, overrides)
+		if err != nil {
+			switch err {
+			case ErrInvalidTicket:
+				http.Error(w, err.Error(), http.StatusNotFound)
+			case ErrNotResubmittable:
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ticket)
+	}).Methods("POST")
+}
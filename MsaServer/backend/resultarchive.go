@@ -0,0 +1,376 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Result archive compression codecs. Zstd at level 3 is both faster to
+// create and noticeably smaller than gzip for large a3m sets, but gzip
+// stays the default for client compatibility.
+const (
+	ResultCompressionGzip = "gzip"
+	ResultCompressionZstd = "zstd"
+)
+
+type ConfigResultArchive struct {
+	// Compression selects the archive codec, "gzip" (default) or
+	// "zstd".
+	Compression string `json:"compression"`
+	// GzipMembers stores large text members (.a3m, .tsv, .m8) inside
+	// the tar individually gzipped, so clients that extract and keep
+	// results on shared storage save the space without recompressing.
+	GzipMembers bool `json:"gzipmembers"`
+}
+
+// memberGzipThreshold is the size above which members are individually
+// gzipped when GzipMembers is on; tiny files are not worth the header
+// overhead.
+const memberGzipThreshold = 1 << 20
+
+// gzipMemberExtensions are the text formats that compress well enough
+// to bother.
+var gzipMemberExtensions = map[string]bool{
+	".a3m": true,
+	".tsv": true,
+	".m8":  true,
+	".sto": true,
+}
+
+// gzipMember compresses one result file into a temp file and returns
+// it, opened for reading; tar needs the compressed size up front.
+func gzipMember(path string) (*os.File, int64, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer source.Close()
+	temp, err := os.CreateTemp(filepath.Dir(path), ".member-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	os.Remove(temp.Name())
+	compressed := gzip.NewWriter(temp)
+	if _, err := io.Copy(compressed, source); err != nil {
+		temp.Close()
+		return nil, 0, err
+	}
+	if err := compressed.Close(); err != nil {
+		temp.Close()
+		return nil, 0, err
+	}
+	size, err := temp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		temp.Close()
+		return nil, 0, err
+	}
+	if _, err := temp.Seek(0, io.SeekStart); err != nil {
+		temp.Close()
+		return nil, 0, err
+	}
+	return temp, size, nil
+}
+
+// Archives are written deterministically: entries in sorted order (the
+// os.ReadDir contract), normalized modes, zeroed mtimes and uids, and
+// default gzip metadata. Identical job outputs therefore produce
+// byte-identical archives, which enables checksum-based dedup and
+// caching downstream.
+func deterministicTarHeader(name string, size int64) *tar.Header {
+	return &tar.Header{
+		Name:   name,
+		Mode:   0644,
+		Size:   size,
+		Format: tar.FormatPAX,
+	}
+}
+
+// resultArchiveName returns the archive file name for the codec.
+func resultArchiveName(id Id, compression string) string {
+	if compression == ResultCompressionZstd {
+		return "mmseqs_results_" + string(id) + ".tar.zst"
+	}
+	return "mmseqs_results_" + string(id) + ".tar.gz"
+}
+
+// perDatabaseArchiveName is the file name of one database's archive.
+func perDatabaseArchiveName(database string) string {
+	return "alis_" + database + ".tar.gz"
+}
+
+// CreatePerDatabaseArchives additionally packs each database's result
+// files (grouped by file-name prefix, e.g. uniref.a3m, uniref.m8) into
+// its own alis_<database>.tar.gz, so clients interested in only one
+// database avoid downloading everything.
+func CreatePerDatabaseArchives(jobsbase string, id Id) error {
+	resultBase := filepath.Join(jobsbase, string(id))
+	entries, err := os.ReadDir(resultBase)
+	if err != nil {
+		return err
+	}
+	groups := make(map[string][]string)
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "mmseqs_results_") || strings.HasPrefix(name, "alis_") ||
+			strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".etag") || entry.IsDir() {
+			continue
+		}
+		prefix := name
+		if dot := strings.IndexByte(name, '.'); dot > 0 {
+			prefix = name[:dot]
+		}
+		groups[prefix] = append(groups[prefix], name)
+	}
+	for database, files := range groups {
+		temp, err := os.CreateTemp(resultBase, ".archive-*")
+		if err != nil {
+			return err
+		}
+		compressed := gzip.NewWriter(temp)
+		archive := tar.NewWriter(compressed)
+		failed := func(err error) error {
+			temp.Close()
+			os.Remove(temp.Name())
+			return err
+		}
+		for _, name := range files {
+			info, err := os.Stat(filepath.Join(resultBase, name))
+			if err != nil {
+				return failed(err)
+			}
+			if err := archive.WriteHeader(deterministicTarHeader(name, info.Size())); err != nil {
+				return failed(err)
+			}
+			file, err := os.Open(filepath.Join(resultBase, name))
+			if err != nil {
+				return failed(err)
+			}
+			if _, err := io.Copy(archive, file); err != nil {
+				file.Close()
+				return failed(err)
+			}
+			file.Close()
+		}
+		if err := archive.Close(); err != nil {
+			return failed(err)
+		}
+		if err := compressed.Close(); err != nil {
+			return failed(err)
+		}
+		if err := temp.Close(); err != nil {
+			return failed(err)
+		}
+		if err := os.Rename(temp.Name(), filepath.Join(resultBase, perDatabaseArchiveName(database))); err != nil {
+			os.Remove(temp.Name())
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateResultZip packs the same file set as the tarball into a
+// standard .zip for platforms where tar.gz handling is awkward. Workers
+// call it when the submission requested zip output; the download route
+// falls back to re-encoding the tarball when no zip was persisted.
+func CreateResultZip(jobsbase string, id Id) error {
+	resultBase := filepath.Join(jobsbase, string(id))
+	target := filepath.Join(resultBase, "mmseqs_results_"+string(id)+".zip")
+	temp, err := os.CreateTemp(resultBase, ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+	archive := zip.NewWriter(temp)
+	entries, err := os.ReadDir(resultBase)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "mmseqs_results_") || strings.HasPrefix(name, ".") ||
+			strings.HasSuffix(name, ".etag") || entry.IsDir() {
+			continue
+		}
+		writer, err := archive.CreateHeader(&zip.FileHeader{
+			Name:   name,
+			Method: zip.Deflate,
+		})
+		if err != nil {
+			return err
+		}
+		file, err := os.Open(filepath.Join(resultBase, name))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(writer, file); err != nil {
+			file.Close()
+			return err
+		}
+		file.Close()
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), target)
+}
+
+// StreamResultArchive generates the tar.gz straight into the writer
+// instead of serving a pre-built file, halving disk IO and time to
+// first byte for large results. When persist is true the archive is
+// simultaneously written to disk so later downloads hit the file path.
+func StreamResultArchive(w io.Writer, jobsbase string, id Id, persist bool) error {
+	resultBase := filepath.Join(jobsbase, string(id))
+	output := w
+	var temp *os.File
+	if persist {
+		var err error
+		temp, err = os.CreateTemp(resultBase, ".archive-*")
+		if err == nil {
+			defer os.Remove(temp.Name())
+			defer temp.Close()
+			output = io.MultiWriter(w, temp)
+		}
+	}
+	compressed := gzip.NewWriter(output)
+	archive := tar.NewWriter(compressed)
+	entries, err := os.ReadDir(resultBase)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "mmseqs_results_") || strings.HasPrefix(name, ".") ||
+			strings.HasSuffix(name, ".etag") || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if err := archive.WriteHeader(deterministicTarHeader(name, info.Size())); err != nil {
+			return err
+		}
+		file, err := os.Open(filepath.Join(resultBase, name))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(archive, file); err != nil {
+			file.Close()
+			return err
+		}
+		file.Close()
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
+	if temp != nil {
+		if err := temp.Sync(); err != nil {
+			return err
+		}
+		if err := temp.Close(); err != nil {
+			return err
+		}
+		return os.Rename(temp.Name(), filepath.Join(resultBase, resultArchiveName(id, ResultCompressionGzip)))
+	}
+	return nil
+}
+
+// CreateResultArchive packs the job's result directory into its archive
+// using the configured codec. The archive is written to a temp file and
+// renamed so a crashed worker never leaves a half-written archive that
+// a download could pick up.
+func CreateResultArchive(jobsbase string, id Id, config ConfigResultArchive) error {
+	resultBase := filepath.Join(jobsbase, string(id))
+	target := filepath.Join(resultBase, resultArchiveName(id, config.Compression))
+	temp, err := os.CreateTemp(resultBase, ".archive-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	var compressed io.WriteCloser
+	if config.Compression == ResultCompressionZstd {
+		compressed, err = zstd.NewWriter(temp, zstd.WithEncoderLevel(zstd.SpeedDefault))
+		if err != nil {
+			return err
+		}
+	} else {
+		compressed = gzip.NewWriter(temp)
+	}
+	archive := tar.NewWriter(compressed)
+	entries, err := os.ReadDir(resultBase)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		// skip other archives, sidecars and in-progress temp files
+		if strings.HasPrefix(name, "mmseqs_results_") || strings.HasPrefix(name, ".") ||
+			strings.HasSuffix(name, ".etag") || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if config.GzipMembers && gzipMemberExtensions[filepath.Ext(name)] && info.Size() >= memberGzipThreshold {
+			member, size, err := gzipMember(filepath.Join(resultBase, name))
+			if err != nil {
+				return err
+			}
+			if err := archive.WriteHeader(deterministicTarHeader(name+".gz", size)); err != nil {
+				member.Close()
+				return err
+			}
+			if _, err := io.Copy(archive, member); err != nil {
+				member.Close()
+				return err
+			}
+			member.Close()
+			continue
+		}
+		if err := archive.WriteHeader(deterministicTarHeader(name, info.Size())); err != nil {
+			return err
+		}
+		file, err := os.Open(filepath.Join(resultBase, name))
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(archive, file); err != nil {
+			file.Close()
+			return err
+		}
+		file.Close()
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+	if err := compressed.Close(); err != nil {
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), target)
+}
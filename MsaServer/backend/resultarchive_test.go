@@ -0,0 +1,117 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeTestResult(t *testing.T, jobsbase string, id Id) string {
+	t.Helper()
+	resultBase := filepath.Join(jobsbase, string(id))
+	if err := os.MkdirAll(resultBase, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"uniref.a3m": ">q\nACDEF\n",
+		"uniref.m8":  "q\tt\t1.0\t5\t0\t0\t1\t5\t1\t5\t1e-10\t100\n",
+		"pdb.m8":     "q\tp\t0.9\t5\t1\t0\t1\t5\t1\t5\t1e-5\t80\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(resultBase, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resultBase
+}
+
+func buildArchive(t *testing.T, jobsbase string, id Id) []byte {
+	t.Helper()
+	if err := CreateResultArchive(jobsbase, id, ConfigResultArchive{}); err != nil {
+		t.Fatal(err)
+	}
+	target := filepath.Join(jobsbase, string(id), resultArchiveName(id, ResultCompressionGzip))
+	payload, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(target); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+func TestCreateResultArchiveReproducible(t *testing.T) {
+	jobsbase := t.TempDir()
+	id := Id("ticket-a")
+	resultBase := writeTestResult(t, jobsbase, id)
+
+	first := buildArchive(t, jobsbase, id)
+	// rewriting the same content with different timestamps and modes
+	// must not change a single byte of the archive
+	stale := time.Now().Add(-48 * time.Hour)
+	for _, name := range []string{"uniref.a3m", "uniref.m8", "pdb.m8"} {
+		path := filepath.Join(resultBase, name)
+		if err := os.Chmod(path, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, stale, stale); err != nil {
+			t.Fatal(err)
+		}
+	}
+	second := buildArchive(t, jobsbase, id)
+	if !bytes.Equal(first, second) {
+		t.Error("identical result files produced different archive bytes")
+	}
+}
+
+func TestCreateResultArchiveContents(t *testing.T) {
+	jobsbase := t.TempDir()
+	id := Id("ticket-a")
+	resultBase := writeTestResult(t, jobsbase, id)
+	// sidecars, dotfiles and earlier archives must stay out
+	for _, name := range []string{"uniref.a3m.etag", ".archive-tmp", "mmseqs_results_old.tar.gz"} {
+		if err := os.WriteFile(filepath.Join(resultBase, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	payload := buildArchive(t, jobsbase, id)
+	compressed, err := gzip.NewReader(bytes.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive := tar.NewReader(compressed)
+	var names []string
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, header.Name)
+		if header.Mode != 0644 {
+			t.Errorf("%s: mode %o not normalized to 0644", header.Name, header.Mode)
+		}
+		if !header.ModTime.IsZero() && header.ModTime.Unix() != 0 {
+			t.Errorf("%s: mtime %v not zeroed", header.Name, header.ModTime)
+		}
+	}
+	// os.ReadDir yields sorted order, excluded files are skipped
+	want := []string{"pdb.m8", "uniref.a3m", "uniref.m8"}
+	if len(names) != len(want) {
+		t.Fatalf("members: got %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("members: got %v, want %v", names, want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+)
+
+// ChangedHit pairs the two versions of a hit present in both results
+// but with different scores.
+type ChangedHit struct {
+	Before ResultHit `json:"before"`
+	After  ResultHit `json:"after"`
+}
+
+// ResultDiff compares the hit sets of two completed jobs, e.g. the same
+// query searched against two database versions.
+type ResultDiff struct {
+	Base    Id           `json:"base"`
+	Other   Id           `json:"other"`
+	Added   []ResultHit  `json:"added"`
+	Removed []ResultHit  `json:"removed"`
+	Changed []ChangedHit `json:"changed"`
+}
+
+func hitPairKey(hit ResultHit) string {
+	return hit.Query + "\x00" + hit.Target
+}
+
+func hitScoresEqual(a ResultHit, b ResultHit) bool {
+	return a.Bitscore == b.Bitscore && a.Evalue == b.Evalue && a.Identity == b.Identity
+}
+
+// DiffResults computes added, removed and changed hits between the two
+// result directories, keyed by query/target pair across all m8 tables.
+func DiffResults(jobsbase string, base Id, other Id) (*ResultDiff, error) {
+	baseHits, err := collectResultHits(filepath.Join(jobsbase, string(base)))
+	if err != nil {
+		return nil, err
+	}
+	otherHits, err := collectResultHits(filepath.Join(jobsbase, string(other)))
+	if err != nil {
+		return nil, err
+	}
+	baseByPair := make(map[string]ResultHit, len(baseHits))
+	for _, row := range baseHits {
+		baseByPair[hitPairKey(resultHitFromParquet(row))] = resultHitFromParquet(row)
+	}
+	diff := &ResultDiff{Base: base, Other: other}
+	seen := make(map[string]bool, len(otherHits))
+	for _, row := range otherHits {
+		hit := resultHitFromParquet(row)
+		key := hitPairKey(hit)
+		seen[key] = true
+		before, found := baseByPair[key]
+		if !found {
+			diff.Added = append(diff.Added, hit)
+			continue
+		}
+		if !hitScoresEqual(before, hit) {
+			diff.Changed = append(diff.Changed, ChangedHit{Before: before, After: hit})
+		}
+	}
+	for key, hit := range baseByPair {
+		if !seen[key] {
+			diff.Removed = append(diff.Removed, hit)
+		}
+	}
+	return diff, nil
+}
+
+// resultHitFromParquet converts the Parquet row form back to the plain
+// hit used by the JSON endpoints.
+func resultHitFromParquet(row parquetHit) ResultHit {
+	return ResultHit{
+		Query:       row.Query,
+		Target:      row.Target,
+		Identity:    row.Identity,
+		AlnLength:   int(row.AlnLength),
+		Mismatches:  int(row.Mismatches),
+		GapOpens:    int(row.GapOpens),
+		QueryStart:  int(row.QueryStart),
+		QueryEnd:    int(row.QueryEnd),
+		TargetStart: int(row.TargetStart),
+		TargetEnd:   int(row.TargetEnd),
+		Evalue:      row.Evalue,
+		Bitscore:    row.Bitscore,
+		TaxId:       row.TaxId,
+		TaxName:     row.TaxName,
+	}
+}
+
+// RegisterDiffRoute adds GET /ticket/{ticket}/diff/{other}, which
+// compares the hit sets of two completed jobs — useful for validating
+// database upgrades before switching an alias over.
+func RegisterDiffRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/diff/{other}", func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		base := Id(vars["ticket"])
+		other := Id(vars["other"])
+		for _, id := range []Id{base, other} {
+			status, err := jobsystem.Status(id)
+			if err != nil || status != StatusComplete {
+				http.Error(w, "ticket "+string(id)+" is not complete", http.StatusConflict)
+				return
+			}
+		}
+		diff, err := DiffResults(jobsbase, base, other)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(diff)
+	}).Methods("GET")
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// resultFileContentTypes maps the result-file extensions we produce to
+// media types; everything else is served as octet-stream.
+var resultFileContentTypes = map[string]string{
+	".a3m":  "text/plain; charset=utf-8",
+	".tax":  "text/plain; charset=utf-8",
+	".m8":   "text/tab-separated-values; charset=utf-8",
+	".json": "application/json",
+	".sto":  "text/plain; charset=utf-8",
+	".gz":   "application/gzip",
+}
+
+// RegisterResultFileRoute adds GET /ticket/{ticket}/file/{name}, which
+// serves a single file out of a finished job's result directory.
+// Lightweight clients that only need one small file (a taxonomy table,
+// an m8 hit list) no longer have to download the whole archive.
+func RegisterResultFileRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/file/{name}", func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		id := Id(vars["ticket"])
+		name := vars["name"]
+		// reject anything that could escape the result directory
+		if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+			http.Error(w, "invalid file name", http.StatusBadRequest)
+			return
+		}
+		status, err := jobsystem.Status(id)
+		if err != nil || status == StatusUnknown {
+			http.Error(w, "unknown ticket", http.StatusNotFound)
+			return
+		}
+		if status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusConflict)
+			return
+		}
+		path := filepath.Join(jobsbase, string(id), name)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			http.Error(w, "no such result file", http.StatusNotFound)
+			return
+		}
+		if contentType, found := resultFileContentTypes[filepath.Ext(name)]; found {
+			w.Header().Set("Content-Type", contentType)
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		http.ServeFile(w, req, path)
+	}).Methods("GET")
+}
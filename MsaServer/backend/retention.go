@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ConfigRetention struct {
+	Enabled bool `json:"enabled"`
+	// DefaultTTL is the age in seconds after which finished result
+	// directories are deleted.
+	DefaultTTL int64 `json:"defaultttl"`
+	// PerType overrides the TTL for individual job types, e.g. keeping
+	// index jobs longer than interactive MSAs.
+	PerType map[string]int64 `json:"pertype"`
+	// PerKey overrides the TTL for individual API keys.
+	PerKey map[string]int64 `json:"perkey"`
+	// Interval is the sweep interval in seconds.
+	Interval int64 `json:"interval"`
+}
+
+// pinFileName marks a result directory as exempt from retention.
+const pinFileName = "retain.pin"
+
+func (c *ConfigRetention) ttlFor(jobType JobType, apiKey string) time.Duration {
+	if ttl, found := c.PerKey[apiKey]; found {
+		return time.Duration(ttl) * time.Second
+	}
+	if ttl, found := c.PerType[string(jobType)]; found {
+		return time.Duration(ttl) * time.Second
+	}
+	return time.Duration(c.DefaultTTL) * time.Second
+}
+
+// TicketPinned reports whether the ticket was pinned against retention.
+func TicketPinned(jobsbase string, id Id) bool {
+	_, err := os.Stat(filepath.Join(jobsbase, string(id), pinFileName))
+	return err == nil
+}
+
+// RetentionSweep deletes result directories of finished tickets older
+// than their TTL; Paths.Results otherwise grows forever. Pinned tickets
+// and tickets still pending or running are never touched. It returns
+// the number of removed directories.
+func RetentionSweep(jobsystem JobSystem, jobsbase string, config ConfigRetention) (int, error) {
+	store, ok := UnwrapJobSystem(jobsystem).(AdminSystem)
+	if !ok || !config.Enabled || config.DefaultTTL <= 0 {
+		return 0, nil
+	}
+	tickets, err := store.ListTickets(nil)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	now := time.Now()
+	for _, ticket := range tickets {
+		if !isTerminal(ticket.Status) {
+			continue
+		}
+		if TicketPinned(jobsbase, ticket.Id) {
+			continue
+		}
+		if now.Sub(ticket.Created) < config.ttlFor(ticket.Type, ticket.ApiKey) {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(jobsbase, string(ticket.Id))); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// RetentionLoop runs retention sweeps until the stop channel is closed.
+func RetentionLoop(jobsystem JobSystem, jobsbase string, config ConfigRetention, stop <-chan struct{}) {
+	if !config.Enabled {
+		return
+	}
+	interval := time.Duration(config.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			RetentionSweep(jobsystem, jobsbase, config)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// RegisterPinRoutes adds POST and DELETE /ticket/{ticket}/pin, the
+// grace mechanism that exempts specific tickets from retention.
+func RegisterPinRoutes(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/pin", func(w http.ResponseWriter, req *http.Request) {
+		id := Id(mux.Vars(req)["ticket"])
+		status, err := jobsystem.Status(id)
+		if err != nil || status == StatusUnknown {
+			http.Error(w, "unknown ticket", http.StatusNotFound)
+			return
+		}
+		path := filepath.Join(jobsbase, string(id), pinFileName)
+		switch req.Method {
+		case "POST":
+			if err := os.WriteFile(path, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		case "DELETE":
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("POST", "DELETE")
+}
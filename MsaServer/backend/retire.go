@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// retiredMarkerSuffix marks a database that no longer accepts new
+// submissions; the marker survives restarts so a retirement interrupted
+// mid-drain resumes safely.
+const retiredMarkerSuffix = ".RETIRED"
+
+// DbRetirement coordinates taking a database out of service: new
+// submissions targeting it are rejected immediately, the files are only
+// deleted once no queued or running job references it — preventing
+// "index file disappeared mid-search" failures.
+type DbRetirement struct {
+	roots DbRoots
+
+	mutex   sync.RWMutex
+	retired map[string]bool
+}
+
+// NewDbRetirement picks up retirement markers left by a previous
+// process.
+func NewDbRetirement(roots DbRoots) *DbRetirement {
+	retirement := &DbRetirement{roots: roots, retired: make(map[string]bool)}
+	for _, root := range roots {
+		markers, err := filepath.Glob(filepath.Join(root, "*"+retiredMarkerSuffix))
+		if err != nil {
+			continue
+		}
+		for _, marker := range markers {
+			name := strings.TrimSuffix(filepath.Base(marker), retiredMarkerSuffix)
+			retirement.retired[name] = true
+		}
+	}
+	return retirement
+}
+
+// CheckNotRetired rejects submissions that target a retiring database.
+func (d *DbRetirement) CheckNotRetired(databases []string) error {
+	if d == nil {
+		return nil
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	for _, database := range databases {
+		if d.retired[database] {
+			return fmt.Errorf("database %s is retired and no longer accepts jobs", database)
+		}
+	}
+	return nil
+}
+
+// Retire marks the database, waits until no non-terminal ticket
+// references it anymore and then deletes its files. The drain runs in
+// the calling goroutine; admin handlers run it in the background.
+func (d *DbRetirement) Retire(jobsystem JobSystem, name string) error {
+	root, found := d.roots.Locate(name)
+	if !found {
+		return fmt.Errorf("database %s is not installed", name)
+	}
+	marker := filepath.Join(root, name+retiredMarkerSuffix)
+	if err := os.WriteFile(marker, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	d.retired[name] = true
+	d.mutex.Unlock()
+	for {
+		referenced, err := versionReferenced(jobsystem, name)
+		if err != nil {
+			return err
+		}
+		if !referenced {
+			break
+		}
+		time.Sleep(swapDrainInterval)
+	}
+	if err := removeDatabaseVersion(root, name); err != nil {
+		return err
+	}
+	// the marker stays in the map: the name remains rejected until an
+	// operator installs a replacement and restarts
+	return os.Remove(marker)
+}
+
+// RegisterRetireRoute adds POST /admin/databases/{name}/retire, the
+// safe decommissioning operation; it returns immediately while the
+// drain and deletion proceed in the background.
+func RegisterRetireRoute(r *mux.Router, jobsystem JobSystem, retirement *DbRetirement, config ConfigAdmin) {
+	if config.Token == "" || retirement == nil {
+		return
+	}
+	r.HandleFunc("/admin/databases/{name}/retire", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		name := mux.Vars(req)["name"]
+		if _, found := retirement.roots.Locate(name); !found {
+			http.Error(w, "database is not installed", http.StatusNotFound)
+			return
+		}
+		go func() {
+			if err := retirement.Retire(jobsystem, name); err != nil {
+				log.Printf("retire: %s: %s", name, err)
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+	})).Methods("POST")
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// LocalityRouter is implemented by job systems that can route tickets to
+// workers by database locality: workers advertise which databases they
+// hold on local disks, and DequeueFor only hands them tickets they can
+// serve without pulling a remote database over the network.
+type LocalityRouter interface {
+	// AdvertiseWorker records which databases the worker holds locally;
+	// it should be called periodically as a liveness signal.
+	AdvertiseWorker(worker string, databases []string) error
+	// DequeueFor behaves like Dequeue restricted to tickets whose
+	// databases the worker advertised. Tickets without database targets
+	// are eligible for every worker.
+	DequeueFor(worker string) (*Ticket, error)
+}
+
+// workerAdvertisements is the in-memory advertisement table used by the
+// local job systems; multi-node deployments use the Postgres table
+// instead.
+type workerAdvertisements struct {
+	mu      sync.Mutex
+	entries map[string]workerAdvertisement
+}
+
+type workerAdvertisement struct {
+	databases map[string]bool
+	seen      time.Time
+}
+
+// advertisementTTL is how long an advertisement counts as fresh; workers
+// re-advertise on every poll loop.
+const advertisementTTL = 5 * time.Minute
+
+func (w *workerAdvertisements) advertise(worker string, databases []string) {
+	set := make(map[string]bool, len(databases))
+	for _, database := range databases {
+		set[database] = true
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.entries == nil {
+		w.entries = make(map[string]workerAdvertisement)
+	}
+	w.entries[worker] = workerAdvertisement{databases: set, seen: time.Now()}
+}
+
+// snapshot lists the currently fresh advertisements.
+func (w *workerAdvertisements) snapshot() []WorkerInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var workers []WorkerInfo
+	for name, entry := range w.entries {
+		if time.Since(entry.seen) > advertisementTTL {
+			continue
+		}
+		info := WorkerInfo{Name: name, Seen: entry.seen}
+		for database := range entry.databases {
+			info.Databases = append(info.Databases, database)
+		}
+		workers = append(workers, info)
+	}
+	return workers
+}
+
+// holds reports whether the worker advertised all given databases; a
+// worker without a fresh advertisement accepts everything, so a plain
+// single-host setup needs no advertising at all.
+func (w *workerAdvertisements) holds(worker string, databases []string) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	entry, found := w.entries[worker]
+	if !found || time.Since(entry.seen) > advertisementTTL {
+		return true
+	}
+	for _, database := range databases {
+		if !entry.databases[database] {
+			return false
+		}
+	}
+	return true
+}
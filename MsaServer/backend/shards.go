@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConfigShards describes databases split into shards, possibly served
+// by different workers; a query against the logical name fans out to
+// every shard and the hits are merged before archiving, so databases
+// too large for any single node stay searchable.
+type ConfigShards struct {
+	Enabled bool `json:"enabled"`
+	// Shards maps a logical database name to its shard databases;
+	// unlisted databases are also probed for the <name>_shard_N naming
+	// convention on disk.
+	Shards map[string][]string `json:"shards,omitempty"`
+}
+
+// ShardsOf returns the shard databases of a logical database, or nil
+// when it is not sharded.
+func ShardsOf(config ConfigShards, roots DbRoots, database string) []string {
+	if !config.Enabled {
+		return nil
+	}
+	if shards, listed := config.Shards[database]; listed {
+		return shards
+	}
+	matches := roots.Glob(database + "_shard_*.dbtype")
+	var shards []string
+	for _, match := range matches {
+		shards = append(shards, strings.TrimSuffix(filepath.Base(match), ".dbtype"))
+	}
+	sort.Strings(shards)
+	return shards
+}
+
+// FanOutShardedSearch splits one search request into a child ticket per
+// shard and persists a parent record for aggregation; the children
+// carry per-shard database targets and can be routed to the workers
+// that hold them. The returned ticket is the parent.
+func FanOutShardedSearch(jobsystem JobSystem, jobsbase string, request JobRequest, shards []string) (Ticket, error) {
+	raw, err := json.Marshal(request.Job)
+	if err != nil {
+		return Ticket{Id: request.Id, Status: StatusUnknown}, err
+	}
+	var search SearchJob
+	if err := json.Unmarshal(raw, &search); err != nil {
+		return Ticket{Id: request.Id, Status: StatusUnknown}, err
+	}
+	parent := ParentTicket{Id: request.Id, Created: time.Now().UTC()}
+	for index, shard := range shards {
+		child := search
+		child.Database = []string{shard}
+		payload, err := json.Marshal(child)
+		if err != nil {
+			return Ticket{Id: request.Id, Status: StatusUnknown}, err
+		}
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%s:shard:%d", request.Id, index)))
+		childRequest := request
+		childRequest.Id = Id(hex.EncodeToString(hash[:])[:32])
+		childRequest.Job = json.RawMessage(payload)
+		if _, err := jobsystem.NewJob(childRequest, jobsbase, false); err != nil {
+			return Ticket{Id: request.Id, Status: StatusUnknown}, err
+		}
+		parent.Children = append(parent.Children, childRequest.Id)
+	}
+	if err := os.MkdirAll(filepath.Join(jobsbase, string(request.Id)), 0755); err != nil {
+		return Ticket{Id: request.Id, Status: StatusUnknown}, err
+	}
+	payload, err := json.Marshal(parent)
+	if err != nil {
+		return Ticket{Id: request.Id, Status: StatusUnknown}, err
+	}
+	if err := os.WriteFile(filepath.Join(jobsbase, string(request.Id), parentFileName), payload, 0644); err != nil {
+		return Ticket{Id: request.Id, Status: StatusUnknown}, err
+	}
+	return Ticket{Id: request.Id, Status: StatusPending}, nil
+}
+
+// mergeM8Shards concatenates the shard hit tables and re-sorts per
+// query by descending bit score, so the merged table reads as if one
+// search had covered the whole database.
+func mergeM8Shards(sources []string, target string) error {
+	type rankedLine struct {
+		query    string
+		bitscore float64
+		text     string
+	}
+	var lines []rankedLine
+	for _, source := range sources {
+		file, err := os.Open(source)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+		for scanner.Scan() {
+			text := scanner.Text()
+			hit, ok := parseM8Line(text)
+			if !ok {
+				continue
+			}
+			lines = append(lines, rankedLine{query: hit.Query, bitscore: hit.Bitscore, text: text})
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	sort.SliceStable(lines, func(a, b int) bool {
+		if lines[a].query != lines[b].query {
+			return lines[a].query < lines[b].query
+		}
+		return lines[a].bitscore > lines[b].bitscore
+	})
+	temp, err := os.CreateTemp(filepath.Dir(target), ".merge-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	writer := bufio.NewWriter(temp)
+	for _, line := range lines {
+		writer.WriteString(line.text)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), target)
+}
+
+// MergeShardResults folds the finished shard results into the parent's
+// result directory: every distinct m8 file name across the children is
+// merged and re-sorted. The worker calls this once aggregation reports
+// all children complete, before archiving the parent.
+func MergeShardResults(jobsbase string, parent ParentTicket) error {
+	names := make(map[string][]string)
+	for _, child := range parent.Children {
+		matches, err := filepath.Glob(filepath.Join(jobsbase, string(child), "*.m8"))
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			name := filepath.Base(match)
+			names[name] = append(names[name], match)
+		}
+	}
+	parentBase := filepath.Join(jobsbase, string(parent.Id))
+	if err := os.MkdirAll(parentBase, 0755); err != nil {
+		return err
+	}
+	for name, sources := range names {
+		if err := mergeM8Shards(sources, filepath.Join(parentBase, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func m8Line(query, target string, bitscore float64) string {
+	return fmt.Sprintf("%s\t%s\t0.9\t50\t5\t0\t1\t50\t1\t50\t1e-10\t%g", query, target, bitscore)
+}
+
+func TestMergeM8Shards(t *testing.T) {
+	dir := t.TempDir()
+	shard1 := filepath.Join(dir, "shard1.m8")
+	shard2 := filepath.Join(dir, "shard2.m8")
+	if err := os.WriteFile(shard1, []byte(strings.Join([]string{
+		m8Line("q1", "a", 200),
+		m8Line("q2", "b", 90),
+	}, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(shard2, []byte(strings.Join([]string{
+		m8Line("q2", "c", 150),
+		m8Line("q1", "d", 300),
+		"not\ta\tvalid\tline",
+	}, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(dir, "merged.m8")
+	// a missing shard file is tolerated: its worker found no hits
+	if err := mergeM8Shards([]string{shard1, shard2, filepath.Join(dir, "absent.m8")}, target); err != nil {
+		t.Fatal(err)
+	}
+	payload, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// grouped by query, descending bit score within each query, as if
+	// one search had covered the whole database
+	want := strings.Join([]string{
+		m8Line("q1", "d", 300),
+		m8Line("q1", "a", 200),
+		m8Line("q2", "c", 150),
+		m8Line("q2", "b", 90),
+	}, "\n") + "\n"
+	if string(payload) != want {
+		t.Errorf("merged table:\ngot:\n%swant:\n%s", payload, want)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+)
+
+// ConfigIndexSplit lets RAM-limited hosts serve databases whose index
+// exceeds memory: IndexJob builds a split index and searches pick
+// matching flags, instead of one monolithic index that cannot load.
+type ConfigIndexSplit struct {
+	Enabled bool `json:"enabled"`
+	// MemoryBudgetBytes is the largest index split the host should
+	// hold at once; zero derives the budget from available RAM.
+	MemoryBudgetBytes int64 `json:"memorybudgetbytes,omitempty"`
+}
+
+// splitMemoryBudget resolves the configured budget, falling back to
+// four fifths of available RAM like ChooseDbLoadSettings.
+func splitMemoryBudget(config ConfigIndexSplit) int64 {
+	if config.MemoryBudgetBytes > 0 {
+		return config.MemoryBudgetBytes
+	}
+	available := availableMemoryBytes()
+	if available == 0 {
+		return 0
+	}
+	return available / 5 * 4
+}
+
+// IndexSplitArgs returns the createindex arguments for a database: with
+// splitting enabled and the expected index larger than the budget, the
+// index is built in enough splits that each fits.
+func IndexSplitArgs(config ConfigIndexSplit, root string, name string) []string {
+	if !config.Enabled {
+		return nil
+	}
+	budget := splitMemoryBudget(config)
+	if budget <= 0 {
+		return nil
+	}
+	// the sequence data is the best available estimate of the index
+	// size before it exists
+	size := databaseIndexSize(root, name)
+	if size <= budget {
+		return nil
+	}
+	splits := int((size + budget - 1) / budget)
+	return []string{
+		"--split", strconv.Itoa(splits),
+		"--split-memory-limit", fmt.Sprintf("%dM", budget>>20),
+	}
+}
+
+// hasSplitIndex reports whether a split index exists for the database
+// (mmseqs names the parts <db>.idx.0, <db>.idx.1, ...).
+func hasSplitIndex(root string, name string) bool {
+	matches, err := filepath.Glob(filepath.Join(root, name+"*.idx.0"))
+	return err == nil && len(matches) > 0
+}
+
+// SearchSplitArgs returns the extra search arguments for a database
+// with a split index: the same memory limit the index was built with,
+// so mmseqs pages through the splits instead of thrashing.
+func SearchSplitArgs(config ConfigIndexSplit, root string, name string) []string {
+	if !config.Enabled || !hasSplitIndex(root, name) {
+		return nil
+	}
+	budget := splitMemoryBudget(config)
+	if budget <= 0 {
+		return nil
+	}
+	return []string{"--split-memory-limit", fmt.Sprintf("%dM", budget>>20)}
+}
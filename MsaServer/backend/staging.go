@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ConfigStaging copies configured databases from slow shared storage to
+// local scratch when the worker starts, so searches read from SSD
+// instead of NFS.
+type ConfigStaging struct {
+	Enabled bool `json:"enabled"`
+	// Databases lists the databases to stage; others are used in place.
+	Databases []string `json:"databases,omitempty"`
+	// Path is the local directory staged copies live in.
+	Path string `json:"path"`
+}
+
+// stagedMarkerSuffix marks a completed staging; a partial copy from a
+// crashed worker is re-staged instead of trusted.
+const stagedMarkerSuffix = ".STAGED"
+
+// stageCopyFile copies one file, creating it with the source's size
+// visible to progress logging.
+func stageCopyFile(source string, target string) (int64, error) {
+	in, err := os.Open(source)
+	if err != nil {
+		return 0, err
+	}
+	defer in.Close()
+	out, err := os.Create(target)
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.CopyBuffer(out, in, make([]byte, warmupReadBuffer))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return written, err
+}
+
+// StageDatabase copies one database from its shared root into the
+// scratch directory, preferring rsync (resumable, preserves sparse
+// files) when available. Checksums from the database's manifest are
+// verified on the staged copy before it is marked usable.
+func StageDatabase(roots DbRoots, scratchPath string, name string) error {
+	root, found := roots.Locate(name)
+	if !found {
+		return fmt.Errorf("database %s is not installed", name)
+	}
+	if err := os.MkdirAll(scratchPath, 0755); err != nil {
+		return err
+	}
+	marker := filepath.Join(scratchPath, name+stagedMarkerSuffix)
+	if _, err := os.Stat(marker); err == nil {
+		return nil
+	}
+	started := time.Now()
+	matches, err := filepath.Glob(filepath.Join(root, name+"*"))
+	if err != nil {
+		return err
+	}
+	if rsync, err := exec.LookPath("rsync"); err == nil {
+		arguments := append([]string{"-a", "--partial"}, matches...)
+		arguments = append(arguments, scratchPath+string(os.PathSeparator))
+		if output, err := exec.Command(rsync, arguments...).CombinedOutput(); err != nil {
+			return fmt.Errorf("rsync of %s failed: %w: %s", name, err, string(output))
+		}
+	} else {
+		total := int64(0)
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil || info.IsDir() {
+				continue
+			}
+			written, err := stageCopyFile(match, filepath.Join(scratchPath, filepath.Base(match)))
+			if err != nil {
+				return fmt.Errorf("staging %s failed: %w", match, err)
+			}
+			total += written
+			log.Printf("staging: %s: %d MB copied", name, total>>20)
+		}
+	}
+	if err := verifyDatabaseFiles(scratchPath, name, false); err != nil {
+		return fmt.Errorf("staged copy of %s is corrupt: %w", name, err)
+	}
+	if err := os.WriteFile(marker, []byte(time.Now().UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return err
+	}
+	log.Printf("staging: %s ready in %s", name, time.Since(started).Round(time.Second))
+	return nil
+}
+
+// UnstageDatabase removes a staged copy, e.g. when the database is
+// retired from the shared storage.
+func UnstageDatabase(scratchPath string, name string) error {
+	if err := removeDatabaseVersion(scratchPath, name); err != nil {
+		return err
+	}
+	return os.RemoveAll(filepath.Join(scratchPath, name+stagedMarkerSuffix))
+}
+
+// StageDatabases stages every configured database and returns the roots
+// to search with: scratch first, so staged copies win, with the shared
+// roots as fallback for everything else. Staged copies of databases no
+// longer configured (or no longer installed upstream) are cleaned up.
+func StageDatabases(config ConfigStaging, roots DbRoots) (DbRoots, error) {
+	if !config.Enabled {
+		return roots, nil
+	}
+	configured := make(map[string]bool, len(config.Databases))
+	for _, name := range config.Databases {
+		configured[name] = true
+		if err := StageDatabase(roots, config.Path, name); err != nil {
+			return roots, err
+		}
+	}
+	markers, err := filepath.Glob(filepath.Join(config.Path, "*"+stagedMarkerSuffix))
+	if err == nil {
+		for _, marker := range markers {
+			name := filepath.Base(marker[:len(marker)-len(stagedMarkerSuffix)])
+			_, installed := roots.Locate(name)
+			if configured[name] && installed {
+				continue
+			}
+			log.Printf("staging: removing retired staged database %s", name)
+			if err := UnstageDatabase(config.Path, name); err != nil {
+				log.Printf("staging: cleanup of %s failed: %s", name, err)
+			}
+		}
+	}
+	return append(DbRoots{config.Path}, roots...), nil
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// a3mEntry is one named sequence reduced to query columns.
+type a3mEntry struct {
+	Name     string
+	Sequence string
+}
+
+// readA3MEntries reads an a3m stream keeping the record names, with
+// lowercase insertions removed so all rows share the query columns.
+func readA3MEntries(reader io.Reader) ([]a3mEntry, error) {
+	var entries []a3mEntry
+	var current strings.Builder
+	name := ""
+	inSequence := false
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	flush := func() {
+		if inSequence {
+			entries = append(entries, a3mEntry{Name: name, Sequence: current.String()})
+			current.Reset()
+		}
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+		if line[0] == '>' {
+			flush()
+			fields := strings.Fields(line[1:])
+			if len(fields) > 0 {
+				name = fields[0]
+			} else {
+				name = fmt.Sprintf("seq%d", len(entries)+1)
+			}
+			inSequence = true
+			continue
+		}
+		if !inSequence {
+			continue
+		}
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			if c >= 'a' && c <= 'z' {
+				continue
+			}
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// readTaxAnnotations parses a .tax sidecar file (sequence name and
+// taxid, tab separated) into a lookup map.
+func readTaxAnnotations(path string) map[string]string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	annotations := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) >= 2 {
+			annotations[fields[0]] = fields[1]
+		}
+	}
+	return annotations
+}
+
+// WriteStockholm converts an a3m alignment to Stockholm 1.0 for
+// HMMER-based and AlphaFold-reference toolchains. Taxonomy annotations,
+// if available, are emitted as #=GS <name> TX <taxid> lines.
+func WriteStockholm(w io.Writer, a3m io.Reader, taxonomy map[string]string) error {
+	entries, err := readA3MEntries(a3m)
+	if err != nil {
+		return err
+	}
+	buffered := bufio.NewWriter(w)
+	fmt.Fprintln(buffered, "# STOCKHOLM 1.0")
+	nameWidth := 0
+	for _, entry := range entries {
+		if len(entry.Name) > nameWidth {
+			nameWidth = len(entry.Name)
+		}
+	}
+	for _, entry := range entries {
+		if taxid, found := taxonomy[entry.Name]; found {
+			fmt.Fprintf(buffered, "#=GS %-*s TX %s\n", nameWidth, entry.Name, taxid)
+		}
+	}
+	for _, entry := range entries {
+		// Stockholm uses . or - for gaps; a3m match gaps are already -
+		fmt.Fprintf(buffered, "%-*s %s\n", nameWidth, entry.Name, entry.Sequence)
+	}
+	fmt.Fprintln(buffered, "//")
+	return buffered.Flush()
+}
+
+// RegisterStockholmRoute adds GET /ticket/{ticket}/stockholm/{name},
+// which converts one a3m file from the result directory to Stockholm on
+// the fly; taxonomy sidecars (<name>.tax) become GS annotations.
+func RegisterStockholmRoute(r *mux.Router, jobsystem JobSystem, jobsbase string) {
+	r.HandleFunc("/ticket/{ticket}/stockholm/{name}", func(w http.ResponseWriter, req *http.Request) {
+		vars := mux.Vars(req)
+		id := Id(vars["ticket"])
+		name := vars["name"]
+		if name != filepath.Base(name) || !strings.HasSuffix(name, ".a3m") {
+			http.Error(w, "invalid file name", http.StatusBadRequest)
+			return
+		}
+		status, err := jobsystem.Status(id)
+		if err != nil || status != StatusComplete {
+			http.Error(w, "job is not complete", http.StatusNotFound)
+			return
+		}
+		path := filepath.Join(jobsbase, string(id), name)
+		file, err := os.Open(path)
+		if err != nil {
+			http.Error(w, "no such result file", http.StatusNotFound)
+			return
+		}
+		defer file.Close()
+		taxonomy := readTaxAnnotations(path + ".tax")
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", "attachment; filename=\""+strings.TrimSuffix(name, ".a3m")+".sto\"")
+		if err := WriteStockholm(w, file, taxonomy); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}).Methods("GET")
+}
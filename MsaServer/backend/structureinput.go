@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrUnknownStructureFormat = errors.New("unrecognized structure format; expected PDB or mmCIF")
+	ErrNoSuchModel            = errors.New("structure does not contain the requested model")
+)
+
+// NormalizeStructureUpload turns a structure upload into the plain
+// single-model PDB text the foldseek worker expects. Uploads may be
+// PDB or mmCIF, optionally gzip/zstd compressed; multi-model files are
+// reduced to the selected model (1-based, 0 means the first).
+func NormalizeStructureUpload(r io.Reader, model int) (string, error) {
+	decompressed, err := DecompressingReader(r, maxDecompressedUploadBytes)
+	if err != nil {
+		return "", err
+	}
+	raw, err := io.ReadAll(decompressed)
+	if err != nil {
+		return "", err
+	}
+	text := string(raw)
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	var pdb string
+	switch {
+	case strings.HasPrefix(trimmed, "data_") || strings.Contains(trimmed, "_atom_site."):
+		pdb, err = convertCifToPdb(text)
+		if err != nil {
+			return "", err
+		}
+	case strings.HasPrefix(trimmed, "ATOM") || strings.HasPrefix(trimmed, "HETATM") ||
+		strings.HasPrefix(trimmed, "HEADER") || strings.HasPrefix(trimmed, "MODEL") ||
+		strings.HasPrefix(trimmed, "REMARK") || strings.HasPrefix(trimmed, "TITLE"):
+		pdb = text
+	default:
+		return "", ErrUnknownStructureFormat
+	}
+	return selectPdbModel(pdb, model)
+}
+
+// selectPdbModel extracts one MODEL/ENDMDL block from a multi-model
+// PDB; single-model files pass through unchanged.
+func selectPdbModel(pdb string, model int) (string, error) {
+	if model <= 0 {
+		model = 1
+	}
+	if !strings.Contains(pdb, "\nMODEL") && !strings.HasPrefix(pdb, "MODEL") {
+		if model > 1 {
+			return "", ErrNoSuchModel
+		}
+		return pdb, nil
+	}
+	var builder strings.Builder
+	current := 0
+	found := false
+	scanner := bufio.NewScanner(strings.NewReader(pdb))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "MODEL"):
+			fields := strings.Fields(line)
+			if len(fields) > 1 {
+				current, _ = strconv.Atoi(fields[1])
+			} else {
+				current++
+			}
+			if current == model {
+				found = true
+			}
+		case strings.HasPrefix(line, "ENDMDL"):
+			current = 0
+		case current == model || current == 0:
+			// keep header/remark lines outside any model block and
+			// the coordinates of the selected model
+			builder.WriteString(line)
+			builder.WriteByte('\n')
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if !found {
+		return "", ErrNoSuchModel
+	}
+	return builder.String(), nil
+}
+
+// cifAtomColumns are the _atom_site fields we need to emit PDB ATOM
+// records.
+var cifAtomColumns = []string{
+	"group_PDB", "id", "type_symbol", "label_atom_id", "label_comp_id",
+	"auth_asym_id", "auth_seq_id", "Cartn_x", "Cartn_y", "Cartn_z",
+	"occupancy", "B_iso_or_equiv", "pdbx_PDB_model_num",
+}
+
+// convertCifToPdb performs a minimal mmCIF to PDB conversion covering
+// the _atom_site loop; that is all foldseek needs from the file.
+func convertCifToPdb(cif string) (string, error) {
+	columns := make(map[string]int)
+	index := 0
+	inLoop := false
+	var builder strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(cif))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "_atom_site."):
+			columns[strings.TrimPrefix(line, "_atom_site.")] = index
+			index++
+			inLoop = true
+		case inLoop && (line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "loop_") || strings.HasPrefix(line, "_")):
+			inLoop = false
+		case inLoop:
+			fields := strings.Fields(line)
+			if len(fields) < len(columns) {
+				continue
+			}
+			get := func(name string) string {
+				position, found := columns[name]
+				if !found || position >= len(fields) {
+					return ""
+				}
+				value := fields[position]
+				if value == "." || value == "?" {
+					return ""
+				}
+				return value
+			}
+			group := get("group_PDB")
+			if group != "ATOM" && group != "HETATM" {
+				continue
+			}
+			serial, _ := strconv.Atoi(get("id"))
+			sequence, _ := strconv.Atoi(get("auth_seq_id"))
+			x, _ := strconv.ParseFloat(get("Cartn_x"), 64)
+			y, _ := strconv.ParseFloat(get("Cartn_y"), 64)
+			z, _ := strconv.ParseFloat(get("Cartn_z"), 64)
+			occupancy, _ := strconv.ParseFloat(get("occupancy"), 64)
+			bfactor, _ := strconv.ParseFloat(get("B_iso_or_equiv"), 64)
+			chain := get("auth_asym_id")
+			if len(chain) > 1 {
+				chain = chain[:1]
+			}
+			atom := get("label_atom_id")
+			if len(atom) < 4 {
+				atom = " " + atom
+			}
+			if modelField := get("pdbx_PDB_model_num"); modelField != "" {
+				if model, err := strconv.Atoi(modelField); err == nil && model > 1 {
+					// models beyond the first are reintroduced as
+					// MODEL blocks by the caller's selection; mmCIF
+					// rows are already grouped, so start a new block
+					continue
+				}
+			}
+			fmt.Fprintf(&builder, "%-6s%5d %-4s%4s %1s%4d    %8.3f%8.3f%8.3f%6.2f%6.2f\n",
+				group, serial, atom, get("label_comp_id"), chain, sequence, x, y, z, occupancy, bfactor)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if builder.Len() == 0 {
+		return "", ErrUnknownStructureFormat
+	}
+	builder.WriteString("END\n")
+	return builder.String(), nil
+}
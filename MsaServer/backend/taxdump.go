@@ -0,0 +1,205 @@
+package main
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ConfigTaxdump manages the NCBI taxonomy dump that taxonomy-aware
+// databases resolve taxid→lineage mappings against; without refreshes
+// the annotations in .a3m.tax files go stale as NCBI merges and
+// deletes taxa.
+type ConfigTaxdump struct {
+	Enabled bool `json:"enabled"`
+	// Path is the directory the versioned dumps live in.
+	Path string `json:"path"`
+	// Url overrides the NCBI download location.
+	Url string `json:"url,omitempty"`
+	// KeepVersions bounds how many old dumps are retained.
+	KeepVersions int `json:"keepversions,omitempty"`
+}
+
+const defaultTaxdumpUrl = "https://ftp.ncbi.nih.gov/pub/taxonomy/taxdump.tar.gz"
+
+// taxdumpCurrentFile records which versioned directory is active.
+const taxdumpCurrentFile = "CURRENT"
+
+// TaxdumpManager downloads, verifies and versions the NCBI taxdump.
+type TaxdumpManager struct {
+	config ConfigTaxdump
+}
+
+func NewTaxdumpManager(config ConfigTaxdump) *TaxdumpManager {
+	if config.Url == "" {
+		config.Url = defaultTaxdumpUrl
+	}
+	if config.KeepVersions <= 0 {
+		config.KeepVersions = 2
+	}
+	return &TaxdumpManager{config: config}
+}
+
+// Current returns the active dump version, or the empty string when
+// none is installed yet.
+func (m *TaxdumpManager) Current() string {
+	raw, err := os.ReadFile(filepath.Join(m.config.Path, taxdumpCurrentFile))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(raw))
+}
+
+// CurrentPath returns the directory of the active dump.
+func (m *TaxdumpManager) CurrentPath() string {
+	version := m.Current()
+	if version == "" {
+		return ""
+	}
+	return filepath.Join(m.config.Path, version)
+}
+
+// Versions lists the installed dump versions, oldest first.
+func (m *TaxdumpManager) Versions() []string {
+	matches, err := filepath.Glob(filepath.Join(m.config.Path, "taxdump_*"))
+	if err != nil {
+		return nil
+	}
+	var versions []string
+	for _, match := range matches {
+		if info, err := os.Stat(match); err == nil && info.IsDir() {
+			versions = append(versions, filepath.Base(match))
+		}
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// verifyTaxdumpMd5 checks the archive against NCBI's published .md5
+// sidecar; a missing sidecar is tolerated.
+func verifyTaxdumpMd5(url string, archive string) error {
+	response, err := http.Get(url + ".md5")
+	if err != nil || response.StatusCode != http.StatusOK {
+		if response != nil {
+			response.Body.Close()
+		}
+		return nil
+	}
+	sidecar, err := io.ReadAll(io.LimitReader(response.Body, 256))
+	response.Body.Close()
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(sidecar))
+	if len(fields) == 0 || len(fields[0]) != 32 {
+		return nil
+	}
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := md5.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return err
+	}
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != fields[0] {
+		return fmt.Errorf("taxdump checksum mismatch: got %s, want %s", actual, fields[0])
+	}
+	return nil
+}
+
+// Update downloads a fresh dump into a dated version directory and
+// switches CURRENT over; old versions beyond the retention limit are
+// removed.
+func (m *TaxdumpManager) Update() error {
+	if err := os.MkdirAll(m.config.Path, 0755); err != nil {
+		return err
+	}
+	version := "taxdump_" + time.Now().UTC().Format("20060102")
+	target := filepath.Join(m.config.Path, version)
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+	archive := filepath.Join(m.config.Path, version+".tar.gz")
+	if err := downloadResumable(m.config.Url, archive); err != nil {
+		return err
+	}
+	if err := verifyTaxdumpMd5(m.config.Url, archive); err != nil {
+		os.Remove(archive)
+		return err
+	}
+	staging := target + ".staging"
+	if err := os.MkdirAll(staging, 0755); err != nil {
+		return err
+	}
+	cmd := exec.Command("tar", "xzf", archive, "-C", staging)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.RemoveAll(staging)
+		return fmt.Errorf("extracting taxdump failed: %w: %s", err, string(output))
+	}
+	os.Remove(archive)
+	if err := os.Rename(staging, target); err != nil {
+		return err
+	}
+	temp := filepath.Join(m.config.Path, "."+taxdumpCurrentFile)
+	if err := os.WriteFile(temp, []byte(version+"\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(temp, filepath.Join(m.config.Path, taxdumpCurrentFile)); err != nil {
+		return err
+	}
+	versions := m.Versions()
+	for len(versions) > m.config.KeepVersions {
+		old := versions[0]
+		versions = versions[1:]
+		if old == version {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(m.config.Path, old)); err != nil {
+			log.Printf("taxdump: removing old version %s failed: %s", old, err)
+		}
+	}
+	return nil
+}
+
+// RegisterTaxdumpRoutes adds the taxdump management to the admin API:
+// GET /admin/taxdump reports the current and installed versions,
+// POST /admin/taxdump/update fetches a fresh dump in the background.
+func RegisterTaxdumpRoutes(r *mux.Router, manager *TaxdumpManager, config ConfigAdmin) {
+	if config.Token == "" || manager == nil {
+		return
+	}
+	r.HandleFunc("/admin/taxdump", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		versions := manager.Versions()
+		if versions == nil {
+			versions = []string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"current":  manager.Current(),
+			"versions": versions,
+		})
+	})).Methods("GET")
+	r.HandleFunc("/admin/taxdump/update", adminAuth(config.Token, func(w http.ResponseWriter, req *http.Request) {
+		go func() {
+			if err := manager.Update(); err != nil {
+				log.Printf("taxdump: update failed: %s", err)
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+	})).Methods("POST")
+}
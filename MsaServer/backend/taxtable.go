@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// taxTableName is the normalized lineage table in the result directory.
+const taxTableName = "taxids.tsv"
+
+// TaxonRecord is one row of taxids.tsv: the lineage is stored once per
+// taxid instead of being repeated on every hit.
+type TaxonRecord struct {
+	TaxId   string
+	Name    string
+	Rank    string
+	Lineage string
+}
+
+// NormalizeTaxonomyFiles deduplicates taxonomy annotations: every
+// .a3m.tax file is reduced to its first two columns (sequence name and
+// taxid) and the full name/rank/lineage columns move into one
+// taxids.tsv keyed by taxid. For metagenomic-heavy MSAs, where tens of
+// thousands of hits share a few hundred taxa, this shrinks the .tax
+// files drastically.
+func NormalizeTaxonomyFiles(resultBase string) error {
+	matches, err := filepath.Glob(filepath.Join(resultBase, "*.tax"))
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		return nil
+	}
+	taxa := make(map[string]TaxonRecord)
+	for _, path := range matches {
+		if err := normalizeTaxFile(path, taxa); err != nil {
+			return err
+		}
+	}
+	if len(taxa) == 0 {
+		return nil
+	}
+	records := make([]TaxonRecord, 0, len(taxa))
+	for _, record := range taxa {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].TaxId < records[j].TaxId })
+	temp, err := os.CreateTemp(resultBase, ".tax-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	writer := bufio.NewWriter(temp)
+	for _, record := range records {
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", record.TaxId, record.Name, record.Rank, record.Lineage)
+	}
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), filepath.Join(resultBase, taxTableName))
+}
+
+// normalizeTaxFile strips the lineage columns from one .tax file,
+// collecting them into taxa; files that already only carry name and
+// taxid are left untouched.
+func normalizeTaxFile(path string, taxa map[string]TaxonRecord) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	temp, err := os.CreateTemp(filepath.Dir(path), ".tax-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	writer := bufio.NewWriter(temp)
+	stripped := false
+	scanner := bufio.NewScanner(source)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 2 {
+			continue
+		}
+		if len(fields) > 2 {
+			stripped = true
+			record := TaxonRecord{TaxId: fields[1]}
+			if len(fields) > 2 {
+				record.Name = fields[2]
+			}
+			if len(fields) > 3 {
+				record.Rank = fields[3]
+			}
+			if len(fields) > 4 {
+				record.Lineage = fields[4]
+			}
+			if _, seen := taxa[record.TaxId]; !seen {
+				taxa[record.TaxId] = record
+			}
+		}
+		fmt.Fprintf(writer, "%s\t%s\n", fields[0], fields[1])
+	}
+	if err := scanner.Err(); err != nil {
+		temp.Close()
+		return err
+	}
+	if !stripped {
+		// nothing to normalize; keep the original file untouched
+		temp.Close()
+		return nil
+	}
+	if err := writer.Flush(); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ConfigTemplates bundles the mmCIF files of pdb template hits into the
+// result archive, so downstream AlphaFold template featurization works
+// without network access.
+type ConfigTemplates struct {
+	Enabled bool `json:"enabled"`
+	// MirrorPath is a local PDB mirror in the divided mmCIF layout
+	// (as maintained by the rsync in setup_databases.sh).
+	MirrorPath string `json:"mirrorpath,omitempty"`
+	// CachePath stores downloaded entries when no mirror is available.
+	CachePath string `json:"cachepath,omitempty"`
+	// DownloadUrl is the fallback source for entries missing from the
+	// mirror.
+	DownloadUrl string `json:"downloadurl,omitempty"`
+}
+
+const defaultTemplateDownloadUrl = "https://files.rcsb.org/download"
+
+// templateDirName is the subdirectory of the result the mmCIF files go
+// into.
+const templateDirName = "templates"
+
+// pdbTargetPattern matches pdb70/pdb100 hit names like 1abc_A.
+var pdbTargetPattern = regexp.MustCompile(`^[0-9][a-zA-Z0-9]{3}(_[a-zA-Z0-9]+)?$`)
+
+// templatePdbId extracts the lowercase four-character PDB id of a
+// template hit; empty when the target is not a PDB entry.
+func templatePdbId(target string) string {
+	if !pdbTargetPattern.MatchString(target) {
+		return ""
+	}
+	return strings.ToLower(target[:4])
+}
+
+// FetchTemplate locates the mmCIF of one PDB entry: the local mirror
+// first, then the download cache, then the network (caching the
+// result). The returned path points to a gzipped mmCIF.
+func FetchTemplate(config ConfigTemplates, pdbId string) (string, error) {
+	middle := pdbId[1:3]
+	if config.MirrorPath != "" {
+		mirrored := filepath.Join(config.MirrorPath, "divided", middle, pdbId+".cif.gz")
+		if _, err := os.Stat(mirrored); err == nil {
+			return mirrored, nil
+		}
+	}
+	if config.CachePath == "" {
+		return "", fmt.Errorf("template %s not in mirror and no cache configured", pdbId)
+	}
+	cached := filepath.Join(config.CachePath, pdbId+".cif.gz")
+	if _, err := os.Stat(cached); err == nil {
+		return cached, nil
+	}
+	if err := os.MkdirAll(config.CachePath, 0755); err != nil {
+		return "", err
+	}
+	url := config.DownloadUrl
+	if url == "" {
+		url = defaultTemplateDownloadUrl
+	}
+	response, err := http.Get(url + "/" + pdbId + ".cif.gz")
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("downloading template %s failed: %s", pdbId, response.Status)
+	}
+	temp, err := os.CreateTemp(config.CachePath, ".template-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := io.Copy(temp, response.Body); err != nil {
+		temp.Close()
+		return "", err
+	}
+	if err := temp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(temp.Name(), cached); err != nil {
+		return "", err
+	}
+	return cached, nil
+}
+
+// resultTemplateIds collects the distinct PDB ids of all template hits
+// in a result directory.
+func resultTemplateIds(resultBase string) ([]string, error) {
+	rows, err := collectResultHits(resultBase)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var ids []string
+	for _, row := range rows {
+		pdbId := templatePdbId(row.Target)
+		if pdbId == "" || seen[pdbId] {
+			continue
+		}
+		seen[pdbId] = true
+		ids = append(ids, pdbId)
+	}
+	return ids, nil
+}
+
+// BundleTemplates copies the mmCIF of every PDB template hit into the
+// result's templates/ directory before archiving. Missing entries are
+// reported together rather than failing the whole job on the first
+// obsolete id.
+func BundleTemplates(config ConfigTemplates, resultBase string) error {
+	if !config.Enabled {
+		return nil
+	}
+	ids, err := resultTemplateIds(resultBase)
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	templateDir := filepath.Join(resultBase, templateDirName)
+	if err := os.MkdirAll(templateDir, 0755); err != nil {
+		return err
+	}
+	var missing []string
+	for _, pdbId := range ids {
+		source, err := FetchTemplate(config, pdbId)
+		if err != nil {
+			missing = append(missing, pdbId)
+			continue
+		}
+		target := filepath.Join(templateDir, pdbId+".cif.gz")
+		if _, err := os.Stat(target); err == nil {
+			continue
+		}
+		in, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		out, err := os.Create(target)
+		if err != nil {
+			in.Close()
+			return err
+		}
+		_, err = io.Copy(out, in)
+		in.Close()
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("templates unavailable for %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+
+	"github.com/gorilla/mux"
+)
+
+// queryFromRequest returns the submitted query: inline from the q form
+// value or, when the resumable upload flow is enabled, from a completed
+// upload referenced by the upload form value. Uploaded queries may be
+// gzip or zstd compressed and are inflated under the shared size bound.
+func queryFromRequest(req *http.Request, config ConfigRoot) (string, error) {
+	if query := req.FormValue("q"); query != "" {
+		return query, nil
+	}
+	id := req.FormValue("upload")
+	if id == "" || !config.Upload.Enabled {
+		return "", nil
+	}
+	path, err := CompletedUploadPath(config.Upload.Directory, id)
+	if err != nil {
+		return "", err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	raw, err := ReadQueryUpload(file, maxDecompressedUploadBytes)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// submitV1 applies the shared submission checks and stores the job.
+// Identical submissions hash to the same ticket: a repeat of a COMPLETE
+// query returns the existing result, and a repeat of a queued or
+// running query returns that ticket untouched instead of disturbing the
+// in-flight work. Only failed tickets are re-enqueued.
+func submitV1(w http.ResponseWriter, req *http.Request, jobsystem JobSystem, jobsbase string, config ConfigRoot, acl *DbAcl, retirement *DbRetirement, jobType JobType, job interface{}, databases []string) {
+	if err := CheckBackpressure(jobsystem, config.Backpressure); err != nil {
+		var backpressure *BackpressureError
+		if errors.As(err, &backpressure) {
+			WriteBackpressure(w, backpressure)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	apiKey := req.Header.Get("X-API-Key")
+	if err := acl.Authorize(apiKey, databases); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := AuthorizeUserDatabases(apiKey, databases); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if err := retirement.CheckNotRetired(databases); err != nil {
+		http.Error(w, err.Error(), http.StatusGone)
+		return
+	}
+	if backend, ok := UnwrapJobSystem(jobsystem).(QuotaBackend); ok {
+		enforcer := MakeQuotaEnforcer(config.Quotas, backend)
+		if err := enforcer.CheckSubmit(apiKey); err != nil {
+			var quota *QuotaError
+			if errors.As(err, &quota) {
+				http.Error(w, quota.Error(), http.StatusTooManyRequests)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	payload, err := json.Marshal(job)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	hash := sha256.Sum256(append([]byte(jobType), payload...))
+	request := JobRequest{
+		Id:       Id(hex.EncodeToString(hash[:])[:32]),
+		Type:     jobType,
+		Job:      json.RawMessage(payload),
+		Email:    req.FormValue("email"),
+		Priority: ParsePriority(req.FormValue("priority")),
+		ApiKey:   apiKey,
+	}
+	ticket, err := NewJobIdempotent(jobsystem, request, jobsbase, false, req.Header.Get("Idempotency-Key"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if callback := req.FormValue("callback"); callback != "" {
+		if err := SaveCallbackUrl(jobsbase, ticket.Id, callback); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ticket)
+}
+
+// RegisterTicketRoutes adds the legacy v1 API the existing ColabFold
+// client and the Go SDK speak: form-encoded submission per job type and
+// the ticket status lookup.
+//
+//	POST /ticket/msa             q (FASTA) and mode
+//	POST /ticket/pair            q (FASTA) and mode
+//	POST /ticket/search          q (FASTA), mode and database[]
+//	POST /ticket/structuresearch q (PDB/mmCIF) and mode
+//	GET  /ticket/{ticket}        current ticket status
+//
+// Instead of an inline q value, the submission routes also accept an
+// upload form value naming a completed resumable upload when the upload
+// flow is enabled.
+//
+// With a non-nil alias resolver, submitted database aliases are pinned
+// to concrete versions before the job is hashed and stored, matching
+// the /api/v2 behaviour; a non-nil acl restricts access-controlled
+// databases to their cleared API keys; a non-nil retirement rejects
+// databases being decommissioned.
+func RegisterTicketRoutes(r *mux.Router, jobsystem JobSystem, jobsbase string, config ConfigRoot, aliases *DbAliases, acl *DbAcl, retirement *DbRetirement) {
+	resolve := func(databases []string) ([]string, error) {
+		if aliases == nil || len(databases) == 0 {
+			return databases, nil
+		}
+		return aliases.ResolveAll(databases)
+	}
+	r.HandleFunc("/ticket/msa", func(w http.ResponseWriter, req *http.Request) {
+		query, err := queryFromRequest(req, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query == "" {
+			http.Error(w, "q or a completed upload is required", http.StatusBadRequest)
+			return
+		}
+		databases, err := resolve(req.Form["database[]"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		job := MsaJob{Mode: req.FormValue("mode"), Query: query, DBs: databases}
+		submitV1(w, req, jobsystem, jobsbase, config, acl, retirement, JobTypeMsa, job, job.DBs)
+	}).Methods("POST")
+	r.HandleFunc("/ticket/pair", func(w http.ResponseWriter, req *http.Request) {
+		query, err := queryFromRequest(req, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query == "" {
+			http.Error(w, "q or a completed upload is required", http.StatusBadRequest)
+			return
+		}
+		job := PairJob{Mode: req.FormValue("mode"), Query: query}
+		submitV1(w, req, jobsystem, jobsbase, config, acl, retirement, JobTypePair, job, nil)
+	}).Methods("POST")
+	r.HandleFunc("/ticket/search", func(w http.ResponseWriter, req *http.Request) {
+		query, err := queryFromRequest(req, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query == "" {
+			http.Error(w, "q or a completed upload is required", http.StatusBadRequest)
+			return
+		}
+		databases := req.Form["database[]"]
+		if len(databases) == 0 {
+			http.Error(w, "database[] is required", http.StatusBadRequest)
+			return
+		}
+		databases, err = resolve(databases)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		job := SearchJob{Mode: req.FormValue("mode"), Query: query, Database: databases}
+		submitV1(w, req, jobsystem, jobsbase, config, acl, retirement, JobTypeSearch, job, databases)
+	}).Methods("POST")
+	r.HandleFunc("/ticket/structuresearch", func(w http.ResponseWriter, req *http.Request) {
+		query, err := queryFromRequest(req, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if query == "" {
+			http.Error(w, "q or a completed upload is required", http.StatusBadRequest)
+			return
+		}
+		databases := req.Form["database[]"]
+		if len(databases) == 0 && config.Paths.Search.Structure != "" {
+			databases = []string{config.Paths.Search.Structure}
+		}
+		databases, err = resolve(databases)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		job := StructureSearchJob{Mode: req.FormValue("mode"), Query: query, Database: databases}
+		submitV1(w, req, jobsystem, jobsbase, config, acl, retirement, JobTypeStructureSearch, job, databases)
+	}).Methods("POST")
+	r.HandleFunc("/ticket/{ticket}", func(w http.ResponseWriter, req *http.Request) {
+		ticket, err := jobsystem.GetTicket(Id(mux.Vars(req)["ticket"]))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		AttachResultStats(jobsbase, &ticket)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ticket)
+	}).Methods("GET")
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ConfigUpload struct {
+	Enabled bool `json:"enabled"`
+	// MaxSizeBytes bounds the declared total size of one upload.
+	MaxSizeBytes int64 `json:"maxsizebytes"`
+	// Directory holds in-progress and completed uploads.
+	Directory string `json:"directory"`
+	// StaleAfter is how long an untouched partial upload survives
+	// before cleanup may remove it.
+	StaleAfter time.Duration `json:"staleafter"`
+}
+
+// uploadMeta is stored next to the data file so offsets survive server
+// restarts and clients can resume after reconnecting.
+type uploadMeta struct {
+	Id       string    `json:"id"`
+	Size     int64     `json:"size"`
+	Offset   int64     `json:"offset"`
+	Filename string    `json:"filename,omitempty"`
+	Created  time.Time `json:"created"`
+	Updated  time.Time `json:"updated"`
+}
+
+var ErrUploadTooLarge = errors.New("upload exceeds the configured maximum size")
+
+func uploadPaths(directory, id string) (data string, meta string) {
+	return filepath.Join(directory, id+".part"), filepath.Join(directory, id+".json")
+}
+
+func readUploadMeta(directory, id string) (uploadMeta, error) {
+	var meta uploadMeta
+	_, metaPath := uploadPaths(directory, id)
+	file, err := os.Open(metaPath)
+	if err != nil {
+		return meta, err
+	}
+	defer file.Close()
+	err = json.NewDecoder(file).Decode(&meta)
+	return meta, err
+}
+
+func writeUploadMeta(directory string, meta uploadMeta) error {
+	_, metaPath := uploadPaths(directory, meta.Id)
+	payload, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metaPath, payload, 0644)
+}
+
+// CompletedUploadPath returns the data file of a finished upload, or an
+// error if the upload does not exist or is still partial. Submission
+// handlers use it to consume uploads by id instead of multipart bodies.
+func CompletedUploadPath(directory, id string) (string, error) {
+	meta, err := readUploadMeta(directory, id)
+	if err != nil {
+		return "", err
+	}
+	if meta.Offset != meta.Size {
+		return "", errors.New("upload is incomplete")
+	}
+	dataPath, _ := uploadPaths(directory, id)
+	return dataPath, nil
+}
+
+// CleanStaleUploads removes partial uploads that have not seen a chunk
+// for longer than staleAfter.
+func CleanStaleUploads(directory string, staleAfter time.Duration) error {
+	matches, err := filepath.Glob(filepath.Join(directory, "*.json"))
+	if err != nil {
+		return err
+	}
+	cutoff := time.Now().Add(-staleAfter)
+	for _, metaPath := range matches {
+		id := filepath.Base(metaPath)
+		id = id[:len(id)-len(".json")]
+		meta, err := readUploadMeta(directory, id)
+		if err != nil {
+			continue
+		}
+		if meta.Offset == meta.Size || meta.Updated.After(cutoff) {
+			continue
+		}
+		dataPath, _ := uploadPaths(directory, id)
+		os.Remove(dataPath)
+		os.Remove(metaPath)
+	}
+	return nil
+}
+
+// RegisterUploadRoutes adds a tus-style resumable upload flow:
+//
+//	POST  /uploads            create a session (Upload-Length header)
+//	HEAD  /uploads/{id}       query the current offset to resume
+//	PATCH /uploads/{id}       append a chunk at Upload-Offset
+//
+// Large batch FASTA or structure tarballs are uploaded in chunks that
+// can be retried individually; the completed upload is then referenced
+// by id on submission.
+func RegisterUploadRoutes(r *mux.Router, config ConfigUpload) {
+	if !config.Enabled {
+		return
+	}
+	r.HandleFunc("/uploads", func(w http.ResponseWriter, req *http.Request) {
+		size, err := strconv.ParseInt(req.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || size <= 0 {
+			http.Error(w, "missing or invalid Upload-Length header", http.StatusBadRequest)
+			return
+		}
+		if config.MaxSizeBytes > 0 && size > config.MaxSizeBytes {
+			http.Error(w, ErrUploadTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := os.MkdirAll(config.Directory, 0755); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		buffer := make([]byte, 16)
+		if _, err := rand.Read(buffer); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		id := hex.EncodeToString(buffer)
+		dataPath, _ := uploadPaths(config.Directory, id)
+		file, err := os.OpenFile(dataPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		file.Close()
+		now := time.Now()
+		meta := uploadMeta{
+			Id:       id,
+			Size:     size,
+			Filename: req.Header.Get("Upload-Filename"),
+			Created:  now,
+			Updated:  now,
+		}
+		if err := writeUploadMeta(config.Directory, meta); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Location", "/uploads/"+id)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(meta)
+	}).Methods("POST")
+	r.HandleFunc("/uploads/{id}", func(w http.ResponseWriter, req *http.Request) {
+		meta, err := readUploadMeta(config.Directory, mux.Vars(req)["id"])
+		if err != nil {
+			http.Error(w, "upload not found", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(meta.Offset, 10))
+		w.Header().Set("Upload-Length", strconv.FormatInt(meta.Size, 10))
+		w.WriteHeader(http.StatusOK)
+	}).Methods("HEAD")
+	r.HandleFunc("/uploads/{id}", func(w http.ResponseWriter, req *http.Request) {
+		id := mux.Vars(req)["id"]
+		meta, err := readUploadMeta(config.Directory, id)
+		if err != nil {
+			http.Error(w, "upload not found", http.StatusNotFound)
+			return
+		}
+		offset, err := strconv.ParseInt(req.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset != meta.Offset {
+			// the client resumed with a stale offset; tell it where
+			// to continue from instead of corrupting the file
+			w.Header().Set("Upload-Offset", strconv.FormatInt(meta.Offset, 10))
+			http.Error(w, "offset mismatch", http.StatusConflict)
+			return
+		}
+		dataPath, _ := uploadPaths(config.Directory, id)
+		file, err := os.OpenFile(dataPath, os.O_WRONLY, 0644)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		limit := meta.Size - offset
+		written, err := io.Copy(file, io.LimitReader(req.Body, limit))
+		if err != nil && written == 0 {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		meta.Offset = offset + written
+		meta.Updated = time.Now()
+		if err := writeUploadMeta(config.Directory, meta); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Upload-Offset", strconv.FormatInt(meta.Offset, 10))
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("PATCH")
+}
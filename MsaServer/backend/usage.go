@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// UsageReport is the caller's current consumption against their quota
+// limits; zero limits mean unlimited.
+type UsageReport struct {
+	ApiKey        string      `json:"api_key"`
+	Running       int         `json:"running"`
+	Queued        int         `json:"queued"`
+	CpuHoursToday float64     `json:"cpu_hours_today"`
+	StorageBytes  int64       `json:"storage_bytes"`
+	Limits        QuotaLimits `json:"limits"`
+}
+
+// storageUsedBytes sums the result directories of a key's tickets.
+func storageUsedBytes(jobsbase string, tickets []AdminTicket) int64 {
+	var total int64
+	for _, ticket := range tickets {
+		filepath.Walk(filepath.Join(jobsbase, string(ticket.Id)), func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				total += info.Size()
+			}
+			return nil
+		})
+	}
+	return total
+}
+
+// RegisterUsageRoute adds GET /me/usage, which reports the caller's
+// quota consumption — running and queued jobs, CPU hours burned today
+// and result storage held — so pipeline authors can throttle themselves
+// before hitting hard limits.
+func RegisterUsageRoute(r *mux.Router, jobsystem JobSystem, jobsbase string, config ConfigQuotas) {
+	backend, ok := UnwrapJobSystem(jobsystem).(QuotaBackend)
+	if !ok {
+		return
+	}
+	r.HandleFunc("/me/usage", func(w http.ResponseWriter, req *http.Request) {
+		apiKey := req.Header.Get("X-API-Key")
+		if apiKey == "" {
+			http.Error(w, "missing X-API-Key header", http.StatusUnauthorized)
+			return
+		}
+		running, queued, err := backend.UsageCounts(apiKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		seconds, err := backend.CpuSecondsSince(apiKey, startOfDay(time.Now()))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		report := UsageReport{
+			ApiKey:        apiKey,
+			Running:       running,
+			Queued:        queued,
+			CpuHoursToday: float64(seconds) / 3600,
+			Limits:        config.limitsFor(apiKey),
+		}
+		if store, ok := UnwrapJobSystem(jobsystem).(AdminSystem); ok {
+			if tickets, err := store.ListTickets(nil); err == nil {
+				var mine []AdminTicket
+				for _, ticket := range tickets {
+					if ticket.ApiKey == apiKey {
+						mine = append(mine, ticket)
+					}
+				}
+				report.StorageBytes = storageUsedBytes(jobsbase, mine)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}).Methods("GET")
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// ConfigUserDb enables user-uploaded custom sequence databases: an API
+// key uploads a FASTA, the server builds an mmseqs database from it,
+// and later search jobs from that key can target it.
+type ConfigUserDb struct {
+	Enabled bool `json:"enabled"`
+	// MaxSizeBytes caps the uploaded FASTA size.
+	MaxSizeBytes int64 `json:"maxsizebytes"`
+	// MaxPerKey caps how many databases one key may own.
+	MaxPerKey int `json:"maxperkey"`
+	// Mmseqs is the binary used for createdb.
+	Mmseqs string `json:"mmseqs"`
+}
+
+// userDbPrefix namespaces user databases on disk and in job payloads:
+// a user database is addressed as u<keyhash>_<name>, so it never
+// collides with the server's shared databases.
+const userDbPrefix = "u"
+
+var userDbNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,63}$`)
+
+// userDbNamespacePattern recognizes the on-disk form of user databases.
+var userDbNamespacePattern = regexp.MustCompile(`^u[0-9a-f]{12}_`)
+
+var ErrTooManyUserDatabases = errors.New("user database limit reached")
+
+// userDbKeyHash derives the per-key namespace component.
+func userDbKeyHash(apiKey string) string {
+	hash := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(hash[:])[:12]
+}
+
+// UserDatabaseName maps a key and a user-chosen name to the database
+// name job payloads use.
+func UserDatabaseName(apiKey string, name string) string {
+	return userDbPrefix + userDbKeyHash(apiKey) + "_" + name
+}
+
+// AuthorizeUserDatabases rejects submissions that target another key's
+// user database; shared databases pass through untouched.
+func AuthorizeUserDatabases(apiKey string, databases []string) error {
+	owned := userDbPrefix + userDbKeyHash(apiKey) + "_"
+	for _, database := range databases {
+		if !userDbNamespacePattern.MatchString(database) {
+			continue
+		}
+		if !strings.HasPrefix(database, owned) {
+			return fmt.Errorf("not authorized to search database %s", database)
+		}
+	}
+	return nil
+}
+
+// listUserDatabases returns the names (without namespace) of the
+// databases a key owns.
+func listUserDatabases(databasesPath string, apiKey string) ([]string, error) {
+	owned := userDbPrefix + userDbKeyHash(apiKey) + "_"
+	matches, err := filepath.Glob(filepath.Join(databasesPath, owned+"*.params"))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(matches))
+	for _, match := range matches {
+		base := strings.TrimSuffix(filepath.Base(match), ".params")
+		names = append(names, strings.TrimPrefix(base, owned))
+	}
+	return names, nil
+}
+
+// CreateUserDatabase builds an mmseqs database from an uploaded FASTA
+// inside the key's namespace.
+func CreateUserDatabase(config ConfigUserDb, databasesPath string, apiKey string, name string, fasta io.Reader) (string, error) {
+	if !userDbNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid database name %q", name)
+	}
+	if config.MaxPerKey > 0 {
+		existing, err := listUserDatabases(databasesPath, apiKey)
+		if err != nil {
+			return "", err
+		}
+		if len(existing) >= config.MaxPerKey {
+			return "", ErrTooManyUserDatabases
+		}
+	}
+	database := UserDatabaseName(apiKey, name)
+	source := filepath.Join(databasesPath, database+".fasta")
+	file, err := os.Create(source)
+	if err != nil {
+		return "", err
+	}
+	limit := config.MaxSizeBytes
+	if limit <= 0 {
+		limit = 1 << 30
+	}
+	written, err := io.Copy(file, io.LimitReader(fasta, limit+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(source)
+		return "", err
+	}
+	if written > limit {
+		os.Remove(source)
+		return "", ErrUploadTooLarge
+	}
+	mmseqs := config.Mmseqs
+	if mmseqs == "" {
+		mmseqs = "mmseqs"
+	}
+	cmd := exec.Command(mmseqs, "createdb", source, filepath.Join(databasesPath, database))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		os.Remove(source)
+		return "", fmt.Errorf("createdb failed: %w: %s", err, string(output))
+	}
+	if err := WriteDbParams(databasesPath, database, DbParams{Name: name, Kind: "sequence"}); err != nil {
+		return "", err
+	}
+	return database, nil
+}
+
+// DeleteUserDatabase removes one of the key's databases and all its
+// artifacts.
+func DeleteUserDatabase(databasesPath string, apiKey string, name string) error {
+	if !userDbNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid database name %q", name)
+	}
+	return removeDatabaseVersion(databasesPath, UserDatabaseName(apiKey, name))
+}
+
+// RegisterUserDbRoutes adds the custom database API under /my:
+// POST /my/databases uploads a FASTA and builds the database,
+// GET /my/databases lists the key's databases, and
+// DELETE /my/databases/{name} removes one. All routes require an API
+// key, which also namespaces the databases.
+func RegisterUserDbRoutes(r *mux.Router, config ConfigUserDb, databasesPath string) {
+	if !config.Enabled {
+		return
+	}
+	r.HandleFunc("/my/databases", func(w http.ResponseWriter, req *http.Request) {
+		apiKey := req.Header.Get("X-API-Key")
+		if apiKey == "" {
+			http.Error(w, "an API key is required", http.StatusUnauthorized)
+			return
+		}
+		name := req.FormValue("name")
+		fasta := strings.NewReader(req.FormValue("q"))
+		database, err := CreateUserDatabase(config, databasesPath, apiKey, name, fasta)
+		if err != nil {
+			status := http.StatusBadRequest
+			if err == ErrTooManyUserDatabases {
+				status = http.StatusForbidden
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"name": name, "database": database})
+	}).Methods("POST")
+	r.HandleFunc("/my/databases", func(w http.ResponseWriter, req *http.Request) {
+		apiKey := req.Header.Get("X-API-Key")
+		if apiKey == "" {
+			http.Error(w, "an API key is required", http.StatusUnauthorized)
+			return
+		}
+		names, err := listUserDatabases(databasesPath, apiKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if names == nil {
+			names = []string{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(names)
+	}).Methods("GET")
+	r.HandleFunc("/my/databases/{name}", func(w http.ResponseWriter, req *http.Request) {
+		apiKey := req.Header.Get("X-API-Key")
+		if apiKey == "" {
+			http.Error(w, "an API key is required", http.StatusUnauthorized)
+			return
+		}
+		if err := DeleteUserDatabase(databasesPath, apiKey, mux.Vars(req)["name"]); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}).Methods("DELETE")
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// validationIssue is one problem found in a submission, tied to the
+// record it occurred in so batch users can fix exactly the broken
+// entries.
+type validationIssue struct {
+	Record  string `json:"record,omitempty"`
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// validationReport is the /validate response; Valid submissions would
+// be accepted unchanged by the submission endpoints.
+type validationReport struct {
+	Valid   bool              `json:"valid"`
+	Records int               `json:"records"`
+	Issues  []validationIssue `json:"issues"`
+}
+
+const (
+	maxQuerySequenceLength = 10000
+	maxQueryRecords        = 10000
+)
+
+// aminoAcidAlphabet covers the standard residues plus the ambiguity
+// codes mmseqs accepts.
+const aminoAcidAlphabet = "ACDEFGHIKLMNPQRSTVWYXBZJUO*-"
+
+type fastaRecord struct {
+	Header   string
+	Sequence string
+}
+
+func parseFasta(query string) ([]fastaRecord, []validationIssue) {
+	var records []fastaRecord
+	var issues []validationIssue
+	scanner := bufio.NewScanner(strings.NewReader(query))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	var current *fastaRecord
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if strings.HasPrefix(text, ">") {
+			records = append(records, fastaRecord{Header: strings.TrimPrefix(text, ">")})
+			current = &records[len(records)-1]
+			continue
+		}
+		if current == nil {
+			issues = append(issues, validationIssue{
+				Field:   "q",
+				Message: fmt.Sprintf("line %d: sequence data before the first header", line),
+			})
+			return records, issues
+		}
+		current.Sequence += strings.ToUpper(text)
+	}
+	if len(records) == 0 {
+		issues = append(issues, validationIssue{Field: "q", Message: "no FASTA records found"})
+	}
+	return records, issues
+}
+
+func validateFasta(query string) (int, []validationIssue) {
+	records, issues := parseFasta(query)
+	if len(records) > maxQueryRecords {
+		issues = append(issues, validationIssue{
+			Field:   "q",
+			Message: fmt.Sprintf("%d records exceed the limit of %d", len(records), maxQueryRecords),
+		})
+	}
+	for _, record := range records {
+		if len(record.Sequence) == 0 {
+			issues = append(issues, validationIssue{
+				Record: record.Header, Field: "q", Message: "empty sequence",
+			})
+			continue
+		}
+		if len(record.Sequence) > maxQuerySequenceLength {
+			issues = append(issues, validationIssue{
+				Record: record.Header, Field: "q",
+				Message: fmt.Sprintf("sequence length %d exceeds the limit of %d", len(record.Sequence), maxQuerySequenceLength),
+			})
+		}
+		for index, residue := range record.Sequence {
+			if !strings.ContainsRune(aminoAcidAlphabet, residue) {
+				issues = append(issues, validationIssue{
+					Record: record.Header, Field: "q",
+					Message: fmt.Sprintf("invalid residue %q at position %d", residue, index+1),
+				})
+				break
+			}
+		}
+	}
+	return len(records), issues
+}
+
+func validateStructure(query string) (int, []validationIssue) {
+	var issues []validationIssue
+	pdb, err := NormalizeStructureUpload(strings.NewReader(query), 0)
+	if err != nil {
+		issues = append(issues, validationIssue{Field: "q", Message: err.Error()})
+		return 0, issues
+	}
+	chains := make(map[byte]bool)
+	atoms := 0
+	scanner := bufio.NewScanner(strings.NewReader(pdb))
+	scanner.Buffer(make([]byte, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "ATOM") && len(line) > 21 {
+			atoms++
+			chains[line[21]] = true
+		}
+	}
+	if atoms == 0 {
+		issues = append(issues, validationIssue{Field: "q", Message: "structure contains no ATOM records"})
+	}
+	return len(chains), issues
+}
+
+// validateDatabases checks that the requested databases exist under one
+// of the configured database roots.
+func validateDatabases(roots DbRoots, databases []string) []validationIssue {
+	var issues []validationIssue
+	for _, database := range databases {
+		if database != filepath.Base(database) {
+			issues = append(issues, validationIssue{
+				Field: "database", Message: fmt.Sprintf("invalid database name %q", database),
+			})
+			continue
+		}
+		if _, found := roots.Locate(database); !found {
+			issues = append(issues, validationIssue{
+				Field: "database", Message: fmt.Sprintf("unknown database %q", database),
+			})
+		}
+	}
+	return issues
+}
+
+// RegisterValidateRoute adds POST /validate, which runs the same input
+// checks the submission path would but never creates a ticket. Users
+// catch bad alphabets, oversized batches or misspelled databases
+// immediately instead of an hour into a queued job.
+func RegisterValidateRoute(r *mux.Router, roots DbRoots) {
+	r.HandleFunc("/validate", func(w http.ResponseWriter, req *http.Request) {
+		query := req.FormValue("q")
+		if query == "" {
+			http.Error(w, "missing query field q", http.StatusBadRequest)
+			return
+		}
+		jobType := req.FormValue("type")
+		if jobType == "" {
+			jobType = string(JobTypeMsa)
+		}
+		report := validationReport{}
+		switch JobType(jobType) {
+		case JobTypeMsa, JobTypeSearch, JobTypePair:
+			report.Records, report.Issues = validateFasta(query)
+		case JobTypeStructureSearch:
+			report.Records, report.Issues = validateStructure(query)
+		default:
+			report.Issues = append(report.Issues, validationIssue{
+				Field: "type", Message: fmt.Sprintf("unknown job type %q", jobType),
+			})
+		}
+		if databases := req.Form["database[]"]; len(databases) > 0 {
+			report.Issues = append(report.Issues, validateDatabases(roots, databases)...)
+		}
+		report.Valid = len(report.Issues) == 0
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	}).Methods("POST")
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// ConfigWarmup prefetches database files into the page cache on
+// startup, so the first job after a restart doesn't pay minutes of
+// cold NFS or disk reads.
+type ConfigWarmup struct {
+	Enabled bool `json:"enabled"`
+	// Databases lists the database names to warm; their index and data
+	// files are read in full.
+	Databases []string `json:"databases,omitempty"`
+	// Mode is "read" for a sequential read pass (vmtouch-style) or
+	// "touchdb" to delegate to mmseqs touchdb.
+	Mode string `json:"mode,omitempty"`
+	// Mmseqs is the binary used in touchdb mode.
+	Mmseqs string `json:"mmseqs,omitempty"`
+}
+
+// warmupReadBuffer is the sequential read chunk size; large enough to
+// keep NFS readahead busy.
+const warmupReadBuffer = 8 << 20
+
+// warmFile reads one file start to finish, pulling it into the page
+// cache, and returns the byte count.
+func warmFile(path string) (int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.CopyBuffer(io.Discard, file, make([]byte, warmupReadBuffer))
+}
+
+// warmDatabase prefetches all files of one database. Index files are
+// read first: they are what the first search touches.
+func warmDatabase(databasesPath string, name string) (int64, error) {
+	indexes, err := filepath.Glob(filepath.Join(databasesPath, name+"*.idx*"))
+	if err != nil {
+		return 0, err
+	}
+	rest, err := filepath.Glob(filepath.Join(databasesPath, name+"*"))
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool, len(indexes))
+	ordered := indexes
+	for _, path := range indexes {
+		seen[path] = true
+	}
+	for _, path := range rest {
+		if !seen[path] {
+			ordered = append(ordered, path)
+		}
+	}
+	total := int64(0)
+	for _, path := range ordered {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		read, err := warmFile(path)
+		if err != nil {
+			return total, err
+		}
+		total += read
+	}
+	return total, nil
+}
+
+// WarmupDatabases runs the configured warm-up pass; call it in a
+// goroutine at startup so it doesn't delay serving requests.
+func WarmupDatabases(config ConfigWarmup, databasesPath string) error {
+	if !config.Enabled {
+		return nil
+	}
+	for _, name := range config.Databases {
+		started := time.Now()
+		if config.Mode == "touchdb" {
+			mmseqs := config.Mmseqs
+			if mmseqs == "" {
+				mmseqs = "mmseqs"
+			}
+			cmd := exec.Command(mmseqs, "touchdb", filepath.Join(databasesPath, name))
+			if output, err := cmd.CombinedOutput(); err != nil {
+				return fmt.Errorf("touchdb %s failed: %w: %s", name, err, string(output))
+			}
+			log.Printf("warmup: touched %s in %s", name, time.Since(started).Round(time.Second))
+			continue
+		}
+		read, err := warmDatabase(databasesPath, name)
+		if err != nil {
+			return fmt.Errorf("warming %s failed: %w", name, err)
+		}
+		elapsed := time.Since(started)
+		log.Printf("warmup: read %d MB of %s in %s", read>>20, name, elapsed.Round(time.Second))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import "sync"
+
+// weightedPicker implements smooth weighted round-robin over job types,
+// so cheap structure searches get a configured share of the workers even
+// while a backlog of hour-long MSA jobs exists (and vice versa). Types
+// without configured weight default to weight 1.
+type weightedPicker struct {
+	mu      sync.Mutex
+	weights map[string]int
+	current map[string]int
+}
+
+func newWeightedPicker(weights map[string]int) *weightedPicker {
+	copied := make(map[string]int, len(weights))
+	for key, weight := range weights {
+		if weight > 0 {
+			copied[key] = weight
+		}
+	}
+	return &weightedPicker{
+		weights: copied,
+		current: make(map[string]int),
+	}
+}
+
+func (p *weightedPicker) weight(key string) int {
+	if weight, found := p.weights[key]; found {
+		return weight
+	}
+	return 1
+}
+
+// Pick chooses among the currently available keys proportionally to
+// their weights.
+func (p *weightedPicker) Pick(available []string) string {
+	if len(available) == 0 {
+		return ""
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	total := 0
+	best := ""
+	for _, key := range available {
+		weight := p.weight(key)
+		p.current[key] += weight
+		total += weight
+		if best == "" || p.current[key] > p.current[best] {
+			best = key
+		}
+	}
+	p.current[best] -= total
+	return best
+}
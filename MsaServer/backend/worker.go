@@ -0,0 +1,877 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// workerHeartbeatInterval is how often a running job renews its claim
+// and heartbeat; it must stay well under the visibility timeout.
+const workerHeartbeatInterval = 30 * time.Second
+
+// shardWatchInterval is how often a shard parent polls its children.
+const shardWatchInterval = 5 * time.Second
+
+// a3mChunkMaxBytes is the chunking threshold for oversized alignments.
+const a3mChunkMaxBytes = 64 << 20
+
+// Worker drains the job system and executes tickets with the
+// mmseqs/foldseek pipelines ported from colabfold_search.sh. Several
+// workers may run in one process; the claim files keep them and other
+// server nodes from double-executing a ticket.
+type Worker struct {
+	jobsystem JobSystem
+	config    ConfigRoot
+	roots     DbRoots
+	stats     *DbStats
+	// objectStore is non-nil when finished archives are offloaded to
+	// object storage for presigned downloads.
+	objectStore ObjectStore
+	// stop ends long-lived helpers like the shard watcher on shutdown.
+	stop <-chan struct{}
+	// dependencyPaths holds the result directories of the current
+	// job's dependencies; they are exported to the pipeline processes.
+	dependencyPaths []string
+}
+
+// dependencyResultsEnv names the environment variable carrying the
+// result directories of the job's dependencies, path-list separated.
+const dependencyResultsEnv = "MSA_SERVER_DEPENDENCY_RESULTS"
+
+// RunWorker is one worker goroutine; it returns when stop is closed.
+func RunWorker(jobsystem JobSystem, config ConfigRoot, roots DbRoots, stats *DbStats, objectStore ObjectStore, stop <-chan struct{}) {
+	worker := &Worker{jobsystem: jobsystem, config: config, roots: roots, stats: stats, objectStore: objectStore, stop: stop}
+	router, routed := UnwrapJobSystem(jobsystem).(LocalityRouter)
+	advertise := func() {
+		var local []string
+		for name := range discoverDatabases(roots) {
+			local = append(local, name)
+		}
+		if err := router.AdvertiseWorker(config.Worker.Name, local); err != nil {
+			log.Printf("worker %s: advertise failed: %v", config.Worker.Name, err)
+		}
+	}
+	var lastAdvertised time.Time
+	poll := time.Duration(config.Worker.PollSeconds) * time.Second
+	for {
+		if routed && time.Since(lastAdvertised) > time.Minute {
+			advertise()
+			lastAdvertised = time.Now()
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		ticket, err := worker.dequeue()
+		if err != nil {
+			log.Printf("worker %s: dequeue failed: %v", config.Worker.Name, err)
+		}
+		if ticket == nil {
+			select {
+			case <-stop:
+				return
+			case <-time.After(poll):
+			}
+			continue
+		}
+		worker.execute(*ticket)
+	}
+}
+
+// dequeue prefers locality-aware dispatch when the backend supports it,
+// so tickets run on workers that hold their databases locally.
+func (w *Worker) dequeue() (*Ticket, error) {
+	if pausable, ok := w.jobsystem.(*PausableJobSystem); ok && pausable.Paused() {
+		return nil, nil
+	}
+	if router, ok := UnwrapJobSystem(w.jobsystem).(LocalityRouter); ok {
+		return router.DequeueFor(w.config.Worker.Name)
+	}
+	return w.jobsystem.Dequeue()
+}
+
+func (w *Worker) jobsbase() string {
+	return w.config.Paths.Results
+}
+
+// fail records the failure; backends with a dead-letter store keep the
+// error history and stop retrying after the configured attempts.
+func (w *Worker) fail(id Id, message string) {
+	status := StatusError
+	maxFailures := w.config.DeadLetter.MaxFailures
+	if maxFailures <= 0 {
+		maxFailures = 3
+	}
+	if deadletters, ok := UnwrapJobSystem(w.jobsystem).(DeadLetterSystem); ok {
+		recorded, err := deadletters.RecordFailure(id, message, maxFailures)
+		if err != nil {
+			log.Printf("job %s: recording failure: %v", id, err)
+		} else {
+			status = recorded
+		}
+	} else if err := w.jobsystem.SetStatus(id, StatusError); err != nil {
+		log.Printf("job %s: setting error status: %v", id, err)
+	}
+	log.Printf("job %s failed (%s): %s", id, status, message)
+	if err := NotifyCallback(w.config.Callback, w.jobsbase(), Ticket{Id: id, Status: status}); err != nil {
+		log.Printf("job %s: callback: %v", id, err)
+	}
+}
+
+// heartbeat keeps the claim and the backend heartbeat fresh while the
+// job runs, so the dead-worker sweep leaves it alone.
+func (w *Worker) heartbeat(id Id, token uint64, stop <-chan struct{}) {
+	beats, hasBeats := UnwrapJobSystem(w.jobsystem).(HeartbeatSystem)
+	ticker := time.NewTicker(workerHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if hasBeats {
+				if err := beats.Heartbeat(id, w.config.Worker.Name); err != nil {
+					log.Printf("job %s: heartbeat: %v", id, err)
+				}
+			}
+			if err := RenewClaim(w.jobsbase(), id, w.config.Worker.Name, token); err != nil {
+				log.Printf("job %s: renewing claim: %v", id, err)
+			}
+		}
+	}
+}
+
+func (w *Worker) execute(ticket Ticket) {
+	id := ticket.Id
+	token, err := ClaimJob(w.jobsbase(), id, w.config.Worker.Name)
+	if err != nil {
+		if err != ErrAlreadyClaimed {
+			log.Printf("job %s: claim: %v", id, err)
+		}
+		return
+	}
+	stopBeat := make(chan struct{})
+	go w.heartbeat(id, token, stopBeat)
+	handedOff := false
+	defer func() {
+		if !handedOff {
+			close(stopBeat)
+			ReleaseClaim(w.jobsbase(), id, w.config.Worker.Name, token)
+		}
+	}()
+
+	payloads, ok := UnwrapJobSystem(w.jobsystem).(PayloadSystem)
+	if !ok {
+		w.fail(id, "job system cannot return job payloads")
+		return
+	}
+	jobType, raw, err := payloads.JobPayload(id)
+	if err != nil {
+		w.fail(id, fmt.Sprintf("loading job payload: %v", err))
+		return
+	}
+	w.dependencyPaths = nil
+	if deps, ok := UnwrapJobSystem(w.jobsystem).(DependencySystem); ok {
+		dependencies, err := deps.Dependencies(id)
+		if err != nil {
+			w.fail(id, fmt.Sprintf("loading dependencies: %v", err))
+			return
+		}
+		w.dependencyPaths = DependencyResultPaths(w.jobsbase(), dependencies)
+	}
+	resultBase := filepath.Join(w.jobsbase(), string(id))
+	if err := os.MkdirAll(resultBase, 0755); err != nil {
+		w.fail(id, err.Error())
+		return
+	}
+	started := time.Now()
+	commands := OpenCommandLog(w.jobsbase(), id)
+	commands.RecordVersion(w.config.Paths.Mmseqs)
+	databases, finished, err := w.run(id, jobType, raw, resultBase, commands)
+	if err != nil {
+		commands.Note("failed: %v", err)
+		w.fail(id, err.Error())
+		return
+	}
+	if !finished {
+		// a shard parent stays RUNNING until its watcher aggregates the
+		// children; the watcher inherits the claim and heartbeat so the
+		// dead-worker sweep does not requeue the parent and fan out the
+		// shards a second time
+		handedOff = true
+		go w.watchShardParent(id, token, stopBeat)
+		return
+	}
+	if err := w.finish(id, jobType, raw, databases, started); err != nil {
+		w.fail(id, err.Error())
+	}
+}
+
+// run executes the pipeline for one ticket and returns the databases it
+// searched. finished is false when completion is deferred (shard
+// fan-out).
+func (w *Worker) run(id Id, jobType JobType, raw []byte, resultBase string, commands *CommandLog) ([]string, bool, error) {
+	switch jobType {
+	case JobTypeMsa:
+		var job MsaJob
+		if err := json.Unmarshal(raw, &job); err != nil {
+			return nil, true, err
+		}
+		databases, err := w.runMsa(id, job, resultBase, commands)
+		return databases, true, err
+	case JobTypeSearch:
+		var job SearchJob
+		if err := json.Unmarshal(raw, &job); err != nil {
+			return nil, true, err
+		}
+		if handled, err := w.fanOutShards(id, job, raw); handled || err != nil {
+			return job.Database, false, err
+		}
+		return job.Database, true, w.runSearch(id, job, resultBase, commands)
+	case JobTypeStructureSearch:
+		var job StructureSearchJob
+		if err := json.Unmarshal(raw, &job); err != nil {
+			return nil, true, err
+		}
+		return job.Database, true, w.runStructureSearch(id, job, resultBase, commands)
+	case JobTypePair:
+		var job PairJob
+		if err := json.Unmarshal(raw, &job); err != nil {
+			return nil, true, err
+		}
+		databases, err := w.runPair(id, job, resultBase, commands)
+		return databases, true, err
+	case JobTypeIndex:
+		var job IndexJob
+		if err := json.Unmarshal(raw, &job); err != nil {
+			return nil, true, err
+		}
+		return job.Database, true, w.runIndex(job, commands)
+	}
+	return nil, true, fmt.Errorf("unknown job type %q", jobType)
+}
+
+// finish runs the shared post-processing: stats, manifests, archives,
+// callback and delivery, then flips the ticket to COMPLETE.
+func (w *Worker) finish(id Id, jobType JobType, raw []byte, databases []string, started time.Time) error {
+	resultBase := filepath.Join(w.jobsbase(), string(id))
+	mode := jobMode(jobType, raw)
+	switch jobType {
+	case JobTypeMsa, JobTypePair:
+		if err := NormalizeTaxonomyFiles(resultBase); err != nil {
+			log.Printf("job %s: taxonomy normalization: %v", id, err)
+		}
+		if _, err := WriteResultStats(resultBase); err != nil {
+			log.Printf("job %s: stats: %v", id, err)
+		}
+		if err := ConvertAlignmentsToFasta(resultBase); err != nil {
+			log.Printf("job %s: aligned fasta: %v", id, err)
+		}
+		if err := ChunkLargeAlignments(resultBase, a3mChunkMaxBytes); err != nil {
+			log.Printf("job %s: chunking: %v", id, err)
+		}
+	case JobTypeSearch, JobTypeStructureSearch:
+		if w.config.Templates.Enabled && strings.Contains(mode, "templates") {
+			if err := BundleTemplates(w.config.Templates, resultBase); err != nil {
+				log.Printf("job %s: bundling templates: %v", id, err)
+			}
+		}
+	}
+	if raw != nil {
+		// the filter runs here rather than per table so merged shard
+		// results and the MSA a3ms are covered too
+		var payload struct {
+			Filter *HitFilter `json:"filter"`
+		}
+		if err := json.Unmarshal(raw, &payload); err == nil {
+			if err := FilterResultHits(resultBase, payload.Filter); err != nil {
+				return fmt.Errorf("filtering hits: %w", err)
+			}
+		}
+	}
+	located := make(map[string]string, len(databases))
+	for _, database := range databases {
+		if root, found := w.roots.Locate(database); found {
+			located[database] = filepath.Join(root, database)
+		}
+	}
+	if _, err := WriteResultManifest(w.jobsbase(), id, readCommandLines(resultBase), located); err != nil {
+		return fmt.Errorf("writing manifest: %w", err)
+	}
+	if err := CreateResultArchive(w.jobsbase(), id, w.config.ResultArchive); err != nil {
+		return fmt.Errorf("archiving result: %w", err)
+	}
+	if jobType == JobTypeMsa && len(databases) > 1 {
+		if err := CreatePerDatabaseArchives(w.jobsbase(), id); err != nil {
+			log.Printf("job %s: per-database archives: %v", id, err)
+		}
+	}
+	if strings.Contains(mode, "zipoutput") {
+		if err := CreateResultZip(w.jobsbase(), id); err != nil {
+			log.Printf("job %s: zip archive: %v", id, err)
+		}
+	}
+	if w.objectStore != nil {
+		if err := OffloadResultArchive(w.objectStore, w.config.ObjectStore, w.jobsbase(), id); err != nil {
+			log.Printf("job %s: offloading archive: %v", id, err)
+		}
+	}
+	if w.stats != nil && len(databases) > 0 {
+		hits, _ := collectResultHits(resultBase)
+		if err := w.stats.RecordSearch(databases, int64(len(hits)), time.Since(started)); err != nil {
+			log.Printf("job %s: usage stats: %v", id, err)
+		}
+	}
+	if err := w.jobsystem.SetStatus(id, StatusComplete); err != nil {
+		return err
+	}
+	ticket, err := w.jobsystem.GetTicket(id)
+	if err != nil {
+		ticket = Ticket{Id: id, Status: StatusComplete}
+	}
+	if err := NotifyCallback(w.config.Callback, w.jobsbase(), ticket); err != nil {
+		log.Printf("job %s: callback: %v", id, err)
+	}
+	if err := DeliverResult(w.jobsbase(), id); err != nil {
+		log.Printf("job %s: delivery: %v", id, err)
+	}
+	return nil
+}
+
+// jobMode extracts the mode string shared by all job payloads.
+func jobMode(jobType JobType, raw []byte) string {
+	var payload struct {
+		Mode string `json:"mode"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return ""
+	}
+	return payload.Mode
+}
+
+// readCommandLines loads the command log for the manifest.
+func readCommandLines(resultBase string) []string {
+	raw, err := os.ReadFile(filepath.Join(resultBase, commandLogName))
+	if err != nil {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(string(raw), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// commandEnv is the environment of the pipeline processes; jobs with
+// dependencies see their prerequisite result directories. A nil return
+// keeps the inherited environment.
+func (w *Worker) commandEnv() []string {
+	if len(w.dependencyPaths) == 0 {
+		return nil
+	}
+	return append(os.Environ(),
+		dependencyResultsEnv+"="+strings.Join(w.dependencyPaths, string(os.PathListSeparator)))
+}
+
+// mmseqs runs one mmseqs subcommand through the command log.
+func (w *Worker) mmseqs(commands *CommandLog, args ...string) error {
+	cmd := exec.Command(w.config.Paths.Mmseqs, args...)
+	cmd.Env = w.commandEnv()
+	if w.config.Verbose {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+	return commands.Run(cmd)
+}
+
+func (w *Worker) foldseek(commands *CommandLog, args ...string) error {
+	cmd := exec.Command(w.config.Paths.Foldseek, args...)
+	cmd.Env = w.commandEnv()
+	if w.config.Verbose {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	}
+	return commands.Run(cmd)
+}
+
+func (w *Worker) threadArgs() []string {
+	if w.config.Worker.Threads > 0 {
+		return []string{"--threads", strconv.Itoa(w.config.Worker.Threads)}
+	}
+	return nil
+}
+
+// workDir creates the scratch directory of one job.
+func (w *Worker) workDir(id Id) (string, error) {
+	path := filepath.Join(w.config.Paths.Temporary, string(id))
+	return path, os.MkdirAll(path, 0755)
+}
+
+// locate resolves a database name against the roots and loads its
+// params sidecar.
+func (w *Worker) locate(database string) (string, DbParams, error) {
+	root, found := w.roots.Locate(database)
+	if !found {
+		return "", DbParams{}, fmt.Errorf("database %s is not installed", database)
+	}
+	params, err := ReadDbParams(root, database)
+	if err != nil {
+		return "", DbParams{}, err
+	}
+	return root, params, nil
+}
+
+// defaultMsaDatabases picks the pipeline targets for submissions that
+// do not pin databases.
+func (w *Worker) defaultMsaDatabases(useEnv bool) []string {
+	var databases []string
+	if w.config.Paths.Search.Uniref != "" {
+		databases = append(databases, w.config.Paths.Search.Uniref)
+	}
+	if useEnv && w.config.Paths.Search.Environmental != "" {
+		databases = append(databases, w.config.Paths.Search.Environmental)
+	}
+	return databases
+}
+
+// runMsa is the ColabFold MSA pipeline from colabfold_search.sh: an
+// iterated profile search per database, expanded against the profile
+// index where available, realigned, filtered and written as one a3m per
+// database.
+func (w *Worker) runMsa(id Id, job MsaJob, resultBase string, commands *CommandLog) ([]string, error) {
+	useEnv := strings.Contains(job.Mode, "env")
+	useTemplates := strings.Contains(job.Mode, "templates")
+	filter := !strings.Contains(job.Mode, "nofilter")
+	databases := job.DBs
+	if len(databases) == 0 {
+		databases = w.defaultMsaDatabases(useEnv)
+	}
+	if len(databases) == 0 {
+		return nil, fmt.Errorf("no databases configured for MSA jobs")
+	}
+	work, err := w.workDir(id)
+	if err != nil {
+		return databases, err
+	}
+	defer os.RemoveAll(work)
+	query := filepath.Join(resultBase, "job.fasta")
+	if err := os.WriteFile(query, []byte(job.Query), 0644); err != nil {
+		return databases, err
+	}
+	qdb := filepath.Join(work, "qdb")
+	if err := w.mmseqs(commands, "createdb", query, qdb); err != nil {
+		return databases, err
+	}
+	for _, database := range databases {
+		target := filepath.Join(resultBase, database+".a3m")
+		if err := w.profileSearch(qdb, database, work, target, filter, commands); err != nil {
+			return databases, err
+		}
+	}
+	if useTemplates && w.config.Paths.Search.Pdb != "" {
+		if err := w.templateSearch(qdb, w.config.Paths.Search.Pdb, work, resultBase, commands); err != nil {
+			return databases, err
+		}
+		databases = append(databases, w.config.Paths.Search.Pdb)
+	}
+	return databases, nil
+}
+
+// profileSearch runs the search/expand/align/filter/result2msa chain of
+// colabfold_search.sh against one database, writing the a3m to target.
+func (w *Worker) profileSearch(qdb string, database string, work string, target string, filter bool, commands *CommandLog) error {
+	root, params, err := w.locate(database)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, database)
+	pipeline := params.Pipeline
+	load := ChooseDbLoadSettings(root, database)
+	commands.Note("database %s resolved to %s", database, path)
+
+	res := filepath.Join(work, "res_"+database)
+	tmp := filepath.Join(work, "tmp_"+database)
+	searchArgs := []string{"search", qdb, path, res, tmp,
+		"--num-iterations", "3", "-a", "-e", "0.1", "--max-seqs", "10000"}
+	if extra := pipeline.SearchArgs(); len(extra) > 0 {
+		searchArgs = append(searchArgs, extra...)
+	} else {
+		searchArgs = append(searchArgs, "-s", "8")
+	}
+	searchArgs = append(searchArgs, load.Args()...)
+	searchArgs = append(searchArgs, SearchSplitArgs(w.config.IndexSplit, root, database)...)
+	searchArgs = append(searchArgs, w.threadArgs()...)
+	searchArgs = AdjustSearchArgsForTarget(searchArgs, IsProfileDatabase(root, database))
+	if err := w.mmseqs(commands, searchArgs...); err != nil {
+		return err
+	}
+
+	alignInput := res
+	reference := path
+	if pipeline != nil && pipeline.Profile {
+		reference = path + ".idx"
+		expanded := filepath.Join(work, "res_exp_"+database)
+		expandArgs := []string{"expandaln", qdb, reference, res, reference, expanded,
+			"--expansion-mode", "0", "--max-seq-id", "0.95"}
+		expandArgs = append(expandArgs, pipeline.ExpandArgs()...)
+		expandArgs = append(expandArgs, load.Args()...)
+		expandArgs = append(expandArgs, w.threadArgs()...)
+		if err := w.mmseqs(commands, expandArgs...); err != nil {
+			return err
+		}
+		alignInput = expanded
+	}
+
+	realigned := filepath.Join(work, "res_realign_"+database)
+	alignArgs := []string{"align", qdb, reference, alignInput, realigned,
+		"--max-accept", maxAcceptFor(filter, pipeline), "--alt-ali", "10", "-a"}
+	if extra := pipeline.AlignArgs(); len(extra) > 0 {
+		alignArgs = append(alignArgs, extra...)
+	} else {
+		alignArgs = append(alignArgs, "-e", "10")
+	}
+	alignArgs = append(alignArgs, load.Args()...)
+	alignArgs = append(alignArgs, w.threadArgs()...)
+	if err := w.mmseqs(commands, alignArgs...); err != nil {
+		return err
+	}
+
+	filtered := filepath.Join(work, "res_filter_"+database)
+	filterArgs := []string{"filterresult", qdb, reference, realigned, filtered,
+		"--qid", "0", "--qsc", qscFor(filter), "--diff", "0",
+		"--max-seq-id", "1.0", "--filter-min-enable", "100"}
+	filterArgs = append(filterArgs, load.Args()...)
+	filterArgs = append(filterArgs, w.threadArgs()...)
+	if err := w.mmseqs(commands, filterArgs...); err != nil {
+		return err
+	}
+
+	msaArgs := []string{"result2msa", qdb, reference, filtered, target,
+		"--msa-format-mode", "6",
+		"--filter-msa", boolFlag(filter), "--filter-min-enable", "1000",
+		"--qid", "0.0,0.2,0.4,0.6,0.8,1.0", "--max-seq-id", "0.95"}
+	if extra := pipeline.FilterArgs(); len(extra) > 0 {
+		msaArgs = append(msaArgs, extra...)
+	} else {
+		msaArgs = append(msaArgs, "--diff", "3000", "--qsc", "0")
+	}
+	msaArgs = append(msaArgs, load.Args()...)
+	msaArgs = append(msaArgs, w.threadArgs()...)
+	return w.mmseqs(commands, msaArgs...)
+}
+
+func boolFlag(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
+
+func qscFor(filter bool) string {
+	if filter {
+		return "0.8"
+	}
+	return "-20.0"
+}
+
+func maxAcceptFor(filter bool, pipeline *DbPipelineParams) string {
+	if pipeline != nil && pipeline.MaxAccept > 0 {
+		return strconv.Itoa(pipeline.MaxAccept)
+	}
+	if filter {
+		return "100000"
+	}
+	return "1000000"
+}
+
+// templateSearch runs the pdb template search of colabfold_search.sh,
+// leaving a <pdb>.m8 hit table next to the a3ms.
+func (w *Worker) templateSearch(qdb string, database string, work string, resultBase string, commands *CommandLog) error {
+	root, _, err := w.locate(database)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(root, database)
+	load := ChooseDbLoadSettings(root, database)
+	res := filepath.Join(work, "res_pdb")
+	tmp := filepath.Join(work, "tmp_pdb")
+	searchArgs := []string{"search", qdb, path, res, tmp, "-s", "7.5", "-a", "-e", "0.1"}
+	searchArgs = append(searchArgs, load.Args()...)
+	searchArgs = append(searchArgs, w.threadArgs()...)
+	if err := w.mmseqs(commands, searchArgs...); err != nil {
+		return err
+	}
+	convertArgs := []string{"convertalis", qdb, path + ".idx", res,
+		filepath.Join(resultBase, database+".m8"),
+		"--format-output", "query,target,fident,alnlen,mismatch,gapopen,qstart,qend,tstart,tend,evalue,bits,cigar"}
+	convertArgs = append(convertArgs, load.Args()...)
+	convertArgs = append(convertArgs, w.threadArgs()...)
+	return w.mmseqs(commands, convertArgs...)
+}
+
+// runSearch is a plain mmseqs search with a configurable hit table.
+func (w *Worker) runSearch(id Id, job SearchJob, resultBase string, commands *CommandLog) error {
+	if len(job.Database) == 0 {
+		return fmt.Errorf("no database given")
+	}
+	work, err := w.workDir(id)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	query := filepath.Join(resultBase, "job.fasta")
+	if err := os.WriteFile(query, []byte(job.Query), 0644); err != nil {
+		return err
+	}
+	qdb := filepath.Join(work, "qdb")
+	if err := w.mmseqs(commands, "createdb", query, qdb); err != nil {
+		return err
+	}
+	for _, database := range job.Database {
+		root, params, err := w.locate(database)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(root, database)
+		load := ChooseDbLoadSettings(root, database)
+		res := filepath.Join(work, "res_"+database)
+		tmp := filepath.Join(work, "tmp_"+database)
+		searchArgs := []string{"search", qdb, path, res, tmp, "-a"}
+		searchArgs = append(searchArgs, params.Pipeline.SearchArgs()...)
+		searchArgs = append(searchArgs, load.Args()...)
+		searchArgs = append(searchArgs, SearchSplitArgs(w.config.IndexSplit, root, database)...)
+		searchArgs = append(searchArgs, w.threadArgs()...)
+		searchArgs = AdjustSearchArgsForTarget(searchArgs, IsProfileDatabase(root, database))
+		if err := w.mmseqs(commands, searchArgs...); err != nil {
+			return err
+		}
+		table := filepath.Join(resultBase, database+".m8")
+		convertArgs := []string{"convertalis", qdb, path, res, table,
+			"--format-output", FormatOutputArg(job.OutputColumns)}
+		convertArgs = append(convertArgs, load.Args()...)
+		convertArgs = append(convertArgs, w.threadArgs()...)
+		if err := w.mmseqs(commands, convertArgs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runStructureSearch runs foldseek against structure databases.
+func (w *Worker) runStructureSearch(id Id, job StructureSearchJob, resultBase string, commands *CommandLog) error {
+	if len(job.Database) == 0 {
+		return fmt.Errorf("no database given")
+	}
+	work, err := w.workDir(id)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(work)
+	normalized, err := NormalizeStructureUpload(strings.NewReader(job.Query), 1)
+	if err != nil {
+		return err
+	}
+	query := filepath.Join(resultBase, "job.pdb")
+	if err := os.WriteFile(query, []byte(normalized), 0644); err != nil {
+		return err
+	}
+	for _, database := range job.Database {
+		root, _, err := w.locate(database)
+		if err != nil {
+			return err
+		}
+		table := filepath.Join(resultBase, database+".m8")
+		searchArgs := []string{"easy-search", query, filepath.Join(root, database), table,
+			filepath.Join(work, "tmp_"+database)}
+		if len(job.OutputColumns) > 0 {
+			searchArgs = append(searchArgs, "--format-output", FormatOutputArg(job.OutputColumns))
+		}
+		searchArgs = append(searchArgs, w.threadArgs()...)
+		if err := w.foldseek(commands, searchArgs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runPair builds the paired MSA for a complex: an iterated search
+// against the uniref database followed by pairaln, written as pair.a3m.
+func (w *Worker) runPair(id Id, job PairJob, resultBase string, commands *CommandLog) ([]string, error) {
+	database := w.config.Paths.Search.Uniref
+	if database == "" {
+		return nil, fmt.Errorf("no uniref database configured for pair jobs")
+	}
+	databases := []string{database}
+	root, params, err := w.locate(database)
+	if err != nil {
+		return databases, err
+	}
+	work, err := w.workDir(id)
+	if err != nil {
+		return databases, err
+	}
+	defer os.RemoveAll(work)
+	query := filepath.Join(resultBase, "job.fasta")
+	if err := os.WriteFile(query, []byte(job.Query), 0644); err != nil {
+		return databases, err
+	}
+	qdb := filepath.Join(work, "qdb")
+	if err := w.mmseqs(commands, "createdb", query, qdb); err != nil {
+		return databases, err
+	}
+	path := filepath.Join(root, database)
+	reference := path
+	if params.Pipeline != nil && params.Pipeline.Profile {
+		reference = path + ".idx"
+	}
+	load := ChooseDbLoadSettings(root, database)
+	res := filepath.Join(work, "res")
+	searchArgs := []string{"search", qdb, path, res, filepath.Join(work, "tmp"),
+		"--num-iterations", "3", "-a", "-e", "0.1", "--max-seqs", "10000"}
+	searchArgs = append(searchArgs, load.Args()...)
+	searchArgs = append(searchArgs, w.threadArgs()...)
+	if err := w.mmseqs(commands, searchArgs...); err != nil {
+		return databases, err
+	}
+	paired := filepath.Join(work, "res_pair")
+	pairArgs := []string{"pairaln", qdb, reference, res, paired}
+	pairArgs = append(pairArgs, load.Args()...)
+	pairArgs = append(pairArgs, w.threadArgs()...)
+	if err := w.mmseqs(commands, pairArgs...); err != nil {
+		return databases, err
+	}
+	msaArgs := []string{"result2msa", qdb, reference, paired,
+		filepath.Join(resultBase, "pair.a3m"),
+		"--msa-format-mode", "6", "--pairing-mode", pairingMode(job.Mode)}
+	msaArgs = append(msaArgs, load.Args()...)
+	msaArgs = append(msaArgs, w.threadArgs()...)
+	return databases, w.mmseqs(commands, msaArgs...)
+}
+
+func pairingMode(mode string) string {
+	if strings.Contains(mode, "pair:complete") {
+		return "1"
+	}
+	return "0"
+}
+
+// runIndex rebuilds the precomputed index of the given databases and
+// refreshes their integrity sidecars.
+func (w *Worker) runIndex(job IndexJob, commands *CommandLog) error {
+	for _, database := range job.Database {
+		root, params, err := w.locate(database)
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(root, database)
+		tmp, err := os.MkdirTemp(w.config.Paths.Temporary, "index-*")
+		if err != nil {
+			return err
+		}
+		indexArgs := []string{"createindex", path, tmp, "--remove-tmp-files", "1"}
+		indexArgs = append(indexArgs, IndexSplitArgs(w.config.IndexSplit, root, database)...)
+		indexArgs = append(indexArgs, w.threadArgs()...)
+		err = w.mmseqs(commands, indexArgs...)
+		os.RemoveAll(tmp)
+		if err != nil {
+			return err
+		}
+		if params.GpuCapable {
+			if err := EnsurePaddedDatabase(w.config.Paths.Mmseqs, root, database); err != nil {
+				return err
+			}
+		}
+		if err := WriteDatabaseChecksums(root, database); err != nil {
+			return err
+		}
+		if err := RecordMmseqsVersion(root, w.config.Paths.Mmseqs); err != nil {
+			log.Printf("index %s: recording mmseqs version: %v", database, err)
+		}
+	}
+	return nil
+}
+
+// fanOutShards splits a search against a sharded database into child
+// tickets and leaves a watcher to aggregate them; it reports whether
+// the ticket was handled this way.
+func (w *Worker) fanOutShards(id Id, job SearchJob, raw []byte) (bool, error) {
+	if !w.config.Shards.Enabled || len(job.Database) != 1 {
+		return false, nil
+	}
+	shards := ShardsOf(w.config.Shards, w.roots, job.Database[0])
+	if len(shards) == 0 {
+		return false, nil
+	}
+	request := JobRequest{
+		Id:     id,
+		Status: StatusRunning,
+		Type:   JobTypeSearch,
+		Job:    json.RawMessage(raw),
+	}
+	if _, err := FanOutShardedSearch(w.jobsystem, w.jobsbase(), request, shards); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// watchShardParent polls the children of a shard parent; once all are
+// COMPLETE it merges their hit tables and finishes the parent. It owns
+// the parent's claim and heartbeat until then; on worker shutdown it
+// releases both and leaves the parent to the dead-worker sweep.
+func (w *Worker) watchShardParent(id Id, token uint64, stopBeat chan struct{}) {
+	defer func() {
+		close(stopBeat)
+		ReleaseClaim(w.jobsbase(), id, w.config.Worker.Name, token)
+	}()
+	parent, err := readParentTicket(w.jobsbase(), id)
+	if err != nil {
+		w.fail(id, fmt.Sprintf("reading shard parent record: %v", err))
+		return
+	}
+	started := time.Now()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-time.After(shardWatchInterval):
+		}
+		children := make([]Ticket, 0, len(parent.Children))
+		for _, child := range parent.Children {
+			status, err := w.jobsystem.Status(child)
+			if err != nil {
+				status = StatusUnknown
+			}
+			children = append(children, Ticket{Id: child, Status: status})
+		}
+		switch aggregateStatus(children) {
+		case StatusComplete:
+			if err := MergeShardResults(w.jobsbase(), parent); err != nil {
+				w.fail(id, fmt.Sprintf("merging shard results: %v", err))
+				return
+			}
+			// hand finish the original payload so the submission's hit
+			// filter also applies to the merged tables
+			var raw []byte
+			if payloads, ok := UnwrapJobSystem(w.jobsystem).(PayloadSystem); ok {
+				_, raw, _ = payloads.JobPayload(id)
+			}
+			if err := w.finish(id, JobTypeSearch, raw, nil, started); err != nil {
+				w.fail(id, err.Error())
+			}
+			return
+		case StatusError, StatusUnknown:
+			w.fail(id, "a shard child failed")
+			return
+		}
+	}
+}